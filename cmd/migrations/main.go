@@ -30,7 +30,7 @@ func main() {
 
 	// if an error is there on starting connection
 	if err != nil {
-		panic(fmt.Sprintf("%s: %w", op, err))
+		panic(fmt.Errorf("%s: %w", op, err))
 	}
 
 	// migrate up or down (up by default)
@@ -61,7 +61,7 @@ func main() {
 			fmt.Println("Database is already up-to-date")
 		} else {
 			// PANIC IF SOMETHING ELSE WENT WRONG
-			panic(fmt.Sprintf("%s: %w", op, err))
+			panic(fmt.Errorf("%s: %w", op, err))
 		}
 	}
 	//logging the success
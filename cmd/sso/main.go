@@ -1,10 +1,13 @@
 package main
 
 import (
+	"context"
 	"log/slog"
 	"os"
+	"os/signal"
 	app "sso_go_grpc/internal/app"
 	"sso_go_grpc/internal/config"
+	"syscall"
 )
 
 func main() {
@@ -17,10 +20,37 @@ func main() {
 
 	application := app.New(log, cfg)
 
-	//running GRPC Server
-	//if error -> panic
-	application.GRPCServer.MustRun()
+	//running all servers, if a port can not be bound the application is stopped
+	if err := application.Start(); err != nil {
+		log.Error("Error on starting application", "err", err)
 
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+		defer cancel()
+
+		application.Stop(ctx)
+		os.Exit(1)
+	}
+
+	//waiting for SIGINT / SIGTERM or a server that stopped with an error
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+
+	var runErr error
+
+	select {
+	case sig := <-stop:
+		log.Info("Stopping application", "signal", sig.String(), "timeout", cfg.ShutdownTimeout)
+	case runErr = <-application.Errors():
+		log.Error("Stopping application after a server error", "err", runErr, "timeout", cfg.ShutdownTimeout)
+	}
+
+	//draining the requests until the shutdown timeout
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+
+	if err := application.Stop(ctx); err != nil || runErr != nil {
+		os.Exit(1)
+	}
 }
 
 // setupLogger returns logger depending on env | default = LevelDebug; dev = LevelInfo
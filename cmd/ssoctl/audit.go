@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sso_go_grpc/internal/domain/models"
+	"sso_go_grpc/internal/services"
+	"sso_go_grpc/internal/storage"
+)
+
+// auditVerify walks the hash chain of the audit log and reports the first broken link,
+// with -checkpoint it also checks that the head of the signed checkpoint is still part of the chain
+func auditVerify(service *services.Services, args []string) error {
+	var checkpointPath string
+
+	flags := flag.NewFlagSet("audit verify", flag.ExitOnError)
+	flags.StringVar(&checkpointPath, "checkpoint", "", "Signed checkpoint file to verify against")
+	flags.Parse(args)
+
+	ctx := context.Background()
+
+	verification, err := service.AuditService.VerifyChain(ctx)
+	if err != nil {
+		return err
+	}
+
+	if verification.BrokenAt != 0 {
+		fmt.Printf("chain broken at entry %d: %s\n", verification.BrokenAt, verification.Reason)
+		fmt.Printf("%d entries before it are intact\n", verification.Entries)
+		return storage.ErrAuditChainBroken
+	}
+
+	fmt.Printf("chain intact: %d entries, last entry %d, hash %s\n", verification.Entries, verification.LastId, verification.LastHash)
+
+	if checkpointPath == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(checkpointPath)
+	if err != nil {
+		return err
+	}
+
+	var checkpoint models.AuditCheckpoint
+	if err = json.Unmarshal(data, &checkpoint); err != nil {
+		return err
+	}
+
+	if err = service.AuditService.VerifyCheckpoint(ctx, &checkpoint); err != nil {
+		return err
+	}
+
+	fmt.Printf("checkpoint of %s matches: entry %d, hash %s\n", checkpoint.CreatedAt.Format("2006-01-02 15:04:05 MST"), checkpoint.LastId, checkpoint.LastHash)
+	return nil
+}
+
+// auditCheckpoint writes a checkpoint of the verified head of the chain, signed with the checkpoint key,
+// to stdout or to the -out file
+func auditCheckpoint(service *services.Services, args []string) error {
+	var out string
+
+	flags := flag.NewFlagSet("audit checkpoint", flag.ExitOnError)
+	flags.StringVar(&out, "out", "", "File to write the checkpoint to (stdout by default)")
+	flags.Parse(args)
+
+	checkpoint, err := service.AuditService.Checkpoint(context.Background())
+	if err != nil {
+		return err
+	}
+
+	var writer io.Writer = os.Stdout
+	if out != "" {
+		file, err := os.OpenFile(out, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		writer = file
+	}
+
+	encoder := json.NewEncoder(writer)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(checkpoint)
+}
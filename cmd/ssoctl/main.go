@@ -0,0 +1,64 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"sso_go_grpc/internal/config"
+	"sso_go_grpc/internal/services"
+	"sso_go_grpc/internal/storage/postgres"
+)
+
+const usage = `ssoctl - administration of the sso
+
+usage: ssoctl [-config path] <command> <subcommand> [flags]
+
+commands:
+  users export -user-id <id> [-out file]    export everything stored about a user as json
+  users import -file <file> [-format csv|jsonl] [-dry-run]
+                                            import users with existing bcrypt hashes
+  audit verify [-checkpoint file]           verify the hash chain of the audit log
+  audit checkpoint [-out file]              export a signed checkpoint of the audit chain
+`
+
+func main() {
+	//setting up config
+	//config.MustLoad parses the global flags, the command is what is left
+	cfg := config.MustLoad()
+
+	args := flag.Args()
+	if len(args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	//only warnings, the output of the commands is the important part
+	log := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelWarn}))
+
+	storage := postgres.MustLoad(cfg, log)
+	defer storage.Db.Close()
+
+	service := services.New(log, storage, cfg)
+
+	var err error
+
+	switch args[0] + " " + args[1] {
+	case "users export":
+		err = usersExport(service, args[2:])
+	case "users import":
+		err = usersImport(service, args[2:])
+	case "audit verify":
+		err = auditVerify(service, args[2:])
+	case "audit checkpoint":
+		err = auditCheckpoint(service, args[2:])
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s %s: %v\n", args[0], args[1], err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sso_go_grpc/internal/domain/models"
+	"sso_go_grpc/internal/services"
+	"strings"
+)
+
+// usersExport writes the data export of one user to stdout or to the -out file
+func usersExport(service *services.Services, args []string) error {
+	var (
+		userId uint64
+		out    string
+	)
+
+	flags := flag.NewFlagSet("users export", flag.ExitOnError)
+	flags.Uint64Var(&userId, "user-id", 0, "Id of the user to export")
+	flags.StringVar(&out, "out", "", "File to write the export to (stdout by default)")
+	flags.Parse(args)
+
+	if userId == 0 {
+		return errors.New("-user-id is required")
+	}
+
+	export, err := service.UserService.BuildUserExport(context.Background(), userId)
+	if err != nil {
+		return err
+	}
+
+	var writer io.Writer = os.Stdout
+	if out != "" {
+		file, err := os.OpenFile(out, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		writer = file
+	}
+
+	encoder := json.NewEncoder(writer)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(export)
+}
+
+// usersImport imports the users of a csv or jsonl file and prints the result of every row.
+// csv files need the header email,username,password_hash,roles where roles are separated by ";",
+// jsonl files have one models.ImportUser per line
+func usersImport(service *services.Services, args []string) error {
+	var (
+		path, format string
+		dryRun       bool
+	)
+
+	flags := flag.NewFlagSet("users import", flag.ExitOnError)
+	flags.StringVar(&path, "file", "", "csv or jsonl file with the users")
+	flags.StringVar(&format, "format", "", "( csv / jsonl ) format of the file (by the file extension by default)")
+	flags.BoolVar(&dryRun, "dry-run", false, "Check every row without saving anything")
+	flags.Parse(args)
+
+	if path == "" {
+		return errors.New("-file is required")
+	}
+
+	if format == "" {
+		format = strings.TrimPrefix(filepath.Ext(path), ".")
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var next func() (*models.ImportUser, error)
+
+	switch format {
+	case "csv":
+		next, err = csvImportReader(file)
+		if err != nil {
+			return err
+		}
+	case "jsonl", "ndjson":
+		decoder := json.NewDecoder(file)
+		next = func() (*models.ImportUser, error) {
+			var user models.ImportUser
+			if err := decoder.Decode(&user); err != nil {
+				return nil, err
+			}
+			return &user, nil
+		}
+	default:
+		return fmt.Errorf("unknown format %q, expected csv or jsonl", format)
+	}
+
+	results, err := service.UserService.RunImport(context.Background(), dryRun, 0, next)
+	if err != nil {
+		return err
+	}
+
+	var failed int
+	for _, result := range results {
+		if result.Error != "" {
+			failed++
+			fmt.Printf("row %d %s: %s\n", result.Row, result.Email, result.Error)
+			continue
+		}
+		fmt.Printf("row %d %s: imported (userId %d)\n", result.Row, result.Email, result.UserId)
+	}
+
+	fmt.Printf("%d imported, %d failed, dry run: %t\n", len(results)-failed, failed, dryRun)
+	return nil
+}
+
+// csvImportReader returns a next function for RunImport reading the csv rows
+func csvImportReader(file io.Reader) (func() (*models.ImportUser, error), error) {
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, column := range header {
+		columns[strings.TrimSpace(column)] = i
+	}
+
+	for _, column := range []string{"email", "username"} {
+		if _, ok := columns[column]; !ok {
+			return nil, fmt.Errorf("csv header has no %s column", column)
+		}
+	}
+
+	// value returns the column of the record, missing columns are empty
+	value := func(record []string, column string) string {
+		i, ok := columns[column]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	return func() (*models.ImportUser, error) {
+		record, err := reader.Read()
+		if err != nil {
+			return nil, err
+		}
+
+		user := &models.ImportUser{
+			Email:        value(record, "email"),
+			Username:     value(record, "username"),
+			PasswordHash: value(record, "password_hash"),
+		}
+
+		for _, role := range strings.Split(value(record, "roles"), ";") {
+			if role = strings.TrimSpace(role); role != "" {
+				user.Roles = append(user.Roles, role)
+			}
+		}
+
+		return user, nil
+	}, nil
+}
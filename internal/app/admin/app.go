@@ -0,0 +1,94 @@
+package adminApp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"sso_go_grpc/internal/lib/metrics"
+	"time"
+)
+
+// MetricsPath is the path of the prometheus metrics
+const MetricsPath = "/metrics"
+
+type App struct {
+	log        *slog.Logger
+	httpServer *http.Server
+	port       int
+
+	// listener is bound by Listen, Run binds it if Listen was not called
+	listener net.Listener
+}
+
+// Listen binds the port, so a port in use is reported before the server runs in the background
+func (app *App) Listen() error {
+	l, err := net.Listen("tcp", app.httpServer.Addr)
+	if err != nil {
+		return fmt.Errorf("admin.app.Listen: %w", err)
+	}
+
+	app.listener = l
+	return nil
+}
+
+func (app *App) MustRun() {
+	if err := app.Run(); err != nil {
+		panic(err)
+	}
+}
+
+// Run this method runs the admin http server
+func (app *App) Run() error {
+	const op = "admin.app.Run"
+
+	//setup logger for this function
+	log := app.log.With(slog.String("op", op))
+
+	if app.listener == nil {
+		if err := app.Listen(); err != nil {
+			log.Error("Error on listening", "err", err)
+			return err
+		}
+	}
+
+	log.Info("Starting Admin Server", "port", app.port, "metrics", MetricsPath)
+
+	if err := app.httpServer.Serve(app.listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Error("Error on serving admin", "err", err)
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// Stop stops taking new requests and waits for the running ones until the context is done
+func (app *App) Stop(ctx context.Context) error {
+	if err := app.httpServer.Shutdown(ctx); err != nil {
+		app.httpServer.Close()
+		return fmt.Errorf("admin.app.Stop: %w", err)
+	}
+
+	// the listener is not closed by the server if it was stopped before it served
+	if app.listener != nil {
+		app.listener.Close()
+	}
+
+	return nil
+}
+
+// New returns the admin server, it is meant for the internal network only and has no authentication
+func New(log *slog.Logger, port int) *App {
+	mux := http.NewServeMux()
+	mux.Handle(MetricsPath, metrics.Handler())
+
+	httpServer := &http.Server{
+		Addr:              fmt.Sprintf(":%d", port),
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	return &App{log: log, httpServer: httpServer, port: port}
+}
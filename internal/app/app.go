@@ -1,27 +1,231 @@
 package app
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 	"log/slog"
+	adminApp "sso_go_grpc/internal/app/admin"
+	gatewayApp "sso_go_grpc/internal/app/gateway"
 	grpcApp "sso_go_grpc/internal/app/grpc"
+	scimApp "sso_go_grpc/internal/app/scim"
+	webhookApp "sso_go_grpc/internal/app/webhook"
 	"sso_go_grpc/internal/config"
+	"sso_go_grpc/internal/lib/tracing"
 	"sso_go_grpc/internal/services"
 	"sso_go_grpc/internal/storage/postgres"
 )
 
 type App struct {
 	GRPCServer *grpcApp.App
+	// ScimServer is nil if SCIM is not enabled
+	ScimServer *scimApp.App
+	// GatewayServer is nil if the REST/JSON gateway is not enabled
+	GatewayServer *gatewayApp.App
+	// WebhookDispatcher is nil if webhooks are not enabled
+	WebhookDispatcher *webhookApp.App
+	// AdminServer is nil if the admin server with the metrics is not enabled
+	AdminServer *adminApp.App
+
+	log     *slog.Logger
+	storage *postgres.Storage
+	// errs receives the errors of the servers that stopped by themselves
+	errs chan error
+	// tracer is nil if the export of traces is not enabled
+	tracer *sdktrace.TracerProvider
 }
 
 func New(log *slog.Logger, cfg *config.Config) *App {
 
+	//setting up tracing before the storage, so the database connections are traced
+	tracer := newTracer(cfg.Tracing)
+
 	//setting up storage
 	storage := postgres.MustLoad(cfg, log)
 
 	service := services.New(log, storage, cfg)
 
-	app := grpcApp.New(log, service, cfg.GRPC.Port)
+	app := grpcApp.New(log, service, cfg.GRPC, storage.CheckReady)
+
+	var scim *scimApp.App
+	if cfg.Scim.Enabled {
+		if cfg.Scim.Token == "" {
+			panic("app.New: scim is enabled without a token")
+		}
+		scim = scimApp.New(log, service.ScimService, cfg.Scim.Port, cfg.Scim.Token)
+	}
+
+	var gateway *gatewayApp.App
+	if cfg.Gateway.Enabled {
+		gateway = gatewayApp.New(log, cfg.Gateway, cfg.GRPC)
+	}
+
+	var webhook *webhookApp.App
+	if cfg.Webhooks.Enabled {
+		webhook = webhookApp.New(log, cfg.Webhooks, service.OutboxProvider)
+	}
+
+	var admin *adminApp.App
+	if cfg.Admin.Enabled {
+		admin = adminApp.New(log, cfg.Admin.Port)
+	}
 
 	return &App{
-		GRPCServer: app,
+		GRPCServer:        app,
+		ScimServer:        scim,
+		GatewayServer:     gateway,
+		WebhookDispatcher: webhook,
+		AdminServer:       admin,
+		log:               log,
+		storage:           storage,
+		tracer:            tracer,
+	}
+}
+
+// Errors returns the errors of servers that stopped while the application is running,
+// the application has to be stopped after an error
+func (a *App) Errors() <-chan error {
+	return a.errs
+}
+
+// newTracer sets up the global tracer provider and the W3C trace context propagation,
+// returns nil if the export is not enabled
+func newTracer(cfg config.TracingConfig) *sdktrace.TracerProvider {
+	if !cfg.Enabled {
+		tracing.SetGlobal(nil)
+		return nil
+	}
+
+	exporter, err := tracing.NewOTLPExporter(context.Background(), cfg.Endpoint, cfg.Insecure)
+	if err != nil {
+		panic(fmt.Errorf("app.newTracer: %w", err))
+	}
+
+	tracer := tracing.NewProvider(exporter, tracing.Options{ServiceName: cfg.ServiceName, SampleRatio: cfg.SampleRatio})
+	tracing.SetGlobal(tracer)
+
+	return tracer
+}
+
+// Start binds the ports of all servers and then runs the servers and workers in the background;
+// if a port can not be bound nothing runs and the error is returned, the application still has to be stopped.
+// Errors of servers that stop later are sent to Errors
+func (a *App) Start() error {
+	const op = "app.Start"
+
+	log := a.log.With(slog.String("op", op))
+
+	servers := a.servers()
+
+	// the ports are bound before anything runs, so a port in use is an error of Start instead of a panic later
+	for _, srv := range servers {
+		if err := srv.Listen(); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	// every server sends at most one error, so the servers never block on it
+	a.errs = make(chan error, len(servers))
+
+	for _, srv := range servers {
+		go func(srv server) {
+			if err := srv.Run(); err != nil {
+				log.Error("Server stopped with an error", "err", err)
+				a.errs <- err
+			}
+		}(srv)
+	}
+
+	//running the webhook dispatcher next to the servers
+	if a.WebhookDispatcher != nil {
+		a.WebhookDispatcher.Start()
+	}
+
+	return nil
+}
+
+// server is one of the servers of the application
+type server interface {
+	Listen() error
+	Run() error
+}
+
+// servers returns the enabled servers, the GRPC server first
+func (a *App) servers() []server {
+	servers := []server{a.GRPCServer}
+
+	if a.ScimServer != nil {
+		servers = append(servers, a.ScimServer)
+	}
+	if a.GatewayServer != nil {
+		servers = append(servers, a.GatewayServer)
+	}
+	if a.AdminServer != nil {
+		servers = append(servers, a.AdminServer)
 	}
+
+	return servers
+}
+
+// Stop shuts everything down until the context is done: the watch streams are ended,
+// the http servers and the gRPC server drain their requests, the workers stop and the database pool is closed
+func (a *App) Stop(ctx context.Context) error {
+	const op = "app.Stop"
+
+	log := a.log.With(slog.String("op", op))
+
+	var errs []error
+
+	// the watch streams never end by themselves, so they are ended before the servers wait for their requests
+	if err := a.storage.Outbox.Notifier.Close(); err != nil {
+		errs = append(errs, err)
+	}
+
+	// the gateway forwards to the gRPC server, so it is stopped first
+	if a.GatewayServer != nil {
+		if err := a.GatewayServer.Stop(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if a.ScimServer != nil {
+		if err := a.ScimServer.Stop(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	a.GRPCServer.Stop(ctx)
+
+	if a.WebhookDispatcher != nil {
+		if err := a.WebhookDispatcher.Stop(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	// the metrics are served until the end, so the shutdown itself can be scraped
+	if a.AdminServer != nil {
+		if err := a.AdminServer.Stop(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if err := a.storage.Close(); err != nil {
+		errs = append(errs, err)
+	}
+
+	// the last spans are exported after everything that creates spans is stopped
+	if a.tracer != nil {
+		if err := a.tracer.Shutdown(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		log.Error("Error on stopping the application", "err", err)
+		return err
+	}
+
+	log.Info("Application stopped")
+	return nil
 }
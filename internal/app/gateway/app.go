@@ -0,0 +1,141 @@
+package gatewayApp
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"log/slog"
+	"net"
+	"net/http"
+	"sso_go_grpc/internal/config"
+	gatewayServer "sso_go_grpc/internal/http/gateway"
+	"sso_go_grpc/internal/lib/tlsconfig"
+	"time"
+)
+
+type App struct {
+	log        *slog.Logger
+	httpServer *http.Server
+	conn       *grpc.ClientConn
+	port       int
+
+	// listener is bound by Listen, Run binds it if Listen was not called
+	listener net.Listener
+}
+
+// Listen binds the port, so a port in use is reported before the server runs in the background
+func (app *App) Listen() error {
+	l, err := net.Listen("tcp", app.httpServer.Addr)
+	if err != nil {
+		return fmt.Errorf("gateway.app.Listen: %w", err)
+	}
+
+	app.listener = l
+	return nil
+}
+
+func (app *App) MustRun() {
+	if err := app.Run(); err != nil {
+		panic(err)
+	}
+}
+
+// Run this method runs the REST/JSON gateway
+func (app *App) Run() error {
+	const op = "gateway.app.Run"
+
+	//setup logger for this function
+	log := app.log.With(slog.String("op", op))
+
+	if app.listener == nil {
+		if err := app.Listen(); err != nil {
+			log.Error("Error on listening", "err", err)
+			return err
+		}
+	}
+
+	log.Info("Starting Gateway Server", "port", app.port, "openapi", gatewayServer.OpenApiPath)
+
+	if err := app.httpServer.Serve(app.listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Error("Error on serving gateway", "err", err)
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// Stop stops taking new requests, waits for the running ones until the context is done
+// and closes the connection to the gRPC server
+func (app *App) Stop(ctx context.Context) error {
+	defer app.conn.Close()
+
+	if err := app.httpServer.Shutdown(ctx); err != nil {
+		app.httpServer.Close()
+		return fmt.Errorf("gateway.app.Stop: %w", err)
+	}
+
+	// the listener is not closed by the server if it was stopped before it served
+	if app.listener != nil {
+		app.listener.Close()
+	}
+
+	return nil
+}
+
+// New returns the gateway forwarding to the gRPC server of this host,
+// the connection is established on the first request
+func New(log *slog.Logger, cfg config.GatewayConfig, grpcCfg config.GrpcConfig) *App {
+	const op = "gateway.app.New"
+
+	creds := insecure.NewCredentials()
+	if grpcCfg.TLS.Enabled {
+		tlsConfig, err := clientTLSConfig(cfg.GrpcTLS)
+		if err != nil {
+			panic(fmt.Errorf("%s: %w", op, err))
+		}
+		creds = credentials.NewTLS(tlsConfig)
+	}
+
+	conn, err := grpc.Dial(fmt.Sprintf("localhost:%d", grpcCfg.Port), grpc.WithTransportCredentials(creds))
+	if err != nil {
+		panic(fmt.Errorf("%s: %w", op, err))
+	}
+
+	cors := gatewayServer.CorsConfig{AllowedOrigins: cfg.CorsOrigins, MaxAge: cfg.CorsMaxAge}
+
+	httpServer := &http.Server{
+		Addr:              fmt.Sprintf(":%d", cfg.Port),
+		Handler:           gatewayServer.NewHandler(conn, log, cors),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	return &App{log: log, httpServer: httpServer, conn: conn, port: cfg.Port}
+}
+
+// clientTLSConfig returns the tls config of the connection to the gRPC server,
+// with a client certificate if the server requires mTLS
+func clientTLSConfig(cfg config.GatewayTLSConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{ServerName: cfg.ServerName, MinVersion: tls.VersionTLS12}
+
+	if cfg.CAFile != "" {
+		pool, err := tlsconfig.LoadCertPool(cfg.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if cfg.CertFile != "" {
+		certificate, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{certificate}
+	}
+
+	return tlsConfig, nil
+}
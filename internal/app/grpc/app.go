@@ -1,19 +1,55 @@
 package grpcApp
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
 	"log/slog"
 	"net"
+	"sso_go_grpc/internal/config"
+	attributeServer "sso_go_grpc/internal/grpc/attribute"
+	auditServer "sso_go_grpc/internal/grpc/audit"
+	"sso_go_grpc/internal/grpc/interceptors"
 	roleServer "sso_go_grpc/internal/grpc/role"
 	userServer "sso_go_grpc/internal/grpc/user"
+	webhookServer "sso_go_grpc/internal/grpc/webhook"
 	"sso_go_grpc/internal/services"
+	"sso_go_grpc/internal/storage/postgres/idempotency"
+	"time"
 )
 
 type App struct {
 	log        *slog.Logger
 	gRPCServer *grpc.Server
 	port       int
+	tls        bool
+
+	health         *health.Server
+	ready          ReadinessCheck
+	healthInterval time.Duration
+	// services are the health service names reporting the readiness
+	services []string
+
+	// listener is bound by Listen, Run binds it if Listen was not called
+	listener net.Listener
+
+	// idempotency has the stored responses of the idempotency keys, the expired ones are purged while the server runs
+	idempotency *idempotency.Storage
+}
+
+// Listen binds the port, so a port in use is reported before the server runs in the background
+func (app *App) Listen() error {
+	l, err := net.Listen("tcp", fmt.Sprintf(":%d", app.port))
+	if err != nil {
+		return fmt.Errorf("grpc.app.Listen: %w", err)
+	}
+
+	app.listener = l
+	return nil
 }
 
 func (app *App) MustRun() {
@@ -30,35 +66,127 @@ func (app *App) Run() error {
 	//setup logger for this function
 	log := app.log.With(slog.String("op", op))
 
-	//starting TCP listener
-	l, err := net.Listen("tcp", fmt.Sprintf(":%d", app.port))
-
-	if err != nil {
-		log.Error(fmt.Sprintf("%s: %w", op, err))
-		return fmt.Errorf("%s: %w", op, err)
+	//starting TCP listener if Listen was not called
+	if app.listener == nil {
+		if err := app.Listen(); err != nil {
+			log.Error("Error on listening", "err", err)
+			return err
+		}
 	}
 
-	log.Info("Starting Grpc Server", "port", app.port)
-	//Serving the listener to the GRPC server
-	//if there is an error return it
-	if err := app.gRPCServer.Serve(l); err != nil {
-		log.Info("Successfully started Grpc server on ", "port", app.port)
+	// the readiness is reported until the server stops
+	stop := make(chan struct{})
+	defer close(stop)
+	go app.watchReadiness(stop)
+	go app.purgeIdempotencyKeys(stop)
 
-		log.Error(fmt.Sprintf("%s: %w", op, err))
+	log.Info("Starting Grpc Server", "port", app.port, "tls", app.tls)
+	//Serving the listener to the GRPC server
+	//if there is an error return it, a server stopped before it served is no error
+	if err := app.gRPCServer.Serve(app.listener); err != nil && !errors.Is(err, grpc.ErrServerStopped) {
+		log.Error("Error on serving gRPC", "err", err)
 		return fmt.Errorf("%s: %w", op, err)
 	}
-	log.Info("Successfully started Grpc server on ", "port", app.port)
+	log.Info("Grpc Server stopped", "port", app.port)
 	return nil
 }
 
-func New(log *slog.Logger, services *services.Services, port int) *App {
+// Stop stops taking new RPCs and waits for the running ones until the context is done,
+// then the remaining RPCs are canceled; the health checks report NOT_SERVING from the start
+func (app *App) Stop(ctx context.Context) {
+	const op = "grpc.app.Stop"
+
+	log := app.log.With(slog.String("op", op))
+
+	app.health.Shutdown()
+
+	stopped := make(chan struct{})
+	go func() {
+		app.gRPCServer.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		log.Warn("Deadline exceeded while draining RPCs, canceling the remaining ones")
+		app.gRPCServer.Stop()
+		<-stopped
+	}
+
+	// the listener is not closed by the server if it was stopped before it served
+	if app.listener != nil {
+		app.listener.Close()
+	}
+}
+
+func New(log *slog.Logger, services *services.Services, cfg config.GrpcConfig, ready ReadinessCheck) *App {
+	const op = "grpc.app.New"
+
+	// the span and request id come first so every log line has them, panics are recovered before they reach the logging
+	// and the metrics, so they are counted as Internal; only valid requests claim an idempotency key
+	options := []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(
+			interceptors.TracingUnary(),
+			interceptors.MetricsUnary(),
+			interceptors.RequestIdUnary(),
+			interceptors.LoggingUnary(log),
+			interceptors.RecoverUnary(log),
+			interceptors.ValidateUnary(),
+			interceptors.IdempotencyUnary(services.IdempotencyProvider, cfg.Idempotency, services.Cfg.JwtSecret, log),
+		),
+		grpc.ChainStreamInterceptor(
+			interceptors.TracingStream(),
+			interceptors.MetricsStream(),
+			interceptors.RequestIdStream(),
+			interceptors.LoggingStream(log),
+			interceptors.RecoverStream(log),
+			interceptors.ValidateStream(),
+		),
+	}
+
+	// without TLS the listener is plain TCP, e.g. behind a TLS terminating proxy
+	if cfg.TLS.Enabled {
+		creds, err := serverCredentials(cfg.TLS, log)
+		if err != nil {
+			panic(fmt.Errorf("%s: %w", op, err))
+		}
+		options = append(options, grpc.Creds(creds))
+	}
+
 	//creating new Grpc Server
-	grpcServer := grpc.NewServer()
+	grpcServer := grpc.NewServer(options...)
 
 	//Register the new gRPC Server with the  AUthService
 	userServer.RegisterServer(grpcServer, services.UserService)
 	roleServer.RegisterServer(grpcServer, services.RoleService)
+	attributeServer.RegisterServer(grpcServer, services.AttributeService)
+	auditServer.RegisterServer(grpcServer, services.AuditService)
+	webhookServer.RegisterServer(grpcServer, services.WebhookService)
+
+	// the empty name is the health of the whole server
+	healthServices := []string{""}
+	for service := range grpcServer.GetServiceInfo() {
+		healthServices = append(healthServices, service)
+	}
+
+	healthServer := newHealthServer(healthServices)
+	healthpb.RegisterHealthServer(grpcServer, healthServer)
+
+	if cfg.Reflection {
+		reflection.Register(grpcServer)
+	}
 
 	//return a structure with that params
-	return &App{log: log, gRPCServer: grpcServer, port: port}
+	return &App{
+		log:            log,
+		gRPCServer:     grpcServer,
+		port:           cfg.Port,
+		tls:            cfg.TLS.Enabled,
+		health:         healthServer,
+		ready:          ready,
+		healthInterval: cfg.HealthInterval,
+		services:       healthServices,
+		idempotency:    services.IdempotencyProvider,
+	}
 }
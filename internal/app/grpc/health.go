@@ -0,0 +1,67 @@
+package grpcApp
+
+import (
+	"context"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"time"
+)
+
+// LivenessService is the health service name that is serving as long as the process runs,
+// the empty name and the api services are serving while the server is ready
+const LivenessService = "liveness"
+
+// ReadinessCheck returns an error if the server can not handle requests
+type ReadinessCheck func(ctx context.Context) error
+
+// watchReadiness runs the readiness check every interval and reports it on the health server until stop is closed
+func (app *App) watchReadiness(stop <-chan struct{}) {
+	ticker := time.NewTicker(app.healthInterval)
+	defer ticker.Stop()
+
+	logger := app.log.With("op", "grpc.app.watchReadiness")
+	ready := false
+
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), app.healthInterval)
+		err := app.ready(ctx)
+		cancel()
+
+		// only the changes are logged, not every check
+		if (err == nil) != ready {
+			ready = err == nil
+			if ready {
+				logger.Info("Server is ready")
+			} else {
+				logger.Warn("Server is not ready", "err", err)
+			}
+		}
+
+		servingStatus := healthpb.HealthCheckResponse_SERVING
+		if !ready {
+			servingStatus = healthpb.HealthCheckResponse_NOT_SERVING
+		}
+
+		for _, service := range app.services {
+			app.health.SetServingStatus(service, servingStatus)
+		}
+
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// newHealthServer returns the health server, everything but the liveness is not serving until the first check
+func newHealthServer(services []string) *health.Server {
+	healthServer := health.NewServer()
+
+	healthServer.SetServingStatus(LivenessService, healthpb.HealthCheckResponse_SERVING)
+	for _, service := range services {
+		healthServer.SetServingStatus(service, healthpb.HealthCheckResponse_NOT_SERVING)
+	}
+
+	return healthServer
+}
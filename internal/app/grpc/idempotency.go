@@ -0,0 +1,37 @@
+package grpcApp
+
+import (
+	"context"
+	"time"
+)
+
+// idempotencyPurgeInterval is the interval the expired idempotency keys are deleted in
+const idempotencyPurgeInterval = 10 * time.Minute
+
+// purgeIdempotencyKeys deletes the expired idempotency keys every idempotencyPurgeInterval until stop is closed
+func (app *App) purgeIdempotencyKeys(stop <-chan struct{}) {
+	ticker := time.NewTicker(idempotencyPurgeInterval)
+	defer ticker.Stop()
+
+	logger := app.log.With("op", "grpc.app.purgeIdempotencyKeys")
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), idempotencyPurgeInterval)
+		deleted, err := app.idempotency.DeleteExpired(ctx)
+		cancel()
+
+		if err != nil {
+			logger.Warn("Error on deleting expired idempotency keys", "err", err)
+			continue
+		}
+		if deleted > 0 {
+			logger.Debug("Deleted expired idempotency keys", "count", deleted)
+		}
+	}
+}
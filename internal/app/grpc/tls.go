@@ -0,0 +1,42 @@
+package grpcApp
+
+import (
+	"crypto/tls"
+	"google.golang.org/grpc/credentials"
+	"log/slog"
+	"sso_go_grpc/internal/config"
+	"sso_go_grpc/internal/lib/tlsconfig"
+)
+
+// serverCredentials returns the TLS credentials of the listener, the certificate files are reloaded when they change
+func serverCredentials(cfg config.TLSConfig, log *slog.Logger) (credentials.TransportCredentials, error) {
+	minVersion, err := tlsconfig.ParseVersion(cfg.MinVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	cipherSuites, err := tlsconfig.ParseCipherSuites(cfg.CipherSuites)
+	if err != nil {
+		return nil, err
+	}
+
+	clientAuth, err := tlsconfig.ParseClientAuth(cfg.ClientAuth)
+	if err != nil {
+		return nil, err
+	}
+
+	base := &tls.Config{
+		MinVersion:   minVersion,
+		CipherSuites: cipherSuites,
+		ClientAuth:   clientAuth,
+		// the config of every handshake comes from the reloader, so it has to announce http/2 itself
+		NextProtos: []string{"h2"},
+	}
+
+	reloader, err := tlsconfig.NewReloader(base, cfg.CertFile, cfg.KeyFile, cfg.ClientCAFile, log)
+	if err != nil {
+		return nil, err
+	}
+
+	return credentials.NewTLS(reloader.Config()), nil
+}
@@ -0,0 +1,88 @@
+package scimApp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	scimServer "sso_go_grpc/internal/http/scim"
+	scimService "sso_go_grpc/internal/services/scim"
+	"time"
+)
+
+type App struct {
+	log        *slog.Logger
+	httpServer *http.Server
+	port       int
+
+	// listener is bound by Listen, Run binds it if Listen was not called
+	listener net.Listener
+}
+
+// Listen binds the port, so a port in use is reported before the server runs in the background
+func (app *App) Listen() error {
+	l, err := net.Listen("tcp", app.httpServer.Addr)
+	if err != nil {
+		return fmt.Errorf("scim.app.Listen: %w", err)
+	}
+
+	app.listener = l
+	return nil
+}
+
+func (app *App) MustRun() {
+	if err := app.Run(); err != nil {
+		panic(err)
+	}
+}
+
+// Run this method runs the SCIM http server
+func (app *App) Run() error {
+	const op = "scim.app.Run"
+
+	//setup logger for this function
+	log := app.log.With(slog.String("op", op))
+
+	if app.listener == nil {
+		if err := app.Listen(); err != nil {
+			log.Error("Error on listening", "err", err)
+			return err
+		}
+	}
+
+	log.Info("Starting SCIM Server", "port", app.port)
+
+	if err := app.httpServer.Serve(app.listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Error("Error on serving SCIM", "err", err)
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// Stop stops taking new requests and waits for the running ones until the context is done
+func (app *App) Stop(ctx context.Context) error {
+	if err := app.httpServer.Shutdown(ctx); err != nil {
+		app.httpServer.Close()
+		return fmt.Errorf("scim.app.Stop: %w", err)
+	}
+
+	// the listener is not closed by the server if it was stopped before it served
+	if app.listener != nil {
+		app.listener.Close()
+	}
+
+	return nil
+}
+
+func New(log *slog.Logger, scimService *scimService.ScimService, port int, token string) *App {
+	httpServer := &http.Server{
+		Addr:              fmt.Sprintf(":%d", port),
+		Handler:           scimServer.NewHandler(scimService, log, token),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	return &App{log: log, httpServer: httpServer, port: port}
+}
@@ -0,0 +1,77 @@
+package webhookApp
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sso_go_grpc/internal/config"
+	webhookService "sso_go_grpc/internal/services/webhook"
+	"sso_go_grpc/internal/storage/postgres/outbox"
+	"sync/atomic"
+)
+
+type App struct {
+	log        *slog.Logger
+	dispatcher *webhookService.Dispatcher
+	cfg        config.WebhookConfig
+
+	// cancel stops the dispatcher, done is closed once it stopped; started is set by Start and Run
+	ctx     context.Context
+	cancel  context.CancelFunc
+	done    chan struct{}
+	started atomic.Bool
+}
+
+// Start runs the webhook dispatcher in the background, a Stop right after Start waits for it
+func (app *App) Start() {
+	app.started.Store(true)
+	go app.Run()
+}
+
+// Run this method runs the webhook dispatcher until Stop is called
+func (app *App) Run() {
+	const op = "webhook.app.Run"
+
+	//setup logger for this function
+	log := app.log.With(slog.String("op", op))
+
+	app.started.Store(true)
+	defer close(app.done)
+
+	log.Info("Starting Webhook Dispatcher", "pollInterval", app.cfg.PollInterval)
+
+	app.dispatcher.Run(app.ctx)
+
+	log.Info("Webhook Dispatcher stopped")
+}
+
+// Stop stops the dispatcher and waits until the running round ended or the context is done;
+// deliveries canceled by the stop are retried after their lease
+func (app *App) Stop(ctx context.Context) error {
+	app.cancel()
+
+	// a dispatcher that did not run yet returns right away with the canceled context
+	if !app.started.Load() {
+		return nil
+	}
+
+	select {
+	case <-app.done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("webhook.app.Stop: %w", ctx.Err())
+	}
+}
+
+func New(log *slog.Logger, cfg config.WebhookConfig, outboxProvider *outbox.Storage) *App {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &App{
+		log:        log,
+		dispatcher: webhookService.NewDispatcher(cfg, log, outboxProvider),
+		cfg:        cfg,
+		ctx:        ctx,
+		cancel:     cancel,
+		done:       make(chan struct{}),
+	}
+}
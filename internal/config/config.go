@@ -6,18 +6,127 @@ import (
 	"fmt"
 	"github.com/ilyakaznacheev/cleanenv"
 	"os"
+	"time"
 )
 
 type GrpcConfig struct {
-	Port    int    `yaml:"port" env-required`
+	Port    int    `yaml:"port" env-required:"true"`
 	Timeout string `yaml:"timeout" env-default:"12h"`
+	// Reflection lets clients like grpcurl discover the services
+	Reflection bool `yaml:"reflection" env-default:"false"`
+	// HealthInterval is the interval of the readiness checks reported by grpc.health.v1
+	HealthInterval time.Duration     `yaml:"health_interval" env-default:"5s"`
+	TLS            TLSConfig         `yaml:"tls"`
+	Idempotency    IdempotencyConfig `yaml:"idempotency"`
 }
+
+// IdempotencyConfig configures the replay of mutating RPCs sent with an idempotency-key
+type IdempotencyConfig struct {
+	// Window is how long the first response of a key is stored and replayed
+	Window time.Duration `yaml:"window" env-default:"24h"`
+	// LockTimeout is how long a running request holds its key, after that a crashed request does not block the retries
+	LockTimeout time.Duration `yaml:"lock_timeout" env-default:"1m"`
+}
+
+// TLSConfig configures TLS of the gRPC listener, the files are reloaded when they change
+type TLSConfig struct {
+	Enabled    bool   `yaml:"enabled" env-default:"false"`
+	CertFile   string `yaml:"cert_file" env:"GRPC_TLS_CERT_FILE"`
+	KeyFile    string `yaml:"key_file" env:"GRPC_TLS_KEY_FILE"`
+	MinVersion string `yaml:"min_version" env-default:"1.2"`
+	// CipherSuites are the allowed tls 1.2 cipher suites by name, empty uses the go defaults
+	CipherSuites []string `yaml:"cipher_suites"`
+	// ClientAuth is the mTLS policy: none, optional (verified if given) or require,
+	// optional and require need the ClientCAFile the client certificates are verified against
+	ClientAuth   string `yaml:"client_auth" env-default:"none"`
+	ClientCAFile string `yaml:"client_ca_file" env:"GRPC_TLS_CLIENT_CA_FILE"`
+}
+
+// ScimConfig configures the SCIM 2.0 provisioning server, it only runs if it is enabled
+type ScimConfig struct {
+	Enabled bool   `yaml:"enabled" env-default:"false"`
+	Port    int    `yaml:"port" env-default:"9801"`
+	Token   string `yaml:"token" env:"SCIM_TOKEN"`
+}
+
+// GatewayConfig configures the REST/JSON gateway in front of the gRPC server, it only runs if it is enabled
+type GatewayConfig struct {
+	Enabled bool `yaml:"enabled" env-default:"false"`
+	Port    int  `yaml:"port" env-default:"9802"`
+	// CorsOrigins are the browser origins allowed to call the gateway, "*" allows every origin
+	CorsOrigins []string      `yaml:"cors_origins" env:"GATEWAY_CORS_ORIGINS"`
+	CorsMaxAge  time.Duration `yaml:"cors_max_age" env-default:"10m"`
+	// GrpcTLS is used to connect to the gRPC server if its TLS is enabled
+	GrpcTLS GatewayTLSConfig `yaml:"grpc_tls"`
+}
+
+// GatewayTLSConfig configures the connection of the gateway to the gRPC server,
+// CertFile and KeyFile are the client certificate for mTLS
+type GatewayTLSConfig struct {
+	// CAFile verifies the server certificate, empty uses the system roots
+	CAFile     string `yaml:"ca_file"`
+	CertFile   string `yaml:"cert_file"`
+	KeyFile    string `yaml:"key_file"`
+	ServerName string `yaml:"server_name" env-default:"localhost"`
+}
+
+// AuditConfig configures the hash chain of the audit log,
+// HmacKey is optional and CheckpointKey is only needed to sign checkpoints
+type AuditConfig struct {
+	HmacKey string `yaml:"hmac_key" env:"AUDIT_HMAC_KEY"`
+	// base64 encoded ed25519 seed
+	CheckpointKey string `yaml:"checkpoint_key" env:"AUDIT_CHECKPOINT_KEY"`
+}
+
+// WebhookConfig configures the dispatcher delivering the outbox events to the webhook subscriptions
+type WebhookConfig struct {
+	Enabled      bool          `yaml:"enabled" env-default:"true"`
+	PollInterval time.Duration `yaml:"poll_interval" env-default:"2s"`
+	BatchSize    int           `yaml:"batch_size" env-default:"100"`
+	Timeout      time.Duration `yaml:"timeout" env-default:"10s"`
+	// a delivery is dead after MaxAttempts failed attempts
+	MaxAttempts uint32        `yaml:"max_attempts" env-default:"8"`
+	BaseBackoff time.Duration `yaml:"base_backoff" env-default:"30s"`
+	MaxBackoff  time.Duration `yaml:"max_backoff" env-default:"6h"`
+}
+
+// TracingConfig configures the export of OpenTelemetry traces over OTLP/gRPC,
+// the W3C trace context of incoming requests is propagated even if the export is disabled
+type TracingConfig struct {
+	Enabled     bool   `yaml:"enabled" env-default:"false"`
+	Endpoint    string `yaml:"endpoint" env:"OTEL_EXPORTER_OTLP_ENDPOINT" env-default:"localhost:4317"`
+	Insecure    bool   `yaml:"insecure" env-default:"false"`
+	ServiceName string `yaml:"service_name" env:"OTEL_SERVICE_NAME" env-default:"sso"`
+	// ratio of the traces started here that are sampled, traces of sampled parents are always sampled
+	SampleRatio float64 `yaml:"sample_ratio" env-default:"1"`
+}
+
+// AdminConfig configures the admin http server serving the prometheus metrics, it only runs if it is enabled
+type AdminConfig struct {
+	Enabled bool `yaml:"enabled" env-default:"false"`
+	Port    int  `yaml:"port" env-default:"9803"`
+}
+
 type Config struct {
-	Env       string `yaml:"env" env-required`
-	DbLink    string `yaml:"db_link" env-required`
-	DbType    string `yaml:"db_type" env-required`
-	JwtSecret string `yaml:"jwt_secret" env-required`
+	Env       string `yaml:"env" env-required:"true"`
+	DbLink    string `yaml:"db_link" env-required:"true"`
+	DbType    string `yaml:"db_type" env-required:"true"`
+	JwtSecret string `yaml:"jwt_secret" env-required:"true"`
+	AdminRole string `yaml:"admin_role" env-default:"admin"`
 	GRPC      GrpcConfig
+	Scim      ScimConfig
+	Gateway   GatewayConfig
+	Audit     AuditConfig
+	Webhooks  WebhookConfig
+	Tracing   TracingConfig
+	Admin     AdminConfig
+
+	// JwtIssuer and JwtAudience are the iss and aud claims of the tokens, checked by the services verifying them
+	JwtIssuer   string `yaml:"jwt_issuer" env-default:"sso"`
+	JwtAudience string `yaml:"jwt_audience" env-default:"sso"`
+
+	// ShutdownTimeout is the time the running requests get to finish on SIGINT / SIGTERM
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout" env-default:"20s"`
 }
 
 // MustLoad returns a config by config path which was gotten from getConfigPath
@@ -31,7 +140,7 @@ func MustLoad() *Config {
 		if errors.Is(err, os.ErrNotExist) {
 			panic(fmt.Sprintf(`%s: Config dont exist on %s`, op, configPath))
 		} else {
-			panic(fmt.Sprintf("%s: %w", op, err))
+			panic(fmt.Errorf("%s: %w", op, err))
 		}
 	}
 
@@ -0,0 +1,18 @@
+package models
+
+const (
+	AttributeTypeString  = "string"
+	AttributeTypeNumber  = "number"
+	AttributeTypeBoolean = "boolean"
+)
+
+// AttributeDefinition is the admin defined schema of one custom user attribute
+type AttributeDefinition struct {
+	Name         string
+	Type         string
+	Required     bool
+	Enum         []string
+	UserWritable bool
+	Claim        string
+	Description  string
+}
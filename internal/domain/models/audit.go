@@ -0,0 +1,68 @@
+package models
+
+import "time"
+
+const (
+	AuditActionRoleCreate     = "role.create"
+	AuditActionRoleUpdate     = "role.update"
+	AuditActionRoleDelete     = "role.delete"
+	AuditActionRoleSetMembers = "role.setMembers"
+	AuditActionUserRoleAdd    = "user.role.add"
+	AuditActionUserRoleRemove = "user.role.remove"
+
+	AuditTargetRole = "role"
+	AuditTargetUser = "user"
+)
+
+// AuditEntry is one administrative change, ActorId is 0 for changes of the SCIM client or of callers without token;
+// Before and After are the json encodable states of the target, nil if there is none
+type AuditEntry struct {
+	Id         uint64
+	ActorId    uint64
+	Action     string
+	TargetType string
+	TargetId   uint64
+	Before     any
+	After      any
+	RequestId  string
+	IP         string
+	CreatedAt  time.Time
+	// PrevHash and Hash chain the entry to the previous one, empty for entries from before the chain
+	PrevHash string
+	Hash     string
+}
+
+// AuditFilter contains the filters of the audit log query, zero values are ignored
+type AuditFilter struct {
+	ActorId    *uint64
+	Action     string
+	TargetType string
+	TargetId   uint64
+	From       time.Time
+	To         time.Time
+
+	// BeforeId is the cursor, only entries older than it are returned
+	BeforeId uint64
+	Limit    int
+}
+
+// AuditChainVerification is the result of walking the hash chain of the audit log,
+// BrokenAt is the id of the first entry whose link is broken, 0 if the chain is intact
+type AuditChainVerification struct {
+	Entries  uint64
+	LastId   uint64
+	LastHash string
+	BrokenAt uint64
+	Reason   string
+}
+
+// AuditCheckpoint is a signed statement of the head of the audit chain, a later verification
+// proves that the entries up to LastId were not changed or removed since the checkpoint
+type AuditCheckpoint struct {
+	Entries   uint64    `json:"entries"`
+	LastId    uint64    `json:"lastId"`
+	LastHash  string    `json:"lastHash"`
+	CreatedAt time.Time `json:"createdAt"`
+	PublicKey string    `json:"publicKey"`
+	Signature string    `json:"signature"`
+}
@@ -0,0 +1,86 @@
+package models
+
+import "time"
+
+const (
+	EventUserCreated  = "user.created"
+	EventUserUpdated  = "user.updated"
+	EventRoleAssigned = "role.assigned"
+	EventRoleRevoked  = "role.revoked"
+	EventRoleDeleted  = "role.deleted"
+
+	DeliveryStatusPending   = "pending"
+	DeliveryStatusDelivered = "delivered"
+	DeliveryStatusDead      = "dead"
+)
+
+// EventTypes are all event types a webhook can subscribe to
+var EventTypes = []string{EventUserCreated, EventUserUpdated, EventRoleAssigned, EventRoleRevoked, EventRoleDeleted}
+
+// Event is a domain event of the outbox, Data is its json encodable payload;
+// Position is the commit order of the event, the resume tokens of the streams continue after it
+type Event struct {
+	Id        uint64    `json:"id"`
+	Position  uint64    `json:"-"`
+	Type      string    `json:"type"`
+	Data      any       `json:"data"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// UserEventData is the payload of user.created and user.updated,
+// Changed names the changed fields of an update
+type UserEventData struct {
+	UserId   uint64   `json:"userId"`
+	Email    string   `json:"email"`
+	Username string   `json:"username"`
+	Status   string   `json:"status"`
+	Changed  []string `json:"changed,omitempty"`
+}
+
+// RoleEventData is the payload of role.assigned, role.revoked and role.deleted,
+// UserIds are the users that had the role when it was deleted
+type RoleEventData struct {
+	RoleId   uint64   `json:"roleId"`
+	RoleName string   `json:"roleName"`
+	UserId   uint64   `json:"userId,omitempty"`
+	UserIds  []uint64 `json:"userIds,omitempty"`
+}
+
+// WebhookSubscription receives the events of its EventTypes, all events if EventTypes is empty;
+// Secret signs the deliveries and is only returned on creation
+type WebhookSubscription struct {
+	Id         uint64
+	Url        string
+	Secret     string
+	EventTypes []string
+	Active     bool
+	CreatedAt  time.Time
+}
+
+// WebhookDelivery is the delivery of one event to one subscription
+type WebhookDelivery struct {
+	Id             uint64
+	SubscriptionId uint64
+	Event          *Event
+	Status         string
+	Attempts       uint32
+	NextAttemptAt  time.Time
+	LastError      string
+	ResponseStatus int
+	DeliveredAt    time.Time
+	CreatedAt      time.Time
+
+	// Url and Secret of the subscription, only set for deliveries claimed by the dispatcher
+	Url    string
+	Secret string
+}
+
+// DeliveryFilter contains the filters of the delivery listing, zero values are ignored
+type DeliveryFilter struct {
+	SubscriptionId uint64
+	Status         string
+
+	// BeforeId is the cursor, only deliveries older than it are returned
+	BeforeId uint64
+	Limit    int
+}
@@ -0,0 +1,48 @@
+package models
+
+import "time"
+
+// UserExportSessionsNote is the note of the export about sessions
+const UserExportSessionsNote = "sessions: tokens are stateless signed JWTs, no sessions or tokens are stored, so there are none to export"
+
+// UserExport is everything the SSO holds about one user, the password hash is never part of it;
+// tokens are stateless, so there are no sessions to export, Notes says so in the document
+type UserExport struct {
+	ExportedAt   time.Time               `json:"exportedAt"`
+	User         UserExportProfile       `json:"user"`
+	Roles        []*RoleAssignment       `json:"roles"`
+	Logins       []*LoginAttempt         `json:"logins"`
+	AuditEntries []*UserExportAuditEntry `json:"auditEntries"`
+	Notes        []string                `json:"notes"`
+}
+
+type UserExportProfile struct {
+	UserId      uint64         `json:"userId"`
+	Email       string         `json:"email"`
+	Username    string         `json:"username"`
+	Status      string         `json:"status"`
+	CreatedAt   time.Time      `json:"createdAt"`
+	LastLoginAt *time.Time     `json:"lastLoginAt"`
+	Attributes  map[string]any `json:"attributes"`
+}
+
+// RoleAssignment is a role of a user with the time it was assigned
+type RoleAssignment struct {
+	RoleId      uint64    `json:"roleId"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	AssignedAt  time.Time `json:"assignedAt"`
+}
+
+// UserExportAuditEntry is an audit entry in which the user is the actor or the target
+type UserExportAuditEntry struct {
+	Id         uint64    `json:"id"`
+	ActorId    uint64    `json:"actorId"`
+	Action     string    `json:"action"`
+	TargetType string    `json:"targetType"`
+	TargetId   uint64    `json:"targetId"`
+	Before     any       `json:"before,omitempty"`
+	After      any       `json:"after,omitempty"`
+	IP         string    `json:"ip,omitempty"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
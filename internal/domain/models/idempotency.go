@@ -0,0 +1,12 @@
+package models
+
+// IdempotentRequest is a mutating request sent with an idempotency key, Key is the hash of the idempotency key,
+// Scope is the caller, empty for requests without token, RequestHash is the keyed hash of the payload
+// and Response the encrypted response of the first request, nil while it is running
+type IdempotentRequest struct {
+	Scope       string
+	Key         string
+	Method      string
+	RequestHash []byte
+	Response    []byte
+}
@@ -0,0 +1,17 @@
+package models
+
+// ImportUser is one user of a bulk import, PasswordHash is an existing bcrypt hash
+type ImportUser struct {
+	Email        string   `json:"email"`
+	Username     string   `json:"username"`
+	PasswordHash string   `json:"passwordHash"`
+	Roles        []string `json:"roles"`
+}
+
+// ImportResult is the result of one row of a bulk import, Error is empty on success
+type ImportResult struct {
+	Row    uint64 `json:"row"`
+	Email  string `json:"email"`
+	UserId uint64 `json:"userId"`
+	Error  string `json:"error,omitempty"`
+}
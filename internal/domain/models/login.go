@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// LoginAttempt is one successful or failed login, UserId is 0 if there is no user with the email
+type LoginAttempt struct {
+	Id        uint64    `json:"id"`
+	UserId    uint64    `json:"userId"`
+	Email     string    `json:"email"`
+	Success   bool      `json:"success"`
+	IP        string    `json:"ip"`
+	UserAgent string    `json:"userAgent"`
+	CreatedAt time.Time `json:"createdAt"`
+}
@@ -1,7 +1,17 @@
 package models
 
 type Role struct {
-	Id          uint64
-	Name        string
-	Description string
+	Id          uint64 `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	// Version is incremented by every change of the role
+	Version uint64 `json:"version"`
+}
+
+// RoleUpdate are the fields of a role to update, nil fields are not changed
+type RoleUpdate struct {
+	Name        *string
+	Description *string
+	// ExpectedVersion fails the update with storage.ErrVersionMismatch if the role has another version, 0 does not check
+	ExpectedVersion uint64
 }
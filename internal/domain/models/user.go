@@ -1,9 +1,45 @@
 package models
 
+import "time"
+
+const (
+	UserStatusActive   = "active"
+	UserStatusDisabled = "disabled"
+)
+
 type User struct {
-	Email    string
-	Password string
-	UserId   uint64
+	Email     string
+	Password  string
+	UserId    uint64
+	Username  string
+	Status    string
+	CreatedAt time.Time
+	// LastLoginAt is zero if the user never logged in
+	LastLoginAt time.Time
+	Roles       []*Role
+	// Attributes are the custom attributes described by the AttributeDefinitions
+	Attributes map[string]any
+	// Version is incremented by every change of the user, not by logins and role assignments
+	Version uint64
+}
+
+// UserFilter describes which users should be returned by a listing,
+// empty fields are not filtered by
+type UserFilter struct {
+	// AfterId is the cursor, only users with a bigger id are returned
+	AfterId       uint64
+	Limit         int
+	Offset        int
+	RoleIds       []uint64
+	Status        string
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+	EmailDomain   string
+	Search        string
+	PrefixSearch  bool
+	// Username and Email are exact, case-insensitive matches
 	Username string
-	Roles    []*Role
+	Email    string
+	// Attributes have to be contained in the attributes of the user
+	Attributes map[string]any
 }
@@ -0,0 +1,92 @@
+package attributeServer
+
+import (
+	"context"
+	"google.golang.org/grpc"
+	"sso_go_grpc/internal/domain/models"
+	"sso_go_grpc/internal/grpc/grpcerr"
+	attributeService "sso_go_grpc/internal/services/attribute"
+	sso "sso_go_grpc/proto/gen"
+)
+
+type serverApi struct {
+	attributeService *attributeService.AttributeService
+	sso.UnimplementedAttributeApiServer
+}
+
+func RegisterServer(Grpc *grpc.Server, attributeService *attributeService.AttributeService) {
+	sso.RegisterAttributeApiServer(Grpc, &serverApi{attributeService: attributeService})
+}
+
+// types maps the proto attribute types to the model types
+var types = map[sso.AttributeType]string{
+	sso.AttributeType_ATTRIBUTE_TYPE_STRING:  models.AttributeTypeString,
+	sso.AttributeType_ATTRIBUTE_TYPE_NUMBER:  models.AttributeTypeNumber,
+	sso.AttributeType_ATTRIBUTE_TYPE_BOOLEAN: models.AttributeTypeBoolean,
+}
+
+func (s *serverApi) SetAttributeDefinition(ctx context.Context, req *sso.SetAttributeDefinitionRequest) (res *sso.SetAttributeDefinitionResponse, err error) {
+	definition, err := s.attributeService.SetDefinition(ctx, req.GetToken(), fromProtoDefinition(req.GetDefinition()))
+
+	if err != nil {
+		return nil, grpcerr.Status(err)
+	}
+
+	return &sso.SetAttributeDefinitionResponse{Definition: toProtoDefinition(definition)}, nil
+}
+
+func (s *serverApi) DeleteAttributeDefinition(ctx context.Context, req *sso.DeleteAttributeDefinitionRequest) (res *sso.DeleteAttributeDefinitionResponse, err error) {
+	err = s.attributeService.DeleteDefinition(ctx, req.GetToken(), req.GetName())
+
+	if err != nil {
+		return nil, grpcerr.Status(err)
+	}
+
+	return &sso.DeleteAttributeDefinitionResponse{Message: "Successfully Deleted the Attribute Definition"}, nil
+}
+
+func (s *serverApi) ListAttributeDefinitions(ctx context.Context, req *sso.ListAttributeDefinitionsRequest) (res *sso.ListAttributeDefinitionsResponse, err error) {
+	definitions, err := s.attributeService.ListDefinitions(ctx, req.GetToken())
+
+	if err != nil {
+		return nil, grpcerr.Status(err)
+	}
+
+	var protoDefinitions []*sso.AttributeDefinition
+	for _, definition := range definitions {
+		protoDefinitions = append(protoDefinitions, toProtoDefinition(definition))
+	}
+
+	return &sso.ListAttributeDefinitionsResponse{Definitions: protoDefinitions}, nil
+}
+
+func fromProtoDefinition(definition *sso.AttributeDefinition) *models.AttributeDefinition {
+	return &models.AttributeDefinition{
+		Name:         definition.GetName(),
+		Type:         types[definition.GetType()],
+		Required:     definition.GetRequired(),
+		Enum:         definition.GetEnum(),
+		UserWritable: definition.GetUserWritable(),
+		Claim:        definition.GetClaim(),
+		Description:  definition.GetDescription(),
+	}
+}
+
+func toProtoDefinition(definition *models.AttributeDefinition) *sso.AttributeDefinition {
+	protoDefinition := &sso.AttributeDefinition{
+		Name:         definition.Name,
+		Required:     definition.Required,
+		Enum:         definition.Enum,
+		UserWritable: definition.UserWritable,
+		Claim:        definition.Claim,
+		Description:  definition.Description,
+	}
+
+	for protoType, modelType := range types {
+		if modelType == definition.Type {
+			protoDefinition.Type = protoType
+		}
+	}
+
+	return protoDefinition
+}
@@ -0,0 +1,75 @@
+package auditServer
+
+import (
+	"context"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"sso_go_grpc/internal/domain/models"
+	"sso_go_grpc/internal/grpc/grpcerr"
+	auditService "sso_go_grpc/internal/services/audit"
+	sso "sso_go_grpc/proto/gen"
+)
+
+type serverApi struct {
+	auditService *auditService.AuditService
+	sso.UnimplementedAuditApiServer
+}
+
+func RegisterServer(Grpc *grpc.Server, auditService *auditService.AuditService) {
+	sso.RegisterAuditApiServer(Grpc, &serverApi{auditService: auditService})
+}
+
+func (s *serverApi) QueryAuditLog(ctx context.Context, req *sso.QueryAuditLogRequest) (res *sso.QueryAuditLogResponse, err error) {
+	filter := models.AuditFilter{
+		ActorId:    req.ActorId,
+		Action:     req.GetAction(),
+		TargetType: req.GetTargetType(),
+		TargetId:   req.GetTargetId(),
+	}
+
+	if req.GetFrom() != nil {
+		filter.From = req.GetFrom().AsTime()
+	}
+	if req.GetTo() != nil {
+		filter.To = req.GetTo().AsTime()
+	}
+
+	entries, nextPageToken, err := s.auditService.QueryAuditLog(ctx, req.GetToken(), filter, req.GetPageToken(), req.GetPageSize())
+
+	if err != nil {
+		return nil, grpcerr.Status(err)
+	}
+
+	res = &sso.QueryAuditLogResponse{NextPageToken: nextPageToken}
+	for _, entry := range entries {
+		res.Entries = append(res.Entries, toProtoEntry(entry))
+	}
+
+	return res, nil
+}
+
+func toProtoEntry(entry *models.AuditEntry) *sso.AuditEntry {
+	protoEntry := &sso.AuditEntry{
+		Id:         entry.Id,
+		ActorId:    entry.ActorId,
+		Action:     entry.Action,
+		TargetType: entry.TargetType,
+		TargetId:   entry.TargetId,
+		RequestId:  entry.RequestId,
+		Ip:         entry.IP,
+		CreatedAt:  timestamppb.New(entry.CreatedAt),
+		PrevHash:   entry.PrevHash,
+		Hash:       entry.Hash,
+	}
+
+	// the states are decoded from json, so they are always convertible
+	if entry.Before != nil {
+		protoEntry.Before, _ = structpb.NewValue(entry.Before)
+	}
+	if entry.After != nil {
+		protoEntry.After, _ = structpb.NewValue(entry.After)
+	}
+
+	return protoEntry
+}
@@ -0,0 +1,48 @@
+package fieldmask
+
+import (
+	"fmt"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+	"slices"
+	"sso_go_grpc/internal/grpc/grpcerr"
+)
+
+// Field is the name of the update mask field of the update requests
+const Field = "updateMask"
+
+// Paths returns the fields of the request to update: the paths of the mask, or without mask the updatable fields
+// that are set in the request. Paths that are not updatable are rejected with InvalidArgument
+func Paths(mask *fieldmaskpb.FieldMask, req proto.Message, updatable ...string) ([]string, error) {
+	if len(mask.GetPaths()) == 0 {
+		return populated(req, updatable), nil
+	}
+
+	paths := make([]string, 0, len(mask.GetPaths()))
+
+	for _, path := range mask.GetPaths() {
+		if !slices.Contains(updatable, path) {
+			return nil, grpcerr.InvalidArgument(Field, fmt.Sprintf("%s can not be updated, updatable are %v", path, updatable))
+		}
+		if !slices.Contains(paths, path) {
+			paths = append(paths, path)
+		}
+	}
+
+	return paths, nil
+}
+
+// populated returns the updatable fields that are set in the request
+func populated(req proto.Message, updatable []string) []string {
+	message := req.ProtoReflect()
+	fields := message.Descriptor().Fields()
+
+	var paths []string
+	for _, name := range updatable {
+		if field := fields.ByJSONName(name); field != nil && message.Has(field) {
+			paths = append(paths, name)
+		}
+	}
+
+	return paths
+}
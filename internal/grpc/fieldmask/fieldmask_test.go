@@ -0,0 +1,60 @@
+package fieldmask
+
+import (
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+	"slices"
+	sso "sso_go_grpc/proto/gen"
+	"testing"
+)
+
+func TestPaths(t *testing.T) {
+	tests := []struct {
+		name     string
+		mask     []string
+		req      *sso.UpdateRoleRequest
+		want     []string
+		wantCode codes.Code
+	}{
+		{name: "no mask and nothing set", req: &sso.UpdateRoleRequest{RoleId: 1}},
+		{name: "no mask and name set", req: &sso.UpdateRoleRequest{RoleId: 1, Name: "writer"}, want: []string{"name"}},
+		{
+			name: "no mask and both set",
+			req:  &sso.UpdateRoleRequest{RoleId: 1, Name: "writer", Description: "writes"},
+			want: []string{"name", "description"},
+		},
+		{name: "mask empties an unset field", mask: []string{"description"}, req: &sso.UpdateRoleRequest{RoleId: 1}, want: []string{"description"}},
+		{
+			name: "mask limits the set fields",
+			mask: []string{"name"},
+			req:  &sso.UpdateRoleRequest{RoleId: 1, Name: "writer", Description: "writes"},
+			want: []string{"name"},
+		},
+		{
+			name: "duplicate paths",
+			mask: []string{"description", "name", "description"},
+			req:  &sso.UpdateRoleRequest{RoleId: 1},
+			want: []string{"description", "name"},
+		},
+		{name: "not updatable path", mask: []string{"roleId"}, req: &sso.UpdateRoleRequest{RoleId: 1}, wantCode: codes.InvalidArgument},
+		{name: "unknown path", mask: []string{"color"}, req: &sso.UpdateRoleRequest{RoleId: 1}, wantCode: codes.InvalidArgument},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mask *fieldmaskpb.FieldMask
+			if tt.mask != nil {
+				mask = &fieldmaskpb.FieldMask{Paths: tt.mask}
+			}
+
+			got, err := Paths(mask, tt.req, "name", "description")
+			if code := status.Code(err); code != tt.wantCode {
+				t.Fatalf("Paths() error = %v, want code %s", err, tt.wantCode)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Paths() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
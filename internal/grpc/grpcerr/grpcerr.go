@@ -0,0 +1,160 @@
+package grpcerr
+
+import (
+	"context"
+	"errors"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/runtime/protoiface"
+	"sso_go_grpc/internal/storage"
+	"strings"
+)
+
+// Domain is the domain of the ErrorInfo details
+const Domain = "sso"
+
+// ReasonInternal is the reason of all errors without a mapping, their message is never returned
+const ReasonInternal = "INTERNAL"
+
+// mapping maps a storage error to its status code and stable reason,
+// field is the request field a BadRequest violation is attached for, empty for none
+type mapping struct {
+	err    error
+	code   codes.Code
+	reason string
+	field  string
+}
+
+var mappings = []mapping{
+	{storage.ErrInvalidToken, codes.Unauthenticated, "INVALID_TOKEN", ""},
+	{storage.ErrAuth, codes.Unauthenticated, "INVALID_CREDENTIALS", ""},
+	{storage.ErrNoPermission, codes.PermissionDenied, "PERMISSION_DENIED", ""},
+
+	{storage.ErrUserExists, codes.AlreadyExists, "USER_EXISTS", ""},
+	{storage.ErrUserNotExists, codes.NotFound, "USER_NOT_FOUND", ""},
+	{storage.ErrRoleExists, codes.AlreadyExists, "ROLE_EXISTS", "name"},
+	{storage.ErrRoleNotExists, codes.NotFound, "ROLE_NOT_FOUND", ""},
+	{storage.ErrUserAndRoleIvalid, codes.NotFound, "USER_OR_ROLE_NOT_FOUND", ""},
+	{storage.ErrUserAlreadyHasTHeRole, codes.AlreadyExists, "ROLE_ALREADY_ASSIGNED", ""},
+	{storage.ErrUserDontHaveTheRole, codes.NotFound, "ROLE_NOT_ASSIGNED", ""},
+	{storage.ErrNoDelete, codes.NotFound, "NOTHING_TO_DELETE", ""},
+	{storage.ErrEmptyValue, codes.InvalidArgument, "EMPTY_VALUE", ""},
+	{storage.ErrVersionMismatch, codes.Aborted, "VERSION_MISMATCH", "expectedVersion"},
+
+	{storage.ErrInvalidPassword, codes.InvalidArgument, "INVALID_PASSWORD", "password"},
+	{storage.ErrInvalidUsername, codes.InvalidArgument, "INVALID_USERNAME", "username"},
+	{storage.ErrInvalidPageToken, codes.InvalidArgument, "INVALID_PAGE_TOKEN", "pageToken"},
+	{storage.ErrInvalidImportUser, codes.InvalidArgument, "INVALID_IMPORT_USER", "user"},
+	{storage.ErrInvalidEmail, codes.InvalidArgument, "INVALID_EMAIL", "user.email"},
+	{storage.ErrInvalidUsernameLength, codes.InvalidArgument, "INVALID_USERNAME", "user.username"},
+	{storage.ErrInvalidPasswordHash, codes.InvalidArgument, "INVALID_PASSWORD_HASH", "user.passwordHash"},
+	{storage.ErrImportTooLarge, codes.InvalidArgument, "IMPORT_TOO_LARGE", "user"},
+
+	{storage.ErrAttributeNotExists, codes.NotFound, "ATTRIBUTE_NOT_FOUND", ""},
+	{storage.ErrInvalidAttributes, codes.InvalidArgument, "INVALID_ATTRIBUTES", "attributes"},
+	{storage.ErrInvalidDefinition, codes.InvalidArgument, "INVALID_ATTRIBUTE_DEFINITION", "definition"},
+
+	{storage.ErrAuditEntryNotExists, codes.NotFound, "AUDIT_ENTRY_NOT_FOUND", ""},
+	{storage.ErrAuditChainBroken, codes.DataLoss, "AUDIT_CHAIN_BROKEN", ""},
+	{storage.ErrNoCheckpointKey, codes.FailedPrecondition, "NO_CHECKPOINT_KEY", ""},
+
+	{storage.ErrSubscriptionNotExists, codes.NotFound, "SUBSCRIPTION_NOT_FOUND", ""},
+	{storage.ErrDeliveryNotExists, codes.NotFound, "DELIVERY_NOT_FOUND", ""},
+	{storage.ErrInvalidSubscription, codes.InvalidArgument, "INVALID_SUBSCRIPTION", ""},
+	{storage.ErrInvalidResumeToken, codes.InvalidArgument, "INVALID_RESUME_TOKEN", "resumeToken"},
+	{storage.ErrInvalidEventType, codes.InvalidArgument, "INVALID_EVENT_TYPE", "eventTypes"},
+
+	{storage.ErrIdempotencyKeyInUse, codes.Aborted, "IDEMPOTENCY_KEY_IN_USE", ""},
+	{storage.ErrIdempotencyKeyReused, codes.FailedPrecondition, "IDEMPOTENCY_KEY_REUSED", ""},
+
+	{storage.ErrShuttingDown, codes.Unavailable, "SHUTTING_DOWN", ""},
+}
+
+// Status returns the status error of err: the storage errors get their code and message,
+// an ErrorInfo with their reason and a BadRequest if they are about a request field;
+// status errors are returned as they are, every other error is Internal without its message
+func Status(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return status.FromContextError(err).Err()
+	}
+
+	for _, m := range mappings {
+		if errors.Is(err, m.err) {
+			var violations []*errdetails.BadRequest_FieldViolation
+			if m.field != "" {
+				violations = append(violations, &errdetails.BadRequest_FieldViolation{Field: m.field, Description: err.Error()})
+			}
+			return newStatus(m.code, err.Error(), m.reason, violations)
+		}
+	}
+
+	return newStatus(codes.Internal, "Internal Server Error", ReasonInternal, nil)
+}
+
+// Required returns the InvalidArgument error of missing request fields, with a violation of every field
+func Required(fields ...string) error {
+	violations := make([]*errdetails.BadRequest_FieldViolation, 0, len(fields))
+	for _, field := range fields {
+		violations = append(violations, &errdetails.BadRequest_FieldViolation{Field: field, Description: field + " is required"})
+	}
+
+	return newStatus(codes.InvalidArgument, "Invalid Arguments, expected: "+strings.Join(fields, ", "), "MISSING_FIELDS", violations)
+}
+
+// InvalidArgument returns the InvalidArgument error of one invalid request field
+func InvalidArgument(field, description string) error {
+	violations := []*errdetails.BadRequest_FieldViolation{{Field: field, Description: description}}
+
+	return newStatus(codes.InvalidArgument, description, "INVALID_ARGUMENT", violations)
+}
+
+// FieldViolations returns the InvalidArgument error of a request with invalid fields
+func FieldViolations(violations []*errdetails.BadRequest_FieldViolation) error {
+	messages := make([]string, 0, len(violations))
+	for _, violation := range violations {
+		messages = append(messages, violation.GetField()+": "+violation.GetDescription())
+	}
+
+	return newStatus(codes.InvalidArgument, "Invalid Arguments: "+strings.Join(messages, "; "), "INVALID_ARGUMENT", violations)
+}
+
+// Reason returns the ErrorInfo reason of the status error, empty if it has none
+func Reason(err error) string {
+	st, ok := status.FromError(err)
+	if !ok {
+		return ""
+	}
+
+	for _, detail := range st.Details() {
+		if info, ok := detail.(*errdetails.ErrorInfo); ok {
+			return info.GetReason()
+		}
+	}
+
+	return ""
+}
+
+func newStatus(code codes.Code, message, reason string, violations []*errdetails.BadRequest_FieldViolation) error {
+	st := status.New(code, message)
+
+	details := []protoiface.MessageV1{&errdetails.ErrorInfo{Reason: reason, Domain: Domain}}
+	if len(violations) > 0 {
+		details = append(details, &errdetails.BadRequest{FieldViolations: violations})
+	}
+
+	withDetails, err := st.WithDetails(details...)
+	if err != nil {
+		return st.Err()
+	}
+
+	return withDetails.Err()
+}
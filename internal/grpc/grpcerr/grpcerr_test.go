@@ -0,0 +1,128 @@
+package grpcerr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"sso_go_grpc/internal/storage"
+	"testing"
+)
+
+// violationFields returns the fields of the BadRequest violations of the status error
+func violationFields(err error) []string {
+	var fields []string
+	for _, detail := range status.Convert(err).Details() {
+		if badRequest, ok := detail.(*errdetails.BadRequest); ok {
+			for _, violation := range badRequest.GetFieldViolations() {
+				fields = append(fields, violation.GetField())
+			}
+		}
+	}
+
+	return fields
+}
+
+func TestStatus(t *testing.T) {
+	tests := []struct {
+		name        string
+		err         error
+		wantCode    codes.Code
+		wantMessage string
+		wantReason  string
+		wantField   string
+	}{
+		{
+			name:        "storage error",
+			err:         storage.ErrUserNotExists,
+			wantCode:    codes.NotFound,
+			wantMessage: storage.ErrUserNotExists.Error(),
+			wantReason:  "USER_NOT_FOUND",
+		},
+		{
+			name:        "wrapped storage error with field",
+			err:         fmt.Errorf("storage.role.CreateRole: %w", storage.ErrRoleExists),
+			wantCode:    codes.AlreadyExists,
+			wantMessage: "storage.role.CreateRole: " + storage.ErrRoleExists.Error(),
+			wantReason:  "ROLE_EXISTS",
+			wantField:   "name",
+		},
+		{
+			name:        "version mismatch",
+			err:         storage.ErrVersionMismatch,
+			wantCode:    codes.Aborted,
+			wantMessage: storage.ErrVersionMismatch.Error(),
+			wantReason:  "VERSION_MISMATCH",
+			wantField:   "expectedVersion",
+		},
+		{
+			name:        "status error is kept",
+			err:         status.Error(codes.PermissionDenied, "denied"),
+			wantCode:    codes.PermissionDenied,
+			wantMessage: "denied",
+		},
+		{
+			name:        "canceled context",
+			err:         fmt.Errorf("query: %w", context.Canceled),
+			wantCode:    codes.Canceled,
+			wantMessage: "query: " + context.Canceled.Error(),
+		},
+		{
+			name:        "deadline",
+			err:         context.DeadlineExceeded,
+			wantCode:    codes.DeadlineExceeded,
+			wantMessage: context.DeadlineExceeded.Error(),
+		},
+		{
+			name:        "unknown error hides its message",
+			err:         errors.New("pq: connection refused"),
+			wantCode:    codes.Internal,
+			wantMessage: "Internal Server Error",
+			wantReason:  ReasonInternal,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Status(tt.err)
+
+			st := status.Convert(err)
+			if st.Code() != tt.wantCode {
+				t.Errorf("code = %s, want %s", st.Code(), tt.wantCode)
+			}
+			if st.Message() != tt.wantMessage {
+				t.Errorf("message = %q, want %q", st.Message(), tt.wantMessage)
+			}
+			if reason := Reason(err); reason != tt.wantReason {
+				t.Errorf("reason = %q, want %q", reason, tt.wantReason)
+			}
+
+			fields := violationFields(err)
+			if tt.wantField == "" && len(fields) > 0 || tt.wantField != "" && (len(fields) != 1 || fields[0] != tt.wantField) {
+				t.Errorf("violation fields = %v, want %q", fields, tt.wantField)
+			}
+		})
+	}
+}
+
+func TestStatusNil(t *testing.T) {
+	if err := Status(nil); err != nil {
+		t.Errorf("Status(nil) = %v, want nil", err)
+	}
+}
+
+func TestRequired(t *testing.T) {
+	err := Required("email", "password")
+
+	if code := status.Code(err); code != codes.InvalidArgument {
+		t.Errorf("code = %s, want %s", code, codes.InvalidArgument)
+	}
+	if reason := Reason(err); reason != "MISSING_FIELDS" {
+		t.Errorf("reason = %q, want MISSING_FIELDS", reason)
+	}
+	if fields := violationFields(err); len(fields) != 2 || fields[0] != "email" || fields[1] != "password" {
+		t.Errorf("violation fields = %v, want [email password]", fields)
+	}
+}
@@ -0,0 +1,258 @@
+package interceptors
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"log/slog"
+	"sso_go_grpc/internal/config"
+	"sso_go_grpc/internal/domain/models"
+	"sso_go_grpc/internal/grpc/grpcerr"
+	"sso_go_grpc/internal/lib/client"
+	"sso_go_grpc/internal/lib/jwt"
+	"sso_go_grpc/internal/lib/reqlog"
+	"sso_go_grpc/internal/storage"
+	"sso_go_grpc/internal/storage/postgres/idempotency"
+	"strings"
+)
+
+// ReplayedKey is the response header set to true on responses replayed for an idempotency key
+const ReplayedKey = "idempotent-replayed"
+
+// maxIdempotencyKeyLength is the length of the key column
+const maxIdempotencyKeyLength = 255
+
+// idempotentMethods are the mutating RPCs that accept an idempotency key
+var idempotentMethods = map[string]bool{
+	"/api.UserApi/Register":                       true,
+	"/api.UserApi/UpdateUserAttributes":           true,
+	"/api.RoleApi/CreateRole":                     true,
+	"/api.RoleApi/UpdateRole":                     true,
+	"/api.RoleApi/DeleteRole":                     true,
+	"/api.RoleApi/AddUserRole":                    true,
+	"/api.RoleApi/RemoveUserRole":                 true,
+	"/api.AttributeApi/SetAttributeDefinition":    true,
+	"/api.AttributeApi/DeleteAttributeDefinition": true,
+	"/api.WebhookApi/CreateWebhookSubscription":   true,
+	"/api.WebhookApi/UpdateWebhookSubscription":   true,
+	"/api.WebhookApi/DeleteWebhookSubscription":   true,
+	"/api.WebhookApi/RedeliverWebhook":            true,
+}
+
+// IdempotencyUnary runs a mutating request with an idempotency-key once: its response is stored for the window
+// and returned to the retries with the same key and payload instead of running the request again.
+// Keys are scoped to the user of the token, failed requests are not stored so their retries run again.
+// Responses carry credentials like the token of Register and the secret of a webhook subscription,
+// so only the hash of the key is stored, the response is encrypted with a key derived from it
+// and the payload, which can contain a password, is only stored as keyed hash
+func IdempotencyUnary(store *idempotency.Storage, cfg config.IdempotencyConfig, jwtSecret string, log *slog.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		key := client.Idempotency(ctx)
+		message, ok := req.(proto.Message)
+		if key == "" || !ok || !idempotentMethods[info.FullMethod] {
+			return handler(ctx, req)
+		}
+
+		if len(key) > maxIdempotencyKeyLength {
+			return nil, grpcerr.InvalidArgument(client.IdempotencyKey, fmt.Sprintf("can be at most %d characters long", maxIdempotencyKeyLength))
+		}
+
+		request, err := idempotentRequest(key, info.FullMethod, message, jwtSecret)
+		if err != nil {
+			return nil, grpcerr.Status(err)
+		}
+
+		stored, err := store.Claim(ctx, request, cfg.LockTimeout)
+		if err != nil {
+			return nil, grpcerr.Status(err)
+		}
+		if stored != nil {
+			return replay(ctx, info.FullMethod, request, stored, key, jwtSecret)
+		}
+
+		logger := reqlog.From(ctx, log).With("op", "grpc.interceptors.IdempotencyUnary")
+
+		res, err := handler(ctx, req)
+
+		// the key is released or completed even if the client is gone, so its retry is not blocked
+		storeCtx := context.WithoutCancel(ctx)
+
+		if err != nil {
+			if releaseErr := store.Release(storeCtx, request); releaseErr != nil {
+				logger.Error("Error on releasing idempotency key", "err", releaseErr)
+			}
+			return res, err
+		}
+
+		response, err := proto.Marshal(res.(proto.Message))
+		if err == nil {
+			response, err = seal(response, key, jwtSecret)
+		}
+		if err == nil {
+			err = store.Complete(storeCtx, request, response, cfg.Window)
+		}
+		if err != nil {
+			// the request succeeded, a retry runs it again
+			logger.Error("Error on storing response of idempotency key", "err", err)
+		}
+
+		return res, nil
+	}
+}
+
+// idempotentRequest returns the request of the hashed key with its scope, the user id of a valid token field,
+// and the hash of the payload without the token, so the retries of a refreshed token match
+func idempotentRequest(key, method string, message proto.Message, jwtSecret string) (*models.IdempotentRequest, error) {
+	message = proto.Clone(message)
+	reflectMessage := message.ProtoReflect()
+
+	var scope string
+
+	if field := reflectMessage.Descriptor().Fields().ByName("token"); field != nil && field.Kind() == protoreflect.StringKind {
+		if userId, err := jwt.ParseToken(reflectMessage.Get(field).String(), jwtSecret); err == nil {
+			scope = fmt.Sprintf("user:%d", userId)
+		}
+		reflectMessage.Clear(field)
+	}
+
+	payload, err := proto.MarshalOptions{Deterministic: true}.Marshal(message)
+	if err != nil {
+		return nil, err
+	}
+
+	keyHash := sha256.Sum256([]byte(key))
+
+	return &models.IdempotentRequest{
+		Scope:       scope,
+		Key:         hex.EncodeToString(keyHash[:]),
+		Method:      method,
+		RequestHash: requestHash(payload, key, jwtSecret),
+	}, nil
+}
+
+// requestHash returns the HMAC of the payload with a key derived from the idempotency key and the jwt secret,
+// so the stored hash of a payload with a password can not be brute-forced with the database alone
+func requestHash(payload []byte, key, jwtSecret string) []byte {
+	derived := hmac.New(sha256.New, []byte(jwtSecret))
+	derived.Write([]byte("idempotency-request:" + key))
+
+	mac := hmac.New(sha256.New, derived.Sum(nil))
+	mac.Write(payload)
+
+	return mac.Sum(nil)
+}
+
+// replay returns the stored response of the key, the key can not be used for another payload
+// and not while its first request is running
+func replay(ctx context.Context, method string, request, stored *models.IdempotentRequest, key, jwtSecret string) (any, error) {
+	if !bytes.Equal(request.RequestHash, stored.RequestHash) {
+		return nil, grpcerr.Status(storage.ErrIdempotencyKeyReused)
+	}
+	if stored.Response == nil {
+		return nil, grpcerr.Status(storage.ErrIdempotencyKeyInUse)
+	}
+
+	response, err := responseMessage(method)
+	if err != nil {
+		return nil, grpcerr.Status(err)
+	}
+
+	plain, err := open(stored.Response, key, jwtSecret)
+	if err != nil {
+		return nil, grpcerr.Status(err)
+	}
+
+	if err := proto.Unmarshal(plain, response); err != nil {
+		return nil, grpcerr.Status(err)
+	}
+
+	if err := grpc.SetHeader(ctx, metadata.Pairs(ReplayedKey, "true")); err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+// responseMessage returns a new response message of the method, e.g. /api.RoleApi/CreateRole
+func responseMessage(method string) (proto.Message, error) {
+	service, name, _ := strings.Cut(strings.TrimPrefix(method, "/"), "/")
+
+	descriptor, err := protoregistry.GlobalFiles.FindDescriptorByName(protoreflect.FullName(service))
+	if err != nil {
+		return nil, err
+	}
+
+	serviceDescriptor, ok := descriptor.(protoreflect.ServiceDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("%s is no service", service)
+	}
+
+	methodDescriptor := serviceDescriptor.Methods().ByName(protoreflect.Name(name))
+	if methodDescriptor == nil {
+		return nil, fmt.Errorf("%s has no method %s", service, name)
+	}
+
+	messageType, err := protoregistry.GlobalTypes.FindMessageByName(methodDescriptor.Output().FullName())
+	if err != nil {
+		return nil, err
+	}
+
+	return messageType.New().Interface(), nil
+}
+
+// responseCipher returns the cipher of the stored responses of the idempotency key, the key is derived from
+// the idempotency key and the jwt secret, so the stored responses can not be read with the database alone
+func responseCipher(key, jwtSecret string) (cipher.AEAD, error) {
+	mac := hmac.New(sha256.New, []byte(jwtSecret))
+	mac.Write([]byte("idempotency-key:" + key))
+
+	block, err := aes.NewCipher(mac.Sum(nil))
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// seal encrypts the response, the random nonce is its prefix
+func seal(response []byte, key, jwtSecret string) ([]byte, error) {
+	aead, err := responseCipher(key, jwtSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	return aead.Seal(nonce, nonce, response, nil), nil
+}
+
+// open decrypts a response encrypted by seal
+func open(sealed []byte, key, jwtSecret string) ([]byte, error) {
+	aead, err := responseCipher(key, jwtSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("stored response is too short")
+	}
+
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+
+	return aead.Open(nil, nonce, ciphertext, nil)
+}
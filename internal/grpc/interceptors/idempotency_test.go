@@ -0,0 +1,53 @@
+package interceptors
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"google.golang.org/protobuf/proto"
+	sso "sso_go_grpc/proto/gen"
+	"testing"
+)
+
+func TestIdempotentRequestHash(t *testing.T) {
+	req := &sso.RegisterRequest{Email: "jane@example.com", Password: "password1", Username: "jane"}
+
+	base, err := idempotentRequest("key-1", "/api.UserApi/Register", req, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the stored hash must not be a plain hash of the payload with the password
+	payload, err := proto.MarshalOptions{Deterministic: true}.Marshal(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain := sha256.Sum256(payload)
+	if bytes.Equal(base.RequestHash, plain[:]) {
+		t.Error("request hash is a plain sha256")
+	}
+
+	tests := []struct {
+		name   string
+		key    string
+		req    *sso.RegisterRequest
+		secret string
+		same   bool
+	}{
+		{name: "retry", key: "key-1", req: req, secret: "secret", same: true},
+		{name: "other payload", key: "key-1", req: &sso.RegisterRequest{Email: "jane@example.com", Password: "password2", Username: "jane"}, secret: "secret"},
+		{name: "other key", key: "key-2", req: req, secret: "secret"},
+		{name: "other secret", key: "key-1", req: req, secret: "other"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := idempotentRequest(tt.key, "/api.UserApi/Register", tt.req, tt.secret)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if bytes.Equal(got.RequestHash, base.RequestHash) != tt.same {
+				t.Errorf("request hash equal = %t, want %t", !tt.same, tt.same)
+			}
+		})
+	}
+}
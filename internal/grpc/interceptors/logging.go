@@ -0,0 +1,89 @@
+package interceptors
+
+import (
+	"context"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"log/slog"
+	"sso_go_grpc/internal/lib/client"
+	"sso_go_grpc/internal/lib/reqlog"
+	"strings"
+	"time"
+)
+
+// LoggingUnary puts a logger with the request id and method into the context, handlers get it with reqlog.From;
+// every finished RPC is logged with its status code, latency and principal
+func LoggingUnary(log *slog.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ctx = requestContext(ctx, log, info.FullMethod)
+		start := time.Now()
+
+		res, err := handler(ctx, req)
+
+		logFinished(ctx, info.FullMethod, start, err)
+		return res, err
+	}
+}
+
+// LoggingStream is LoggingUnary for streams, the latency is the lifetime of the stream
+func LoggingStream(log *slog.Logger) grpc.StreamServerInterceptor {
+	return func(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx := requestContext(stream.Context(), log, info.FullMethod)
+		start := time.Now()
+
+		err := handler(srv, &contextStream{ServerStream: stream, ctx: ctx})
+
+		logFinished(ctx, info.FullMethod, start, err)
+		return err
+	}
+}
+
+func requestContext(ctx context.Context, log *slog.Logger, method string) context.Context {
+	log = log.With("request_id", client.RequestId(ctx), "method", method)
+	if span := trace.SpanContextFromContext(ctx); span.IsValid() {
+		log = log.With("trace_id", span.TraceID().String())
+	}
+
+	ctx = reqlog.NewContext(ctx, log)
+
+	// calls with an mTLS certificate are made by a service until a token says otherwise
+	if identity := client.ServiceIdentity(ctx); identity != "" {
+		reqlog.SetPrincipal(ctx, "service:"+identity)
+	}
+
+	return ctx
+}
+
+func logFinished(ctx context.Context, method string, start time.Time, err error) {
+	code := status.Code(err)
+
+	attrs := []any{"code", code.String(), "latency", time.Since(start)}
+	if principal := reqlog.Principal(ctx); principal != "" {
+		attrs = append(attrs, "principal", principal)
+	}
+	if err != nil {
+		attrs = append(attrs, "err", err.Error())
+	}
+
+	reqlog.From(ctx, nil).Log(ctx, level(method, code), "Finished RPC", attrs...)
+}
+
+// level returns the log level of a finished RPC: errors of the server are errors,
+// errors the client may have caused are warnings, the frequent health checks are only debug
+func level(method string, code codes.Code) slog.Level {
+	switch code {
+	case codes.Unknown, codes.Internal, codes.DataLoss, codes.Unimplemented:
+		return slog.LevelError
+	case codes.DeadlineExceeded, codes.PermissionDenied, codes.ResourceExhausted, codes.FailedPrecondition,
+		codes.Aborted, codes.OutOfRange, codes.Unavailable:
+		return slog.LevelWarn
+	}
+
+	if strings.HasPrefix(method, "/grpc.health.v1.Health/") {
+		return slog.LevelDebug
+	}
+
+	return slog.LevelInfo
+}
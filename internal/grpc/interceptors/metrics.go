@@ -0,0 +1,40 @@
+package interceptors
+
+import (
+	"context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+	"sso_go_grpc/internal/lib/metrics"
+	"time"
+)
+
+// MetricsUnary counts the RPCs and records their latency by method and status code
+func MetricsUnary() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		start := time.Now()
+
+		res, err := handler(ctx, req)
+
+		observeRPC(info.FullMethod, err, start)
+		return res, err
+	}
+}
+
+// MetricsStream is MetricsUnary for streams, the latency is the lifetime of the stream
+func MetricsStream() grpc.StreamServerInterceptor {
+	return func(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+
+		err := handler(srv, stream)
+
+		observeRPC(info.FullMethod, err, start)
+		return err
+	}
+}
+
+func observeRPC(fullMethod string, err error, start time.Time) {
+	code := status.Code(err).String()
+
+	metrics.RPCRequests.WithLabelValues(fullMethod, code).Inc()
+	metrics.RPCDuration.WithLabelValues(fullMethod, code).Observe(time.Since(start).Seconds())
+}
@@ -0,0 +1,47 @@
+package interceptors
+
+import (
+	"context"
+	"fmt"
+	"google.golang.org/grpc"
+	"log/slog"
+	"runtime/debug"
+	"sso_go_grpc/internal/grpc/grpcerr"
+	"sso_go_grpc/internal/lib/reqlog"
+)
+
+// RecoverUnary turns a panic of a handler into an Internal error instead of crashing the server,
+// the panic is logged with its stack
+func RecoverUnary(log *slog.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (res any, err error) {
+		defer func() {
+			if p := recover(); p != nil {
+				err = recovered(ctx, log, p)
+			}
+		}()
+
+		return handler(ctx, req)
+	}
+}
+
+// RecoverStream is RecoverUnary for streams
+func RecoverStream(log *slog.Logger) grpc.StreamServerInterceptor {
+	return func(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+		defer func() {
+			if p := recover(); p != nil {
+				err = recovered(stream.Context(), log, p)
+			}
+		}()
+
+		return handler(srv, stream)
+	}
+}
+
+func recovered(ctx context.Context, log *slog.Logger, p any) error {
+	const op = "grpc.interceptors.recover"
+
+	reqlog.From(ctx, log).With("op", op).Error("Recovered from panic", "panic", p, "stack", string(debug.Stack()))
+
+	// the panic is not part of the message, only of the log
+	return grpcerr.Status(fmt.Errorf("panic: %v", p))
+}
@@ -0,0 +1,62 @@
+package interceptors
+
+import (
+	"context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"sso_go_grpc/internal/lib/client"
+	"sso_go_grpc/internal/lib/requestid"
+)
+
+// RequestIdUnary makes sure every request has an x-request-id: the id of the client is kept if it is valid,
+// else a new one is generated; it is sent back in the response headers and read with client.RequestId
+func RequestIdUnary() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ctx, requestId := withRequestId(ctx)
+
+		if err := grpc.SetHeader(ctx, metadata.Pairs(client.RequestIdKey, requestId)); err != nil {
+			return nil, err
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+// RequestIdStream is RequestIdUnary for streams
+func RequestIdStream() grpc.StreamServerInterceptor {
+	return func(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, requestId := withRequestId(stream.Context())
+
+		if err := stream.SetHeader(metadata.Pairs(client.RequestIdKey, requestId)); err != nil {
+			return err
+		}
+
+		return handler(srv, &contextStream{ServerStream: stream, ctx: ctx})
+	}
+}
+
+// withRequestId returns the context with a valid request id in the incoming metadata
+func withRequestId(ctx context.Context) (context.Context, string) {
+	requestId := client.RequestId(ctx)
+	if requestid.Valid(requestId) {
+		return ctx, requestId
+	}
+
+	requestId = requestid.New()
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	md = md.Copy()
+	md.Set(client.RequestIdKey, requestId)
+
+	return metadata.NewIncomingContext(ctx, md), requestId
+}
+
+// contextStream replaces the context of the stream
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextStream) Context() context.Context {
+	return s.ctx
+}
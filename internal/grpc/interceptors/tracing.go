@@ -0,0 +1,64 @@
+package interceptors
+
+import (
+	"context"
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"sso_go_grpc/internal/lib/tracing"
+	"strings"
+)
+
+// TracingUnary starts a server span for every RPC, as child of the W3C trace context in the incoming metadata
+func TracingUnary() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ctx, span := startSpan(ctx, info.FullMethod)
+
+		res, err := handler(ctx, req)
+
+		endSpan(span, err)
+		return res, err
+	}
+}
+
+// TracingStream is TracingUnary for streams, the span lasts as long as the stream
+func TracingStream() grpc.StreamServerInterceptor {
+	return func(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, span := startSpan(stream.Context(), info.FullMethod)
+
+		err := handler(srv, &contextStream{ServerStream: stream, ctx: ctx})
+
+		endSpan(span, err)
+		return err
+	}
+}
+
+func startSpan(ctx context.Context, fullMethod string) (context.Context, trace.Span) {
+	// the full method is /package.Service/Method, the span is named package.Service/Method
+	name := strings.TrimPrefix(fullMethod, "/")
+	service, method, _ := strings.Cut(name, "/")
+
+	return tracing.Start(tracing.Extract(ctx), name,
+		semconv.RPCSystemKey.String("grpc"),
+		semconv.RPCService(service),
+		semconv.RPCMethod(method),
+	)
+}
+
+func endSpan(span trace.Span, err error) {
+	code := status.Code(err)
+	span.SetAttributes(attribute.Int64("rpc.grpc.status_code", int64(code)))
+
+	// only errors of the server mark the span as failed
+	switch code {
+	case codes.Unknown, codes.DeadlineExceeded, codes.Unimplemented, codes.Internal, codes.Unavailable, codes.DataLoss:
+		span.RecordError(err)
+		span.SetStatus(otelcodes.Error, status.Convert(err).Message())
+	}
+
+	span.End()
+}
@@ -0,0 +1,132 @@
+package interceptors
+
+import (
+	"context"
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"sso_go_grpc/internal/lib/tracing"
+	"testing"
+)
+
+// recordSpans makes an in-memory exporter the global span exporter for the test
+func recordSpans(t *testing.T) *tracetest.InMemoryExporter {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter), sdktrace.WithSampler(sdktrace.AlwaysSample()))
+
+	tracing.SetGlobal(provider)
+	t.Cleanup(func() {
+		provider.Shutdown(context.Background())
+	})
+
+	return exporter
+}
+
+// attributes returns the attributes of the span by key
+func attributes(span tracetest.SpanStub) map[attribute.Key]attribute.Value {
+	values := make(map[attribute.Key]attribute.Value, len(span.Attributes))
+	for _, kv := range span.Attributes {
+		values[kv.Key] = kv.Value
+	}
+
+	return values
+}
+
+func TestTracingUnary(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantCode   codes.Code
+		wantStatus otelcodes.Code
+	}{
+		{name: "ok", wantCode: codes.OK, wantStatus: otelcodes.Unset},
+		{name: "client error", err: status.Error(codes.NotFound, "user not found"), wantCode: codes.NotFound, wantStatus: otelcodes.Unset},
+		{name: "server error", err: status.Error(codes.Internal, "boom"), wantCode: codes.Internal, wantStatus: otelcodes.Error},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exporter := recordSpans(t)
+
+			var handlerSpan trace.SpanContext
+			handler := func(ctx context.Context, req any) (any, error) {
+				// the spans of the services are children of the server span
+				_, span := tracing.Start(ctx, "service.user.GetUserById")
+				handlerSpan = span.SpanContext()
+				span.End()
+
+				return nil, tt.err
+			}
+
+			info := &grpc.UnaryServerInfo{FullMethod: "/api.UserApi/GetUserById"}
+			if _, err := TracingUnary()(context.Background(), nil, info, handler); err != tt.err {
+				t.Fatalf("error = %v, want %v", err, tt.err)
+			}
+
+			spans := exporter.GetSpans()
+			if len(spans) != 2 {
+				t.Fatalf("got %d spans, want 2", len(spans))
+			}
+
+			child, server := spans[0], spans[1]
+			if server.Name != "api.UserApi/GetUserById" {
+				t.Errorf("span name = %q, want api.UserApi/GetUserById", server.Name)
+			}
+			if child.Parent.SpanID() != server.SpanContext.SpanID() || !child.SpanContext.Equal(handlerSpan) {
+				t.Error("the span of the handler is not a child of the server span")
+			}
+
+			attrs := attributes(server)
+			if got := attrs["rpc.system"].AsString(); got != "grpc" {
+				t.Errorf("rpc.system = %q, want grpc", got)
+			}
+			if got := attrs["rpc.service"].AsString(); got != "api.UserApi" {
+				t.Errorf("rpc.service = %q, want api.UserApi", got)
+			}
+			if got := attrs["rpc.method"].AsString(); got != "GetUserById" {
+				t.Errorf("rpc.method = %q, want GetUserById", got)
+			}
+			if got := attrs["rpc.grpc.status_code"].AsInt64(); got != int64(tt.wantCode) {
+				t.Errorf("rpc.grpc.status_code = %d, want %d", got, tt.wantCode)
+			}
+			if server.Status.Code != tt.wantStatus {
+				t.Errorf("span status = %s, want %s", server.Status.Code, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestTracingUnaryContinuesTrace(t *testing.T) {
+	exporter := recordSpans(t)
+
+	traceparent := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("traceparent", traceparent))
+
+	handler := func(ctx context.Context, req any) (any, error) {
+		return nil, nil
+	}
+
+	info := &grpc.UnaryServerInfo{FullMethod: "/api.Auth/Login"}
+	if _, err := TracingUnary()(ctx, nil, info, handler); err != nil {
+		t.Fatal(err)
+	}
+
+	spans := exporter.GetSpans()
+	if len(spans) != 1 {
+		t.Fatalf("got %d spans, want 1", len(spans))
+	}
+
+	span := spans[0]
+	if got := span.SpanContext.TraceID().String(); got != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("trace id = %s, want the trace id of the traceparent", got)
+	}
+	if got := span.Parent.SpanID().String(); got != "00f067aa0ba902b7" || !span.Parent.IsRemote() {
+		t.Errorf("parent = %s, want the remote span of the traceparent", got)
+	}
+}
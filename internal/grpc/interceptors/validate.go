@@ -0,0 +1,226 @@
+package interceptors
+
+import (
+	"context"
+	"fmt"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"net/mail"
+	"net/url"
+	"regexp"
+	"sso_go_grpc/internal/grpc/grpcerr"
+	"sso_go_grpc/internal/lib/normalize"
+	sso "sso_go_grpc/proto/gen"
+	"sync"
+	"unicode/utf8"
+)
+
+// ValidateUnary rejects requests breaking the rules of their fields with InvalidArgument,
+// the error has a BadRequest with a violation of every invalid field
+func ValidateUnary() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if message, ok := req.(proto.Message); ok {
+			if err := Validate(message); err != nil {
+				return nil, err
+			}
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+// ValidateStream validates every message received on the stream like ValidateUnary
+func ValidateStream() grpc.StreamServerInterceptor {
+	return func(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return handler(srv, &validatingStream{ServerStream: stream})
+	}
+}
+
+type validatingStream struct {
+	grpc.ServerStream
+}
+
+func (s *validatingStream) RecvMsg(m any) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+
+	if message, ok := m.(proto.Message); ok {
+		return Validate(message)
+	}
+
+	return nil
+}
+
+// Validate checks the message against the rules of its fields and of the fields of its nested messages,
+// returns nil if the message is valid
+func Validate(message proto.Message) error {
+	v := &validator{}
+	v.message(message.ProtoReflect(), "")
+
+	if v.err != nil {
+		return grpcerr.Status(v.err)
+	}
+	if len(v.violations) > 0 {
+		return grpcerr.FieldViolations(v.violations)
+	}
+
+	return nil
+}
+
+// patterns caches the compiled patterns of the rules
+var patterns sync.Map
+
+type validator struct {
+	violations []*errdetails.BadRequest_FieldViolation
+	// err is set if a rule itself is broken, e.g. a pattern that does not compile
+	err error
+}
+
+func (v *validator) violation(path, description string) {
+	v.violations = append(v.violations, &errdetails.BadRequest_FieldViolation{Field: path, Description: description})
+}
+
+func (v *validator) message(message protoreflect.Message, prefix string) {
+	fields := message.Descriptor().Fields()
+
+	for i := 0; i < fields.Len(); i++ {
+		field := fields.Get(i)
+		path := prefix + field.JSONName()
+
+		rules, _ := proto.GetExtension(field.Options(), sso.E_Rules).(*sso.FieldRules)
+
+		switch {
+		case field.IsMap():
+			// maps have no rules
+		case field.IsList():
+			v.list(field, message.Get(field).List(), rules, path)
+		default:
+			if !message.Has(field) {
+				if rules.GetRequired() {
+					v.violation(path, "is required")
+				}
+				continue
+			}
+
+			v.value(field, message.Get(field), rules, path)
+		}
+	}
+}
+
+func (v *validator) list(field protoreflect.FieldDescriptor, list protoreflect.List, rules *sso.FieldRules, path string) {
+	if rules.GetRequired() && list.Len() == 0 {
+		v.violation(path, "at least one item is required")
+	}
+	if rules.GetMaxItems() > 0 && list.Len() > int(rules.GetMaxItems()) {
+		v.violation(path, fmt.Sprintf("at most %d items are allowed", rules.GetMaxItems()))
+	}
+
+	for i := 0; i < list.Len(); i++ {
+		itemPath := fmt.Sprintf("%s[%d]", path, i)
+		value := list.Get(i)
+
+		if rules.GetItems().GetRequired() && isZero(field, value) {
+			v.violation(itemPath, "must not be empty or 0")
+			continue
+		}
+
+		v.value(field, value, rules.GetItems(), itemPath)
+	}
+}
+
+// value checks a set value, empty values are only checked by required
+func (v *validator) value(field protoreflect.FieldDescriptor, value protoreflect.Value, rules *sso.FieldRules, path string) {
+	if field.Kind() == protoreflect.MessageKind {
+		// well-known types like timestamps and structs have no rules
+		if field.Message().ParentFile().Package() != "google.protobuf" {
+			v.message(value.Message(), path+".")
+		}
+		return
+	}
+
+	if field.Kind() != protoreflect.StringKind || rules == nil || value.String() == "" {
+		return
+	}
+
+	text := value.String()
+
+	if rules.GetMinLen() > 0 && utf8.RuneCountInString(text) < int(rules.GetMinLen()) {
+		v.violation(path, fmt.Sprintf("must be at least %d characters", rules.GetMinLen()))
+	}
+	if rules.GetMaxLen() > 0 && utf8.RuneCountInString(text) > int(rules.GetMaxLen()) {
+		v.violation(path, fmt.Sprintf("must be at most %d characters", rules.GetMaxLen()))
+	}
+	if rules.GetMaxBytes() > 0 && len(text) > int(rules.GetMaxBytes()) {
+		v.violation(path, fmt.Sprintf("must be at most %d bytes", rules.GetMaxBytes()))
+	}
+
+	if rules.GetPattern() != "" {
+		pattern, err := compile(rules.GetPattern())
+		if err != nil {
+			v.err = fmt.Errorf("rules of %s: %w", path, err)
+			return
+		}
+		if !pattern.MatchString(text) {
+			v.violation(path, fmt.Sprintf("must match %s", rules.GetPattern()))
+		}
+	}
+
+	if rules.GetEmail() && !isEmail(text) {
+		v.violation(path, "must be a valid email address")
+	}
+	if rules.GetUsername() {
+		if _, err := normalize.Username(text); err != nil {
+			v.violation(path, "contains characters that are not allowed")
+		}
+	}
+	if rules.GetUri() {
+		if u, err := url.Parse(text); err != nil || u.Scheme == "" || u.Host == "" {
+			v.violation(path, "must be an absolute URI")
+		}
+	}
+}
+
+func compile(expr string) (*regexp.Regexp, error) {
+	if pattern, ok := patterns.Load(expr); ok {
+		return pattern.(*regexp.Regexp), nil
+	}
+
+	pattern, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	patterns.Store(expr, pattern)
+	return pattern, nil
+}
+
+// isEmail reports whether the text is a bare address, without a display name or angle brackets
+func isEmail(text string) bool {
+	address, err := mail.ParseAddress(text)
+
+	return err == nil && address.Name == "" && address.Address == text
+}
+
+func isZero(field protoreflect.FieldDescriptor, value protoreflect.Value) bool {
+	switch field.Kind() {
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return !value.Message().IsValid()
+	case protoreflect.BytesKind:
+		return len(value.Bytes()) == 0
+	case protoreflect.StringKind:
+		return value.String() == ""
+	case protoreflect.BoolKind:
+		return !value.Bool()
+	case protoreflect.EnumKind:
+		return value.Enum() == 0
+	case protoreflect.FloatKind, protoreflect.DoubleKind:
+		return value.Float() == 0
+	case protoreflect.Uint32Kind, protoreflect.Uint64Kind, protoreflect.Fixed32Kind, protoreflect.Fixed64Kind:
+		return value.Uint() == 0
+	}
+
+	return value.Int() == 0
+}
@@ -0,0 +1,123 @@
+package interceptors
+
+import (
+	"context"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"slices"
+	sso "sso_go_grpc/proto/gen"
+	"strings"
+	"testing"
+)
+
+// violationFields returns the fields of the BadRequest violations of the status error
+func violationFields(err error) []string {
+	var fields []string
+	for _, detail := range status.Convert(err).Details() {
+		if badRequest, ok := detail.(*errdetails.BadRequest); ok {
+			for _, violation := range badRequest.GetFieldViolations() {
+				fields = append(fields, violation.GetField())
+			}
+		}
+	}
+
+	return fields
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		req  proto.Message
+		// want are the fields with violations, none for a valid request
+		want []string
+	}{
+		{name: "valid", req: &sso.RegisterRequest{Email: "jane@example.com", Password: "password1", Username: "jane"}},
+		{name: "required fields", req: &sso.RegisterRequest{}, want: []string{"email", "password", "username"}},
+		{name: "email", req: &sso.RegisterRequest{Email: "Jane <jane@example.com>", Password: "password1", Username: "jane"}, want: []string{"email"}},
+		{name: "minLen", req: &sso.RegisterRequest{Email: "jane@example.com", Password: "short", Username: "jane"}, want: []string{"password"}},
+		{
+			name: "maxBytes counts bytes",
+			req:  &sso.RegisterRequest{Email: "jane@example.com", Password: strings.Repeat("ä", 37), Username: "jane"},
+			want: []string{"password"},
+		},
+		{
+			name: "maxLen counts characters",
+			req:  &sso.RegisterRequest{Email: "jane@example.com", Password: "password1", Username: strings.Repeat("ä", 64)},
+		},
+		{name: "username", req: &sso.RegisterRequest{Email: "jane@example.com", Password: "password1", Username: "jane doe"}, want: []string{"username"}},
+		{name: "pattern", req: &sso.CreateRoleRequest{Name: "1admin"}, want: []string{"name"}},
+		{name: "uri", req: &sso.CreateWebhookSubscriptionRequest{Url: "/hooks", EventTypes: []string{"user.created"}}, want: []string{"url"}},
+		{name: "required list", req: &sso.CreateWebhookSubscriptionRequest{Url: "https://example.com/hooks"}, want: []string{"eventTypes"}},
+		{name: "required items", req: &sso.ListUsersRequest{RoleIds: []uint64{1, 0, 3}}, want: []string{"roleIds[1]"}},
+		{name: "maxItems", req: &sso.ListUsersRequest{RoleIds: tooManyRoleIds()}, want: []string{"roleIds"}},
+		{name: "unset optional fields", req: &sso.ListUsersRequest{}},
+		{name: "required message", req: &sso.SetAttributeDefinitionRequest{}, want: []string{"definition"}},
+		{
+			name: "nested message",
+			req: &sso.SetAttributeDefinitionRequest{Definition: &sso.AttributeDefinition{
+				Name: "department",
+				Enum: []string{"sales", strings.Repeat("a", 256)},
+			}},
+			want: []string{"definition.enum[1]"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate(tt.req)
+
+			if len(tt.want) == 0 {
+				if err != nil {
+					t.Fatalf("Validate() = %v, want nil", err)
+				}
+				return
+			}
+
+			if code := status.Code(err); code != codes.InvalidArgument {
+				t.Fatalf("Validate() = %v, want code %s", err, codes.InvalidArgument)
+			}
+			if fields := violationFields(err); !slices.Equal(fields, tt.want) {
+				t.Errorf("violation fields = %v, want %v", fields, tt.want)
+			}
+		})
+	}
+}
+
+// tooManyRoleIds returns one role id more than ListUsersRequest allows
+func tooManyRoleIds() []uint64 {
+	ids := make([]uint64, 101)
+	for i := range ids {
+		ids[i] = uint64(i + 1)
+	}
+
+	return ids
+}
+
+func TestValidateUnary(t *testing.T) {
+	interceptor := ValidateUnary()
+	info := &grpc.UnaryServerInfo{FullMethod: "/api.Auth/Register"}
+
+	var called bool
+	handler := func(ctx context.Context, req any) (any, error) {
+		called = true
+		return &sso.RegisterResponse{}, nil
+	}
+
+	if _, err := interceptor(context.Background(), &sso.RegisterRequest{}, info, handler); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("invalid request error = %v, want code %s", err, codes.InvalidArgument)
+	}
+	if called {
+		t.Error("handler was called for an invalid request")
+	}
+
+	req := &sso.RegisterRequest{Email: "jane@example.com", Password: "password1", Username: "jane"}
+	if _, err := interceptor(context.Background(), req, info, handler); err != nil {
+		t.Errorf("valid request error = %v, want nil", err)
+	}
+	if !called {
+		t.Error("handler was not called for a valid request")
+	}
+}
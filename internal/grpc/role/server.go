@@ -2,12 +2,11 @@ package roleServer
 
 import (
 	"context"
-	"errors"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/codes"
-	"google.golang.org/grpc/status"
+	"sso_go_grpc/internal/domain/models"
+	"sso_go_grpc/internal/grpc/fieldmask"
+	"sso_go_grpc/internal/grpc/grpcerr"
 	roleService "sso_go_grpc/internal/services/role"
-	"sso_go_grpc/internal/storage"
 	sso "sso_go_grpc/proto/gen"
 )
 
@@ -24,35 +23,41 @@ func (s *serverApi) CreateRole(ctx context.Context, req *sso.CreateRoleRequest)
 	role, err := s.roleService.CreateRole(ctx, req.GetToken(), req.GetName(), req.GetDescription())
 
 	if err != nil {
-		if errors.Is(storage.ErrRoleExists, err) {
-			return nil, err
-		}
-		return nil, status.Error(codes.Internal, "Internal Server Error")
+		return nil, grpcerr.Status(err)
 	}
 
-	return &sso.CreateRoleResponse{Role: &sso.Role{RoleId: role.RoleId, Description: role.Description, Name: role.Name}}, nil
+	return &sso.CreateRoleResponse{Role: &sso.Role{RoleId: role.RoleId, Description: role.Description, Name: role.Name, Version: role.Version}}, nil
 }
 
 func (s *serverApi) UpdateRole(ctx context.Context, req *sso.UpdateRoleRequest) (res *sso.UpdateRoleResponse, err error) {
-	role, err := s.roleService.UpdateRole(ctx, req.GetToken(), req.GetRoleId(), req.GetName(), req.GetDescription())
+	paths, err := fieldmask.Paths(req.GetUpdateMask(), req, "name", "description")
 	if err != nil {
-		if errors.Is(storage.ErrRoleNotExists, err) {
-			return nil, status.Error(codes.NotFound, err.Error())
+		return nil, err
+	}
+
+	update := models.RoleUpdate{ExpectedVersion: req.GetExpectedVersion()}
+	for _, path := range paths {
+		switch path {
+		case "name":
+			update.Name = &req.Name
+		case "description":
+			update.Description = &req.Description
 		}
 	}
 
+	role, err := s.roleService.UpdateRole(ctx, req.GetToken(), req.GetRoleId(), update)
+	if err != nil {
+		return nil, grpcerr.Status(err)
+	}
+
 	return &sso.UpdateRoleResponse{Role: role}, nil
 }
 
 func (s *serverApi) DeleteRole(ctx context.Context, req *sso.DeleteRoleRequest) (*sso.DeleteRoleResponse, error) {
-	err := s.roleService.DeleteRole(ctx, req.GetToken(), req.GetRoleId())
+	err := s.roleService.DeleteRole(ctx, req.GetToken(), req.GetRoleId(), req.GetExpectedVersion())
 
 	if err != nil {
-		if errors.Is(storage.ErrRoleNotExists, err) {
-			return nil, status.Error(codes.NotFound, err.Error())
-		}
-
-		return nil, status.Error(codes.Internal, "Internal Server Error")
+		return nil, grpcerr.Status(err)
 	}
 
 	return &sso.DeleteRoleResponse{Message: "Successfully Deleted the Role"}, nil
@@ -62,14 +67,7 @@ func (s *serverApi) AddUserRole(ctx context.Context, req *sso.AddUserRoleRequest
 	user, err := s.roleService.AddUserRole(ctx, req.GetToken(), req.GetRoleId(), req.GetUserId())
 
 	if err != nil {
-		if errors.Is(storage.ErrUserAndRoleIvalid, err) {
-			return nil, status.Error(codes.NotFound, err.Error())
-		}
-		if errors.Is(storage.ErrUserAlreadyHasTHeRole, err) {
-			return nil, status.Error(codes.AlreadyExists, err.Error())
-		}
-
-		return nil, status.Error(codes.Internal, "Internal Server Error")
+		return nil, grpcerr.Status(err)
 	}
 
 	return &sso.AddUserRoleResponse{User: user}, nil
@@ -80,11 +78,7 @@ func (s *serverApi) RemoveUserRole(ctx context.Context, req *sso.RemoveUserRoleR
 	user, err := s.roleService.RemoveUserRole(ctx, req.GetToken(), req.GetRoleId(), req.GetUserId())
 
 	if err != nil {
-		if errors.Is(storage.ErrUserAndRoleIvalid, err) || errors.Is(storage.ErrUserDontHaveTheRole, err) {
-			return nil, status.Error(codes.NotFound, err.Error())
-		}
-
-		return nil, status.Error(codes.Internal, "Internal Server Error")
+		return nil, grpcerr.Status(err)
 	}
 
 	return &sso.RemoveUserRoleResponse{User: user}, nil
@@ -94,10 +88,7 @@ func (s *serverApi) VerifyUserRoles(ctx context.Context, req *sso.VerifyUserRole
 	verified, err := s.roleService.VerifyUserRoles(ctx, req.GetRoleIds(), req.GetUserId())
 
 	if err != nil {
-		if errors.Is(storage.ErrRoleNotExists, err) || errors.Is(storage.ErrUserNotExists, err) {
-			return nil, status.Error(codes.Internal, err.Error())
-		}
-		return nil, status.Error(codes.Internal, "Internal Server Error")
+		return nil, grpcerr.Status(err)
 	}
 
 	return &sso.VerifyUserRolesResponse{Verified: verified}, nil
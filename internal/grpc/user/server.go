@@ -2,12 +2,16 @@ package userServer
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/codes"
-	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"io"
+	"sso_go_grpc/internal/domain/models"
+	"sso_go_grpc/internal/grpc/grpcerr"
 	userService "sso_go_grpc/internal/services/user"
-	"sso_go_grpc/internal/storage"
 	sso "sso_go_grpc/proto/gen"
 )
 
@@ -20,33 +24,20 @@ func RegisterServer(Grpc *grpc.Server, userService *userService.UserService) {
 	sso.RegisterUserApiServer(Grpc, &serverApi{userService: userService})
 }
 func (s *serverApi) Register(ctx context.Context, req *sso.RegisterRequest) (res *sso.RegisterResponse, err error) {
-
-	if req.GetEmail() == "" || req.GetPassword() == "" || req.GetUsername() == "" {
-		return nil, status.Error(codes.InvalidArgument, "Invalid Arguments, expected: email, password, username")
-	}
-
 	token, userId, err := s.userService.Register(ctx, req.GetEmail(), req.GetPassword(), req.GetUsername())
 
 	if err != nil {
-		return nil, err
-		//	return nil, status.Error(codes.Internal, "Internal Server Error")
+		return nil, grpcerr.Status(err)
 	}
 
 	return &sso.RegisterResponse{Token: token, UserId: userId}, nil
 }
 
 func (s *serverApi) Login(ctx context.Context, req *sso.LoginRequest) (res *sso.LoginResponse, err error) {
-	if req.GetEmail() == "" || req.GetPassword() == "" {
-		return nil, status.Error(codes.InvalidArgument, "Invalid Arguments, expected: email, password")
-	}
-
 	token, userId, err := s.userService.Login(ctx, req.GetEmail(), req.GetPassword())
 
 	if err != nil {
-		if errors.Is(err, storage.ErrAuth) {
-			return nil, status.Error(codes.Internal, storage.ErrAuth.Error())
-		}
-		return nil, status.Error(codes.Internal, "Internal Server Error")
+		return nil, grpcerr.Status(err)
 	}
 
 	return &sso.LoginResponse{Token: token, UserId: userId}, nil
@@ -56,10 +47,7 @@ func (s *serverApi) GetUserById(ctx context.Context, req *sso.GetUserByIdRequest
 	user, err := s.userService.GetUserById(ctx, req.GetUserId())
 
 	if err != nil {
-		if errors.Is(storage.ErrUserNotExists, err) {
-			return nil, status.Error(codes.NotFound, storage.ErrUserNotExists.Error())
-		}
-		return nil, status.Error(codes.Internal, "Internal Server Error")
+		return nil, grpcerr.Status(err)
 	}
 
 	return &sso.GetUserByIdResponse{User: user}, nil
@@ -70,12 +58,169 @@ func (s *serverApi) GetUserByEmail(ctx context.Context, req *sso.GetUserEmailReq
 	user, err := s.userService.GetUserByEmail(ctx, req.GetEmail())
 
 	if err != nil {
-		if errors.Is(storage.ErrUserNotExists, err) {
-			return nil, status.Error(codes.NotFound, storage.ErrUserNotExists.Error())
-		}
-		return nil, status.Error(codes.Internal, "Internal Server Error")
+		return nil, grpcerr.Status(err)
 	}
 
 	return &sso.GetUserEmailResponse{User: user}, nil
 
 }
+
+func (s *serverApi) ListUsers(ctx context.Context, req *sso.ListUsersRequest) (res *sso.ListUsersResponse, err error) {
+	filter := models.UserFilter{
+		RoleIds:      req.GetRoleIds(),
+		Status:       req.GetStatus(),
+		EmailDomain:  req.GetEmailDomain(),
+		Search:       req.GetSearch(),
+		PrefixSearch: req.GetSearchMode() == sso.SearchMode_SEARCH_MODE_PREFIX,
+	}
+
+	if req.GetCreatedAfter() != nil {
+		filter.CreatedAfter = req.GetCreatedAfter().AsTime()
+	}
+	if req.GetCreatedBefore() != nil {
+		filter.CreatedBefore = req.GetCreatedBefore().AsTime()
+	}
+	if req.GetAttributes() != nil {
+		filter.Attributes = req.GetAttributes().AsMap()
+	}
+
+	users, nextPageToken, err := s.userService.ListUsers(ctx, req.GetToken(), filter, req.GetPageToken(), req.GetPageSize(), req.GetIncludeRoles())
+
+	if err != nil {
+		return nil, grpcerr.Status(err)
+	}
+
+	return &sso.ListUsersResponse{Users: users, NextPageToken: nextPageToken}, nil
+}
+
+func (s *serverApi) UpdateUserAttributes(ctx context.Context, req *sso.UpdateUserAttributesRequest) (res *sso.UpdateUserAttributesResponse, err error) {
+	user, err := s.userService.UpdateUserAttributes(ctx, req.GetToken(), req.GetUserId(), req.GetExpectedVersion(), req.GetAttributes().AsMap())
+
+	if err != nil {
+		return nil, grpcerr.Status(err)
+	}
+
+	return &sso.UpdateUserAttributesResponse{User: user}, nil
+}
+
+func (s *serverApi) ExportUserData(ctx context.Context, req *sso.ExportUserDataRequest) (res *sso.ExportUserDataResponse, err error) {
+	data, err := s.userService.ExportUserData(ctx, req.GetToken(), req.GetUserId())
+
+	if err != nil {
+		return nil, grpcerr.Status(err)
+	}
+
+	return &sso.ExportUserDataResponse{Data: data, ContentType: "application/json"}, nil
+}
+
+func (s *serverApi) ImportUsers(stream sso.UserApi_ImportUsersServer) error {
+	// token and dryRun are read from the first message
+	first, err := stream.Recv()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return grpcerr.Required("user")
+		}
+		return err
+	}
+
+	pending := first.GetUser()
+
+	next := func() (*models.ImportUser, error) {
+		for pending == nil {
+			req, err := stream.Recv()
+			if err != nil {
+				return nil, err
+			}
+			pending = req.GetUser()
+		}
+
+		user := &models.ImportUser{
+			Email:        pending.GetEmail(),
+			Username:     pending.GetUsername(),
+			PasswordHash: pending.GetPasswordHash(),
+			Roles:        pending.GetRoles(),
+		}
+		pending = nil
+
+		return user, nil
+	}
+
+	results, err := s.userService.ImportUsers(stream.Context(), first.GetToken(), first.GetDryRun(), next)
+
+	if err != nil {
+		return grpcerr.Status(err)
+	}
+
+	res := &sso.ImportUsersResponse{DryRun: first.GetDryRun()}
+
+	for _, result := range results {
+		if result.Error == "" {
+			res.Imported++
+		} else {
+			res.Failed++
+		}
+
+		res.Results = append(res.Results, &sso.ImportUserResult{
+			Row:    result.Row,
+			Email:  result.Email,
+			UserId: result.UserId,
+			Error:  result.Error,
+		})
+	}
+
+	return stream.SendAndClose(res)
+}
+
+func (s *serverApi) ListLoginHistory(ctx context.Context, req *sso.ListLoginHistoryRequest) (res *sso.ListLoginHistoryResponse, err error) {
+	attempts, nextPageToken, err := s.userService.ListLoginHistory(ctx, req.GetToken(), req.GetUserId(), req.GetPageToken(), req.GetPageSize())
+
+	if err != nil {
+		return nil, grpcerr.Status(err)
+	}
+
+	return &sso.ListLoginHistoryResponse{Attempts: attempts, NextPageToken: nextPageToken}, nil
+}
+
+func (s *serverApi) WatchUserChanges(req *sso.WatchUserChangesRequest, stream sso.UserApi_WatchUserChangesServer) error {
+	send := func(event *models.Event, resumeToken string) error {
+		change, err := toProtoChange(event, resumeToken)
+		if err != nil {
+			return err
+		}
+		return stream.Send(change)
+	}
+
+	// the headers tell the client that the watch is set up before the first change arrives
+	ready := func() error {
+		return stream.SendHeader(metadata.MD{})
+	}
+
+	err := s.userService.WatchUserChanges(stream.Context(), req.GetToken(), req.GetResumeToken(), req.GetEventTypes(), ready, send)
+
+	// errors of stream.Send are already status errors
+	return grpcerr.Status(err)
+}
+
+// toProtoChange converts the event, its stored json payload is always an object
+func toProtoChange(event *models.Event, resumeToken string) (*sso.UserChange, error) {
+	var data map[string]any
+
+	if raw, ok := event.Data.(json.RawMessage); ok {
+		if err := json.Unmarshal(raw, &data); err != nil {
+			return nil, err
+		}
+	}
+
+	protoData, err := structpb.NewStruct(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return &sso.UserChange{
+		EventId:     event.Id,
+		Type:        event.Type,
+		Data:        protoData,
+		CreatedAt:   timestamppb.New(event.CreatedAt),
+		ResumeToken: resumeToken,
+	}, nil
+}
@@ -0,0 +1,134 @@
+package webhookServer
+
+import (
+	"context"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"sso_go_grpc/internal/domain/models"
+	"sso_go_grpc/internal/grpc/grpcerr"
+	webhookService "sso_go_grpc/internal/services/webhook"
+	sso "sso_go_grpc/proto/gen"
+)
+
+type serverApi struct {
+	webhookService *webhookService.WebhookService
+	sso.UnimplementedWebhookApiServer
+}
+
+func RegisterServer(Grpc *grpc.Server, webhookService *webhookService.WebhookService) {
+	sso.RegisterWebhookApiServer(Grpc, &serverApi{webhookService: webhookService})
+}
+
+func (s *serverApi) CreateWebhookSubscription(ctx context.Context, req *sso.CreateWebhookSubscriptionRequest) (res *sso.CreateWebhookSubscriptionResponse, err error) {
+	subscription, err := s.webhookService.CreateSubscription(ctx, req.GetToken(), &models.WebhookSubscription{
+		Url:        req.GetUrl(),
+		EventTypes: req.GetEventTypes(),
+	})
+
+	if err != nil {
+		return nil, grpcerr.Status(err)
+	}
+
+	return &sso.CreateWebhookSubscriptionResponse{Subscription: toProtoSubscription(subscription), Secret: subscription.Secret}, nil
+}
+
+func (s *serverApi) UpdateWebhookSubscription(ctx context.Context, req *sso.UpdateWebhookSubscriptionRequest) (res *sso.UpdateWebhookSubscriptionResponse, err error) {
+	subscription, err := s.webhookService.UpdateSubscription(ctx, req.GetToken(), &models.WebhookSubscription{
+		Id:         req.GetSubscriptionId(),
+		Url:        req.GetUrl(),
+		EventTypes: req.GetEventTypes(),
+		Active:     req.GetActive(),
+	})
+
+	if err != nil {
+		return nil, grpcerr.Status(err)
+	}
+
+	return &sso.UpdateWebhookSubscriptionResponse{Subscription: toProtoSubscription(subscription)}, nil
+}
+
+func (s *serverApi) DeleteWebhookSubscription(ctx context.Context, req *sso.DeleteWebhookSubscriptionRequest) (res *sso.DeleteWebhookSubscriptionResponse, err error) {
+	err = s.webhookService.DeleteSubscription(ctx, req.GetToken(), req.GetSubscriptionId())
+
+	if err != nil {
+		return nil, grpcerr.Status(err)
+	}
+
+	return &sso.DeleteWebhookSubscriptionResponse{Message: "Successfully Deleted the Webhook Subscription"}, nil
+}
+
+func (s *serverApi) ListWebhookSubscriptions(ctx context.Context, req *sso.ListWebhookSubscriptionsRequest) (res *sso.ListWebhookSubscriptionsResponse, err error) {
+	subscriptions, err := s.webhookService.ListSubscriptions(ctx, req.GetToken())
+
+	if err != nil {
+		return nil, grpcerr.Status(err)
+	}
+
+	res = &sso.ListWebhookSubscriptionsResponse{}
+	for _, subscription := range subscriptions {
+		res.Subscriptions = append(res.Subscriptions, toProtoSubscription(subscription))
+	}
+
+	return res, nil
+}
+
+func (s *serverApi) ListWebhookDeliveries(ctx context.Context, req *sso.ListWebhookDeliveriesRequest) (res *sso.ListWebhookDeliveriesResponse, err error) {
+	filter := models.DeliveryFilter{
+		SubscriptionId: req.GetSubscriptionId(),
+		Status:         req.GetStatus(),
+	}
+
+	deliveries, nextPageToken, err := s.webhookService.ListDeliveries(ctx, req.GetToken(), filter, req.GetPageToken(), req.GetPageSize())
+
+	if err != nil {
+		return nil, grpcerr.Status(err)
+	}
+
+	res = &sso.ListWebhookDeliveriesResponse{NextPageToken: nextPageToken}
+	for _, delivery := range deliveries {
+		res.Deliveries = append(res.Deliveries, toProtoDelivery(delivery))
+	}
+
+	return res, nil
+}
+
+func (s *serverApi) RedeliverWebhook(ctx context.Context, req *sso.RedeliverWebhookRequest) (res *sso.RedeliverWebhookResponse, err error) {
+	err = s.webhookService.Redeliver(ctx, req.GetToken(), req.GetDeliveryId())
+
+	if err != nil {
+		return nil, grpcerr.Status(err)
+	}
+
+	return &sso.RedeliverWebhookResponse{Message: "Successfully Queued the Webhook Delivery"}, nil
+}
+
+func toProtoSubscription(subscription *models.WebhookSubscription) *sso.WebhookSubscription {
+	return &sso.WebhookSubscription{
+		SubscriptionId: subscription.Id,
+		Url:            subscription.Url,
+		EventTypes:     subscription.EventTypes,
+		Active:         subscription.Active,
+		CreatedAt:      timestamppb.New(subscription.CreatedAt),
+	}
+}
+
+func toProtoDelivery(delivery *models.WebhookDelivery) *sso.WebhookDelivery {
+	protoDelivery := &sso.WebhookDelivery{
+		DeliveryId:     delivery.Id,
+		SubscriptionId: delivery.SubscriptionId,
+		EventId:        delivery.Event.Id,
+		EventType:      delivery.Event.Type,
+		Status:         delivery.Status,
+		Attempts:       delivery.Attempts,
+		NextAttemptAt:  timestamppb.New(delivery.NextAttemptAt),
+		LastError:      delivery.LastError,
+		ResponseStatus: int32(delivery.ResponseStatus),
+		CreatedAt:      timestamppb.New(delivery.CreatedAt),
+	}
+
+	if !delivery.DeliveredAt.IsZero() {
+		protoDelivery.DeliveredAt = timestamppb.New(delivery.DeliveredAt)
+	}
+
+	return protoDelivery
+}
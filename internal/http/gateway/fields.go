@@ -0,0 +1,128 @@
+package gatewayServer
+
+import (
+	"encoding/base64"
+	"fmt"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"sso_go_grpc/internal/grpc/grpcerr"
+	"strconv"
+)
+
+// setField sets the field with that json name to the values of a query or path parameter,
+// repeated fields get all values, the others the last one
+func setField(message protoreflect.Message, name string, values []string) error {
+	field := message.Descriptor().Fields().ByJSONName(name)
+	if field == nil || field.IsMap() || len(values) == 0 {
+		return grpcerr.InvalidArgument(name, fmt.Sprintf("unknown parameter %q", name))
+	}
+
+	if field.IsList() {
+		list := message.Mutable(field).List()
+		for _, value := range values {
+			parsed, err := parseValue(message, field, value)
+			if err != nil {
+				return err
+			}
+			list.Append(parsed)
+		}
+		return nil
+	}
+
+	parsed, err := parseValue(message, field, values[len(values)-1])
+	if err != nil {
+		return err
+	}
+
+	message.Set(field, parsed)
+	return nil
+}
+
+// parseValue parses the text of a parameter as value of the field;
+// messages are json, well-known types like timestamps can also be given without quotes
+func parseValue(message protoreflect.Message, field protoreflect.FieldDescriptor, text string) (protoreflect.Value, error) {
+	invalid := func() (protoreflect.Value, error) {
+		return protoreflect.Value{}, grpcerr.InvalidArgument(field.JSONName(), fmt.Sprintf("invalid value %q of parameter %q", text, field.JSONName()))
+	}
+
+	switch field.Kind() {
+	case protoreflect.StringKind:
+		return protoreflect.ValueOfString(text), nil
+	case protoreflect.BoolKind:
+		parsed, err := strconv.ParseBool(text)
+		if err != nil {
+			return invalid()
+		}
+		return protoreflect.ValueOfBool(parsed), nil
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		parsed, err := strconv.ParseInt(text, 10, 32)
+		if err != nil {
+			return invalid()
+		}
+		return protoreflect.ValueOfInt32(int32(parsed)), nil
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		parsed, err := strconv.ParseInt(text, 10, 64)
+		if err != nil {
+			return invalid()
+		}
+		return protoreflect.ValueOfInt64(parsed), nil
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		parsed, err := strconv.ParseUint(text, 10, 32)
+		if err != nil {
+			return invalid()
+		}
+		return protoreflect.ValueOfUint32(uint32(parsed)), nil
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		parsed, err := strconv.ParseUint(text, 10, 64)
+		if err != nil {
+			return invalid()
+		}
+		return protoreflect.ValueOfUint64(parsed), nil
+	case protoreflect.FloatKind:
+		parsed, err := strconv.ParseFloat(text, 32)
+		if err != nil {
+			return invalid()
+		}
+		return protoreflect.ValueOfFloat32(float32(parsed)), nil
+	case protoreflect.DoubleKind:
+		parsed, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			return invalid()
+		}
+		return protoreflect.ValueOfFloat64(parsed), nil
+	case protoreflect.BytesKind:
+		parsed, err := base64.StdEncoding.DecodeString(text)
+		if err != nil {
+			return invalid()
+		}
+		return protoreflect.ValueOfBytes(parsed), nil
+	case protoreflect.EnumKind:
+		if value := field.Enum().Values().ByName(protoreflect.Name(text)); value != nil {
+			return protoreflect.ValueOfEnum(value.Number()), nil
+		}
+		parsed, err := strconv.ParseInt(text, 10, 32)
+		if err != nil || field.Enum().Values().ByNumber(protoreflect.EnumNumber(parsed)) == nil {
+			return invalid()
+		}
+		return protoreflect.ValueOfEnum(protoreflect.EnumNumber(parsed)), nil
+	case protoreflect.MessageKind:
+		var value protoreflect.Value
+		if field.IsList() {
+			value = message.Mutable(field).List().NewElement()
+		} else {
+			value = message.NewField(field)
+		}
+
+		if protojson.Unmarshal([]byte(text), value.Message().Interface()) == nil {
+			return value, nil
+		}
+		proto.Reset(value.Message().Interface())
+		if protojson.Unmarshal([]byte(strconv.Quote(text)), value.Message().Interface()) == nil {
+			return value, nil
+		}
+		return invalid()
+	default:
+		return invalid()
+	}
+}
@@ -0,0 +1,286 @@
+package gatewayServer
+
+import (
+	"encoding/json"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	sso "sso_go_grpc/proto/gen"
+	"strings"
+)
+
+// openApiDocument generates the OpenAPI 3 document of the routes from the descriptors of their messages
+func openApiDocument(routes []*route) []byte {
+	schemas := map[string]any{
+		"Error": map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"code":    map[string]any{"type": "integer", "format": "int32", "description": "gRPC status code"},
+				"status":  map[string]any{"type": "string", "description": "name of the gRPC status code"},
+				"message": map[string]any{"type": "string"},
+			},
+		},
+	}
+
+	paths := make(map[string]map[string]any)
+	for _, route := range routes {
+		if paths[route.pattern] == nil {
+			paths[route.pattern] = make(map[string]any)
+		}
+		paths[route.pattern][strings.ToLower(route.method)] = operation(route, schemas)
+	}
+
+	document := map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title":   "SSO",
+			"version": "v1",
+			"description": "REST/JSON gateway of the UserApi and RoleApi gRPC services. " +
+				"The token can be sent as bearer token instead of the token field.",
+		},
+		"paths": paths,
+		"components": map[string]any{
+			"schemas": schemas,
+			"securitySchemes": map[string]any{
+				"bearer": map[string]any{"type": "http", "scheme": "bearer", "bearerFormat": "JWT"},
+			},
+		},
+	}
+
+	body, err := json.MarshalIndent(document, "", "  ")
+	if err != nil {
+		panic("gateway.openApiDocument: " + err.Error())
+	}
+
+	return body
+}
+
+func operation(route *route, schemas map[string]any) map[string]any {
+	service, method, _ := strings.Cut(route.rpc, ".")
+
+	op := map[string]any{
+		"operationId": method,
+		"tags":        []string{service},
+		"summary":     "gRPC " + route.rpc,
+		"security":    []any{map[string]any{"bearer": []string{}}},
+		"responses": map[string]any{
+			"200": map[string]any{
+				"description": "OK",
+				"content":     map[string]any{responseType(route): map[string]any{"schema": messageSchema(route.response, schemas)}},
+			},
+			"default": map[string]any{
+				"description": "Error, the http status is derived from the gRPC status code",
+				"content":     map[string]any{contentType: map[string]any{"schema": map[string]any{"$ref": "#/components/schemas/Error"}}},
+			},
+		},
+	}
+
+	var parameters []any
+	pathParams := make(map[string]bool)
+
+	for _, segment := range route.segments {
+		name, ok := strings.CutPrefix(segment, "{")
+		if !ok {
+			continue
+		}
+		name = strings.TrimSuffix(name, "}")
+		pathParams[name] = true
+
+		parameters = append(parameters, map[string]any{
+			"name":     name,
+			"in":       "path",
+			"required": true,
+			"schema":   fieldSchema(route.request.Fields().ByJSONName(name), schemas),
+		})
+	}
+
+	if route.body {
+		op["requestBody"] = map[string]any{
+			"required": true,
+			"content":  map[string]any{contentType: map[string]any{"schema": requestSchema(route, schemas)}},
+		}
+	} else {
+		fields := route.request.Fields()
+		for i := 0; i < fields.Len(); i++ {
+			field := fields.Get(i)
+			if pathParams[field.JSONName()] || field.Name() == "token" || field.IsMap() {
+				continue
+			}
+
+			parameters = append(parameters, map[string]any{
+				"name":   field.JSONName(),
+				"in":     "query",
+				"schema": fieldSchema(field, schemas),
+			})
+		}
+	}
+
+	if parameters != nil {
+		op["parameters"] = parameters
+	}
+
+	return op
+}
+
+// requestSchema returns the schema of the json body, for streamed requests the messages are a list in the body
+func requestSchema(route *route, schemas map[string]any) map[string]any {
+	if route.stream == nil || route.stream.requestItems == "" {
+		return messageSchema(route.request, schemas)
+	}
+
+	properties := map[string]any{}
+	fields := route.request.Fields()
+	for i := 0; i < fields.Len(); i++ {
+		field := fields.Get(i)
+		if field.Kind() == protoreflect.MessageKind {
+			properties[route.stream.requestItems] = map[string]any{"type": "array", "items": fieldSchema(field, schemas)}
+			continue
+		}
+		properties[field.JSONName()] = fieldSchema(field, schemas)
+	}
+
+	return map[string]any{"type": "object", "properties": properties}
+}
+
+func responseType(route *route) string {
+	if route.stream != nil && route.stream.responseType != "" {
+		return route.stream.responseType
+	}
+
+	return contentType
+}
+
+// messageSchema returns the schema of the message, messages of the api are referenced components
+func messageSchema(message protoreflect.MessageDescriptor, schemas map[string]any) map[string]any {
+	switch message.FullName() {
+	case "google.protobuf.Timestamp":
+		return map[string]any{"type": "string", "format": "date-time"}
+	case "google.protobuf.Struct":
+		return map[string]any{"type": "object", "additionalProperties": true}
+	case "google.protobuf.Value":
+		return map[string]any{"description": "any json value"}
+	case "google.protobuf.ListValue":
+		return map[string]any{"type": "array", "items": map[string]any{}}
+	case "google.protobuf.FieldMask":
+		return map[string]any{"type": "string", "description": "comma separated field names, e.g. name,description"}
+	}
+
+	name := string(message.Name())
+	ref := map[string]any{"$ref": "#/components/schemas/" + name}
+
+	if _, ok := schemas[name]; ok {
+		return ref
+	}
+
+	properties := map[string]any{}
+	schema := map[string]any{"type": "object", "properties": properties}
+
+	// registered before the fields, so recursive messages reference themselves
+	schemas[name] = schema
+
+	var required []string
+
+	fields := message.Fields()
+	for i := 0; i < fields.Len(); i++ {
+		properties[fields.Get(i).JSONName()] = fieldSchema(fields.Get(i), schemas)
+
+		if fieldRules(fields.Get(i)).GetRequired() {
+			required = append(required, fields.Get(i).JSONName())
+		}
+	}
+
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+
+	return ref
+}
+
+// fieldSchema returns the schema of the field in its protojson encoding
+func fieldSchema(field protoreflect.FieldDescriptor, schemas map[string]any) map[string]any {
+	if field.IsMap() {
+		return map[string]any{"type": "object", "additionalProperties": valueSchema(field.MapValue(), schemas)}
+	}
+
+	rules := fieldRules(field)
+
+	if field.IsList() {
+		schema := map[string]any{"type": "array", "items": withRules(valueSchema(field, schemas), rules.GetItems())}
+		if rules.GetRequired() {
+			schema["minItems"] = 1
+		}
+		if rules.GetMaxItems() > 0 {
+			schema["maxItems"] = rules.GetMaxItems()
+		}
+		return schema
+	}
+
+	return withRules(valueSchema(field, schemas), rules)
+}
+
+// fieldRules returns the validation rules of the field, nil if it has none
+func fieldRules(field protoreflect.FieldDescriptor) *sso.FieldRules {
+	rules, _ := proto.GetExtension(field.Options(), sso.E_Rules).(*sso.FieldRules)
+
+	return rules
+}
+
+// withRules adds the string rules to the schema of a string value
+func withRules(schema map[string]any, rules *sso.FieldRules) map[string]any {
+	if rules == nil || schema["type"] != "string" {
+		return schema
+	}
+
+	if rules.GetMinLen() > 0 {
+		schema["minLength"] = rules.GetMinLen()
+	}
+	if rules.GetMaxLen() > 0 {
+		schema["maxLength"] = rules.GetMaxLen()
+	}
+	if rules.GetPattern() != "" {
+		schema["pattern"] = rules.GetPattern()
+	}
+
+	switch {
+	case rules.GetEmail():
+		schema["format"] = "email"
+	case rules.GetUri():
+		schema["format"] = "uri"
+	}
+
+	return schema
+}
+
+func valueSchema(field protoreflect.FieldDescriptor, schemas map[string]any) map[string]any {
+	switch field.Kind() {
+	case protoreflect.StringKind:
+		return map[string]any{"type": "string"}
+	case protoreflect.BoolKind:
+		return map[string]any{"type": "boolean"}
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		return map[string]any{"type": "integer", "format": "int32"}
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		return map[string]any{"type": "integer", "format": "int64", "minimum": 0}
+	// protojson encodes 64 bit integers as strings
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		return map[string]any{"type": "string", "format": "int64"}
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return map[string]any{"type": "string", "format": "uint64"}
+	case protoreflect.FloatKind:
+		return map[string]any{"type": "number", "format": "float"}
+	case protoreflect.DoubleKind:
+		return map[string]any{"type": "number", "format": "double"}
+	case protoreflect.BytesKind:
+		return map[string]any{"type": "string", "format": "byte"}
+	case protoreflect.EnumKind:
+		var names []string
+		values := field.Enum().Values()
+		for i := 0; i < values.Len(); i++ {
+			names = append(names, string(values.Get(i).Name()))
+		}
+		return map[string]any{"type": "string", "enum": names}
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return messageSchema(field.Message(), schemas)
+	default:
+		return map[string]any{}
+	}
+}
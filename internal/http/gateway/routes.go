@@ -0,0 +1,310 @@
+package gatewayServer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"io"
+	"net/http"
+	sso "sso_go_grpc/proto/gen"
+	"strings"
+)
+
+// route maps one http method and path pattern to one RPC,
+// the path parameters of the pattern ("{userId}") are fields of the request
+type route struct {
+	method   string
+	pattern  string
+	segments []string
+	// rpc is the name of the service and method, e.g. UserApi.GetUserById
+	rpc string
+	// body routes read the request from the json body, the others from the query parameters
+	body     bool
+	request  protoreflect.MessageDescriptor
+	response protoreflect.MessageDescriptor
+	// stream is set for the streaming RPCs, they have their own request and response formats
+	stream *streamFormat
+	handle func(w http.ResponseWriter, r *http.Request, params map[string]string)
+}
+
+// streamFormat describes the http representation of a streaming RPC for the OpenAPI document
+type streamFormat struct {
+	// requestItems is the field of the json body holding the streamed requests, empty if the requests are not streamed
+	requestItems string
+	// responseType is the content type of the streamed responses, empty if the responses are not streamed
+	responseType string
+}
+
+func newRoute(method, pattern, rpc string) *route {
+	return &route{
+		method:   method,
+		pattern:  pattern,
+		segments: strings.Split(strings.Trim(pattern, "/"), "/"),
+		rpc:      rpc,
+	}
+}
+
+// match returns the path parameters if the path matches the pattern
+func (rt *route) match(path string) (map[string]string, bool) {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	if len(segments) != len(rt.segments) {
+		return nil, false
+	}
+
+	params := make(map[string]string)
+	for i, segment := range rt.segments {
+		if name, ok := strings.CutPrefix(segment, "{"); ok {
+			params[strings.TrimSuffix(name, "}")] = segments[i]
+			continue
+		}
+
+		if segment != segments[i] {
+			return nil, false
+		}
+	}
+
+	return params, true
+}
+
+func (rt *route) serve(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	rt.handle(w, r, params)
+}
+
+// unary returns the route of a unary RPC; the request is read from the body or the query parameters,
+// then the path parameters and the bearer token are set
+func unary[Req proto.Message, Res proto.Message](
+	method, pattern, rpc string,
+	body bool,
+	call func(ctx context.Context, req Req, opts ...grpc.CallOption) (Res, error),
+) *route {
+	var request Req
+	var response Res
+
+	rt := newRoute(method, pattern, rpc)
+	rt.body = body
+	rt.request = request.ProtoReflect().Descriptor()
+	rt.response = response.ProtoReflect().Descriptor()
+
+	rt.handle = func(w http.ResponseWriter, r *http.Request, params map[string]string) {
+		req := request.ProtoReflect().New().Interface().(Req)
+
+		if err := decodeRequest(w, r, req.ProtoReflect(), body, params); err != nil {
+			writeError(w, status.Convert(err), 0)
+			return
+		}
+
+		res, err := call(r.Context(), req)
+		if err != nil {
+			writeError(w, status.Convert(err), 0)
+			return
+		}
+
+		writeMessage(w, res)
+	}
+
+	return rt
+}
+
+// decodeRequest fills the request from the body or the query, the path parameters and the bearer token
+func decodeRequest(w http.ResponseWriter, r *http.Request, req protoreflect.Message, body bool, params map[string]string) error {
+	if body {
+		data, err := readBody(w, r)
+		if err != nil {
+			return err
+		}
+
+		if len(data) > 0 {
+			if err = protojson.Unmarshal(data, req.Interface()); err != nil {
+				return status.Error(codes.InvalidArgument, "invalid request body: "+err.Error())
+			}
+		}
+	} else {
+		for name, values := range r.URL.Query() {
+			if err := setField(req, name, values); err != nil {
+				return err
+			}
+		}
+	}
+
+	for name, value := range params {
+		if err := setField(req, name, []string{value}); err != nil {
+			return err
+		}
+	}
+
+	return setToken(req, r)
+}
+
+// setToken sets the token field to the bearer token if the request has no token yet
+func setToken(req protoreflect.Message, r *http.Request) error {
+	field := req.Descriptor().Fields().ByName("token")
+	if field == nil || req.Get(field).String() != "" {
+		return nil
+	}
+
+	if token := bearerToken(r); token != "" {
+		req.Set(field, protoreflect.ValueOfString(token))
+	}
+
+	return nil
+}
+
+// newRoutes returns the routes of all UserApi and RoleApi RPCs,
+// routes with literal segments come before the routes with parameters at that position
+func (s *serverApi) newRoutes() []*route {
+	return []*route{
+		unary(http.MethodPost, "/v1/register", "UserApi.Register", true, s.userClient.Register),
+		unary(http.MethodPost, "/v1/login", "UserApi.Login", true, s.userClient.Login),
+		unary(http.MethodGet, "/v1/users", "UserApi.ListUsers", false, s.userClient.ListUsers),
+		s.importUsers(),
+		s.watchUserChanges(),
+		unary(http.MethodGet, "/v1/users/by-email/{email}", "UserApi.GetUserByEmail", false, s.userClient.GetUserByEmail),
+		unary(http.MethodGet, "/v1/users/{userId}", "UserApi.GetUserById", false, s.userClient.GetUserById),
+		unary(http.MethodPatch, "/v1/users/{userId}/attributes", "UserApi.UpdateUserAttributes", true, s.userClient.UpdateUserAttributes),
+		unary(http.MethodGet, "/v1/users/{userId}/export", "UserApi.ExportUserData", false, s.userClient.ExportUserData),
+		unary(http.MethodGet, "/v1/users/{userId}/logins", "UserApi.ListLoginHistory", false, s.userClient.ListLoginHistory),
+
+		unary(http.MethodPost, "/v1/users/{userId}/roles/verify", "RoleApi.VerifyUserRoles", true, s.roleClient.VerifyUserRoles),
+		unary(http.MethodPut, "/v1/users/{userId}/roles/{roleId}", "RoleApi.AddUserRole", true, s.roleClient.AddUserRole),
+		unary(http.MethodDelete, "/v1/users/{userId}/roles/{roleId}", "RoleApi.RemoveUserRole", false, s.roleClient.RemoveUserRole),
+		unary(http.MethodPost, "/v1/roles", "RoleApi.CreateRole", true, s.roleClient.CreateRole),
+		unary(http.MethodPatch, "/v1/roles/{roleId}", "RoleApi.UpdateRole", true, s.roleClient.UpdateRole),
+		unary(http.MethodDelete, "/v1/roles/{roleId}", "RoleApi.DeleteRole", false, s.roleClient.DeleteRole),
+	}
+}
+
+// importUsers returns the route of the client streaming ImportUsers:
+// the body is {"token": "", "dryRun": false, "users": [ImportUser, ...]}, every user is sent as one message
+func (s *serverApi) importUsers() *route {
+	rt := newRoute(http.MethodPost, "/v1/users/import", "UserApi.ImportUsers")
+	rt.body = true
+	rt.request = (&sso.ImportUsersRequest{}).ProtoReflect().Descriptor()
+	rt.response = (&sso.ImportUsersResponse{}).ProtoReflect().Descriptor()
+	rt.stream = &streamFormat{requestItems: "users"}
+
+	rt.handle = func(w http.ResponseWriter, r *http.Request, params map[string]string) {
+		data, err := readBody(w, r)
+		if err != nil {
+			writeError(w, status.Convert(err), 0)
+			return
+		}
+
+		var body struct {
+			Token  string            `json:"token"`
+			DryRun bool              `json:"dryRun"`
+			Users  []json.RawMessage `json:"users"`
+		}
+		if err = json.Unmarshal(data, &body); err != nil {
+			writeError(w, status.New(codes.InvalidArgument, "invalid request body: "+err.Error()), 0)
+			return
+		}
+
+		first := &sso.ImportUsersRequest{Token: body.Token, DryRun: body.DryRun}
+		if err = setToken(first.ProtoReflect(), r); err != nil {
+			writeError(w, status.Convert(err), 0)
+			return
+		}
+
+		// all users are decoded before the import starts, so an invalid body imports nobody
+		requests := []*sso.ImportUsersRequest{first}
+		for i, raw := range body.Users {
+			user := &sso.ImportUser{}
+			if err = protojson.Unmarshal(raw, user); err != nil {
+				writeError(w, status.Newf(codes.InvalidArgument, "invalid user %d: %s", i, err), 0)
+				return
+			}
+			requests = append(requests, &sso.ImportUsersRequest{User: user})
+		}
+
+		stream, err := s.userClient.ImportUsers(r.Context())
+		if err != nil {
+			writeError(w, status.Convert(err), 0)
+			return
+		}
+
+		for _, req := range requests {
+			// on io.EOF the server ended the stream, its status is returned by CloseAndRecv
+			if err = stream.Send(req); err != nil {
+				break
+			}
+		}
+
+		res, err := stream.CloseAndRecv()
+		if err != nil {
+			writeError(w, status.Convert(err), 0)
+			return
+		}
+
+		writeMessage(w, res)
+	}
+
+	return rt
+}
+
+// watchUserChanges returns the route of the server streaming WatchUserChanges:
+// every change is written as one line of json (application/x-ndjson) as soon as it arrives,
+// an error after the first change is written as a last line {"error": {...}}
+func (s *serverApi) watchUserChanges() *route {
+	rt := newRoute(http.MethodGet, "/v1/users/changes", "UserApi.WatchUserChanges")
+	rt.request = (&sso.WatchUserChangesRequest{}).ProtoReflect().Descriptor()
+	rt.response = (&sso.UserChange{}).ProtoReflect().Descriptor()
+	rt.stream = &streamFormat{responseType: "application/x-ndjson"}
+
+	rt.handle = func(w http.ResponseWriter, r *http.Request, params map[string]string) {
+		req := &sso.WatchUserChangesRequest{}
+		if err := decodeRequest(w, r, req.ProtoReflect(), false, params); err != nil {
+			writeError(w, status.Convert(err), 0)
+			return
+		}
+
+		stream, err := s.userClient.WatchUserChanges(r.Context(), req)
+		if err != nil {
+			writeError(w, status.Convert(err), 0)
+			return
+		}
+
+		// the server sends the headers once the watch is set up, errors before are normal error responses
+		if _, err = stream.Header(); err != nil {
+			writeError(w, status.Convert(err), 0)
+			return
+		}
+
+		flusher, _ := w.(http.Flusher)
+
+		w.Header().Set("Content-Type", rt.stream.responseType)
+		w.WriteHeader(http.StatusOK)
+		if flusher != nil {
+			flusher.Flush()
+		}
+
+		for {
+			change, err := stream.Recv()
+			if err != nil {
+				if !errors.Is(err, io.EOF) && r.Context().Err() == nil {
+					w.Write([]byte(`{"error":`))
+					w.Write(errorBody(status.Convert(err)))
+					w.Write([]byte("}\n"))
+				}
+				return
+			}
+
+			line, err := protojson.MarshalOptions{}.Marshal(change)
+			if err != nil {
+				return
+			}
+
+			w.Write(append(line, '\n'))
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+	}
+
+	return rt
+}
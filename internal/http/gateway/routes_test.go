@@ -0,0 +1,134 @@
+package gatewayServer
+
+import (
+	"context"
+	"errors"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+	"io"
+	"log/slog"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	sso "sso_go_grpc/proto/gen"
+	"strings"
+	"testing"
+)
+
+func TestRouteMatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+		path    string
+		want    map[string]string
+		wantOk  bool
+	}{
+		{name: "literal", pattern: "/v1/users", path: "/v1/users", want: map[string]string{}, wantOk: true},
+		{name: "trailing slash", pattern: "/v1/users", path: "/v1/users/", want: map[string]string{}, wantOk: true},
+		{name: "other literal", pattern: "/v1/users", path: "/v1/roles"},
+		{name: "parameter", pattern: "/v1/users/{userId}", path: "/v1/users/42", want: map[string]string{"userId": "42"}, wantOk: true},
+		{
+			name:    "two parameters",
+			pattern: "/v1/users/{userId}/roles/{roleId}",
+			path:    "/v1/users/42/roles/7",
+			want:    map[string]string{"userId": "42", "roleId": "7"},
+			wantOk:  true,
+		},
+		{name: "parameter and other literal", pattern: "/v1/users/{userId}/export", path: "/v1/users/42/logins"},
+		{name: "fewer segments", pattern: "/v1/users/{userId}", path: "/v1/users"},
+		{name: "more segments", pattern: "/v1/users/{userId}", path: "/v1/users/42/export"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := newRoute(http.MethodGet, tt.pattern, "UserApi.GetUserById").match(tt.path)
+			if ok != tt.wantOk {
+				t.Fatalf("match(%q) ok = %t, want %t", tt.path, ok, tt.wantOk)
+			}
+			if !maps.Equal(got, tt.want) {
+				t.Errorf("match(%q) = %v, want %v", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+// recordingConn records the RPCs the gateway calls, unary calls succeed with an empty response
+type recordingConn struct {
+	method  string
+	request proto.Message
+}
+
+func (c *recordingConn) Invoke(ctx context.Context, method string, args any, reply any, opts ...grpc.CallOption) error {
+	c.method = method
+	c.request = args.(proto.Message)
+	return nil
+}
+
+func (c *recordingConn) NewStream(ctx context.Context, desc *grpc.StreamDesc, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	c.method = method
+	return nil, errors.New("streams are not recorded")
+}
+
+func TestServeRoutes(t *testing.T) {
+	tests := []struct {
+		method     string
+		path       string
+		wantStatus int
+		wantRpc    string
+	}{
+		{http.MethodPost, "/v1/login", http.StatusOK, "/api.UserApi/Login"},
+		{http.MethodGet, "/v1/users", http.StatusOK, "/api.UserApi/ListUsers"},
+		{http.MethodPost, "/v1/users/import", http.StatusInternalServerError, "/api.UserApi/ImportUsers"},
+		{http.MethodGet, "/v1/users/by-email/jane@example.com", http.StatusOK, "/api.UserApi/GetUserByEmail"},
+		{http.MethodGet, "/v1/users/42", http.StatusOK, "/api.UserApi/GetUserById"},
+		{http.MethodGet, "/v1/users/42/export", http.StatusOK, "/api.UserApi/ExportUserData"},
+		{http.MethodPost, "/v1/users/42/roles/verify", http.StatusOK, "/api.RoleApi/VerifyUserRoles"},
+		{http.MethodPut, "/v1/users/42/roles/7", http.StatusOK, "/api.RoleApi/AddUserRole"},
+		{http.MethodDelete, "/v1/users/42/roles/7", http.StatusOK, "/api.RoleApi/RemoveUserRole"},
+		{http.MethodPatch, "/v1/roles/7", http.StatusOK, "/api.RoleApi/UpdateRole"},
+		{http.MethodDelete, "/v1/roles/7", http.StatusOK, "/api.RoleApi/DeleteRole"},
+
+		{http.MethodDelete, "/v1/users/42", http.StatusMethodNotAllowed, ""},
+		{http.MethodGet, "/v1/groups", http.StatusNotFound, ""},
+		{http.MethodGet, "/v1/users/42/roles/7/extra", http.StatusNotFound, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			conn := &recordingConn{}
+			handler := NewHandler(conn, slog.New(slog.NewTextHandler(io.Discard, nil)), CorsConfig{})
+
+			var body io.Reader
+			if tt.method != http.MethodGet && tt.method != http.MethodDelete {
+				body = strings.NewReader("{}")
+			}
+
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, httptest.NewRequest(tt.method, tt.path, body))
+
+			if recorder.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d, body %s", recorder.Code, tt.wantStatus, recorder.Body)
+			}
+			if conn.method != tt.wantRpc {
+				t.Errorf("rpc = %q, want %q", conn.method, tt.wantRpc)
+			}
+		})
+	}
+}
+
+func TestServePathParameters(t *testing.T) {
+	conn := &recordingConn{}
+	handler := NewHandler(conn, slog.New(slog.NewTextHandler(io.Discard, nil)), CorsConfig{})
+
+	request := httptest.NewRequest(http.MethodPut, "/v1/users/42/roles/7", strings.NewReader("{}"))
+	request.Header.Set("Authorization", "Bearer admin-token")
+	handler.ServeHTTP(httptest.NewRecorder(), request)
+
+	req, ok := conn.request.(*sso.AddUserRoleRequest)
+	if !ok {
+		t.Fatalf("request = %T, want *sso.AddUserRoleRequest", conn.request)
+	}
+	if req.GetUserId() != 42 || req.GetRoleId() != 7 || req.GetToken() != "admin-token" {
+		t.Errorf("request = %v, want userId 42, roleId 7 and the bearer token", req)
+	}
+}
@@ -0,0 +1,234 @@
+package gatewayServer
+
+import (
+	"context"
+	"encoding/json"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"sso_go_grpc/internal/lib/client"
+	"sso_go_grpc/internal/lib/requestid"
+	"sso_go_grpc/internal/lib/tracing"
+	sso "sso_go_grpc/proto/gen"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// OpenApiPath is the path the OpenAPI document of the gateway is served at
+	OpenApiPath = "/openapi.json"
+
+	contentType     = "application/json"
+	maxRequestBytes = 4 << 20
+)
+
+// CorsConfig configures which browser origins may call the gateway
+type CorsConfig struct {
+	// AllowedOrigins are the allowed origins, "*" allows every origin, empty disables CORS
+	AllowedOrigins []string
+	MaxAge         time.Duration
+}
+
+type serverApi struct {
+	userClient sso.UserApiClient
+	roleClient sso.RoleApiClient
+	log        *slog.Logger
+	cors       CorsConfig
+	routes     []*route
+	openApi    []byte
+}
+
+// NewHandler returns the handler of the REST/JSON routes of UserApi and RoleApi,
+// every request is forwarded to the gRPC server behind conn
+func NewHandler(conn grpc.ClientConnInterface, log *slog.Logger, cors CorsConfig) http.Handler {
+	s := &serverApi{
+		userClient: sso.NewUserApiClient(conn),
+		roleClient: sso.NewRoleApiClient(conn),
+		log:        log,
+		cors:       cors,
+	}
+
+	s.routes = s.newRoutes()
+	s.openApi = openApiDocument(s.routes)
+
+	return s.withCors(http.HandlerFunc(s.serve))
+}
+
+// serve dispatches the request to the first route matching its method and path
+func (s *serverApi) serve(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == OpenApiPath && r.Method == http.MethodGet {
+		w.Header().Set("Content-Type", contentType)
+		w.Write(s.openApi)
+		return
+	}
+
+	pathMatched := false
+	for _, route := range s.routes {
+		params, ok := route.match(r.URL.Path)
+		if !ok {
+			continue
+		}
+
+		pathMatched = true
+		if route.method != r.Method {
+			continue
+		}
+
+		route.serve(w, r.WithContext(outgoingContext(w, r)), params)
+		return
+	}
+
+	if pathMatched {
+		writeError(w, status.New(codes.Unimplemented, "method not allowed"), http.StatusMethodNotAllowed)
+		return
+	}
+	writeError(w, status.New(codes.NotFound, "route does not exist"), http.StatusNotFound)
+}
+
+// withCors answers the preflight requests and sets the CORS headers of allowed origins
+func (s *serverApi) withCors(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" || !s.allowedOrigin(origin) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Add("Vary", "Origin")
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+		w.Header().Set("Access-Control-Expose-Headers", "X-Request-Id")
+
+		// preflight requests are answered without reaching the routes
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE")
+			w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type, X-Request-Id, Idempotency-Key, Traceparent, Tracestate")
+			w.Header().Set("Access-Control-Max-Age", strconv.Itoa(int(s.cors.MaxAge.Seconds())))
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *serverApi) allowedOrigin(origin string) bool {
+	for _, allowed := range s.cors.AllowedOrigins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// outgoingContext forwards the request id, ip address, user agent and idempotency key of the http client
+// to the gRPC server, requests without a valid X-Request-Id get a new one, it is sent back in the response
+func outgoingContext(w http.ResponseWriter, r *http.Request) context.Context {
+	md := metadata.MD{}
+
+	requestId := r.Header.Get("X-Request-Id")
+	if !requestid.Valid(requestId) {
+		requestId = requestid.New()
+	}
+	w.Header().Set("X-Request-Id", requestId)
+	md.Set(client.RequestIdKey, requestId)
+
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		md.Set(client.ForwardedForKey, host)
+	}
+	if userAgent := r.UserAgent(); userAgent != "" {
+		md.Set(client.ForwardedUserAgentKey, userAgent)
+	}
+	if idempotencyKey := r.Header.Get("Idempotency-Key"); idempotencyKey != "" {
+		md.Set(client.IdempotencyKey, idempotencyKey)
+	}
+
+	// the W3C trace context of the http client is passed on to the gRPC server
+	tracing.Inject(otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header)), md)
+
+	return metadata.NewOutgoingContext(r.Context(), md)
+}
+
+// bearerToken returns the token of the Authorization header, empty if there is none
+func bearerToken(r *http.Request) string {
+	token, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if token == r.Header.Get("Authorization") {
+		return ""
+	}
+
+	return token
+}
+
+// readBody reads the request body, bodies larger than maxRequestBytes are rejected
+func readBody(w http.ResponseWriter, r *http.Request) ([]byte, error) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxRequestBytes))
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "can not read request body: "+err.Error())
+	}
+
+	return body, nil
+}
+
+var marshalOptions = protojson.MarshalOptions{EmitUnpopulated: true}
+
+func writeMessage(w http.ResponseWriter, message proto.Message) {
+	body, err := marshalOptions.Marshal(message)
+	if err != nil {
+		writeError(w, status.New(codes.Internal, "Internal Server Error"), 0)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(http.StatusOK)
+	w.Write(body)
+}
+
+// writeError writes the status as json error body, httpStatus 0 is derived from the status code
+func writeError(w http.ResponseWriter, st *status.Status, httpStatus int) {
+	if httpStatus == 0 {
+		httpStatus = HTTPStatus(st.Code())
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(httpStatus)
+	w.Write(errorBody(st))
+}
+
+// errorResponse is the json error body, it mirrors the gRPC status
+type errorResponse struct {
+	// Code is the gRPC status code, Status its name
+	Code    codes.Code `json:"code"`
+	Status  string     `json:"status"`
+	Message string     `json:"message"`
+	// Details are the google.rpc error details, like ErrorInfo and BadRequest, with their @type
+	Details []json.RawMessage `json:"details,omitempty"`
+}
+
+func errorBody(st *status.Status) []byte {
+	res := &errorResponse{
+		Code:    st.Code(),
+		Status:  codeNames[st.Code()],
+		Message: st.Message(),
+	}
+
+	for _, detail := range st.Proto().GetDetails() {
+		// details of unknown types can not be written as json and are left out
+		if raw, err := protojson.Marshal(detail); err == nil {
+			res.Details = append(res.Details, raw)
+		}
+	}
+
+	body, _ := json.Marshal(res)
+
+	return body
+}
@@ -0,0 +1,156 @@
+package scimServer
+
+import (
+	"net/http"
+	"strings"
+)
+
+// serviceProviderConfig handles /ServiceProviderConfig
+func (s *serverApi) serviceProviderConfig(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"schemas":          []string{schemaSPConfig},
+		"documentationUri": "",
+		"patch":            map[string]any{"supported": true},
+		"bulk":             map[string]any{"supported": false, "maxOperations": 0, "maxPayloadSize": 0},
+		"filter":           map[string]any{"supported": true, "maxResults": maxCount},
+		"changePassword":   map[string]any{"supported": true},
+		"sort":             map[string]any{"supported": false},
+		"etag":             map[string]any{"supported": true},
+		"authenticationSchemes": []map[string]any{{
+			"type":        "oauthbearertoken",
+			"name":        "Bearer Token",
+			"description": "Authentication with the configured scim bearer token",
+			"primary":     true,
+		}},
+		"meta": map[string]any{
+			"resourceType": "ServiceProviderConfig",
+			"location":     baseUrl(r) + "/ServiceProviderConfig",
+		},
+	})
+}
+
+// resourceTypes handles /ResourceTypes
+func (s *serverApi) resourceTypes(w http.ResponseWriter, r *http.Request) {
+	types := []any{
+		resourceType(r, "User", "/Users", schemaUser),
+		resourceType(r, "Group", "/Groups", schemaGroup),
+	}
+
+	response := newListResponse(uint64(len(types)), 1)
+	response.Resources = types
+	response.ItemsPerPage = len(types)
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+// schemas handles /Schemas and /Schemas/{id}
+func (s *serverApi) schemas(w http.ResponseWriter, r *http.Request) {
+	schemas := []map[string]any{userSchema(r), groupSchema(r)}
+
+	if id := strings.TrimPrefix(r.URL.Path, BasePath+"/Schemas/"); id != r.URL.Path {
+		for _, schema := range schemas {
+			if schema["id"] == id {
+				writeJSON(w, http.StatusOK, schema)
+				return
+			}
+		}
+
+		writeError(w, http.StatusNotFound, "", "schema does not exist")
+		return
+	}
+
+	response := newListResponse(uint64(len(schemas)), 1)
+	for _, schema := range schemas {
+		response.Resources = append(response.Resources, schema)
+	}
+	response.ItemsPerPage = len(schemas)
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+func resourceType(r *http.Request, name, endpoint, schema string) map[string]any {
+	return map[string]any{
+		"schemas":  []string{schemaResourceType},
+		"id":       name,
+		"name":     name,
+		"endpoint": endpoint,
+		"schema":   schema,
+		"meta": map[string]any{
+			"resourceType": "ResourceType",
+			"location":     baseUrl(r) + "/ResourceTypes/" + name,
+		},
+	}
+}
+
+func userSchema(r *http.Request) map[string]any {
+	return map[string]any{
+		"schemas":     []string{schemaSchema},
+		"id":          schemaUser,
+		"name":        "User",
+		"description": "User Account",
+		"attributes": []map[string]any{
+			attribute("userName", "string", true, "readWrite", "server", nil),
+			attribute("password", "string", false, "writeOnly", "none", nil),
+			attribute("active", "boolean", false, "readWrite", "none", nil),
+			attribute("emails", "complex", false, "readWrite", "none", []map[string]any{
+				attribute("value", "string", true, "readWrite", "server", nil),
+				attribute("type", "string", false, "readWrite", "none", nil),
+				attribute("primary", "boolean", false, "readWrite", "none", nil),
+			}),
+			attribute("groups", "complex", false, "readOnly", "none", []map[string]any{
+				attribute("value", "string", false, "readOnly", "none", nil),
+				attribute("display", "string", false, "readOnly", "none", nil),
+				attribute("$ref", "reference", false, "readOnly", "none", nil),
+			}),
+		},
+		"meta": map[string]any{
+			"resourceType": "Schema",
+			"location":     baseUrl(r) + "/Schemas/" + schemaUser,
+		},
+	}
+}
+
+func groupSchema(r *http.Request) map[string]any {
+	return map[string]any{
+		"schemas":     []string{schemaSchema},
+		"id":          schemaGroup,
+		"name":        "Group",
+		"description": "Group, a role of the sso",
+		"attributes": []map[string]any{
+			attribute("displayName", "string", true, "readWrite", "server", nil),
+			attribute("members", "complex", false, "readWrite", "none", []map[string]any{
+				attribute("value", "string", false, "immutable", "none", nil),
+				attribute("display", "string", false, "readOnly", "none", nil),
+				attribute("$ref", "reference", false, "immutable", "none", nil),
+			}),
+		},
+		"meta": map[string]any{
+			"resourceType": "Schema",
+			"location":     baseUrl(r) + "/Schemas/" + schemaGroup,
+		},
+	}
+}
+
+// attribute returns the schema of one attribute, complex attributes have sub attributes
+func attribute(name, kind string, required bool, mutability, uniqueness string, subAttributes []map[string]any) map[string]any {
+	schema := map[string]any{
+		"name":        name,
+		"type":        kind,
+		"multiValued": subAttributes != nil,
+		"required":    required,
+		"caseExact":   false,
+		"mutability":  mutability,
+		"returned":    "default",
+		"uniqueness":  uniqueness,
+	}
+
+	if mutability == "writeOnly" {
+		schema["returned"] = "never"
+	}
+
+	if subAttributes != nil {
+		schema["subAttributes"] = subAttributes
+	}
+
+	return schema
+}
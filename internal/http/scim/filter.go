@@ -0,0 +1,119 @@
+package scimServer
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sso_go_grpc/internal/domain/models"
+	"strconv"
+	"strings"
+)
+
+var errInvalidFilter = errors.New("invalid filter")
+
+// conditionRegexp matches one `attribute eq value` condition of a filter
+var conditionRegexp = regexp.MustCompile(`(?i)^\s*([a-z][a-z0-9.:_\-]*)\s+eq\s+("(?:[^"\\]|\\.)*"|true|false)\s*`)
+
+// andRegexp matches the "and" between two conditions
+var andRegexp = regexp.MustCompile(`(?i)^and\s+`)
+
+// parseFilter parses the supported subset of the SCIM filter syntax:
+// `attribute eq value` conditions joined with "and"; it returns the value of every attribute,
+// attribute names are lowercase
+func parseFilter(filter string) (map[string]string, error) {
+	conditions := make(map[string]string)
+	rest := strings.TrimSpace(filter)
+
+	for rest != "" {
+		match := conditionRegexp.FindStringSubmatch(rest)
+		if match == nil {
+			return nil, fmt.Errorf("%w: only `attribute eq value` joined with and is supported", errInvalidFilter)
+		}
+
+		value := match[2]
+		if strings.HasPrefix(value, `"`) {
+			unquoted, err := strconv.Unquote(value)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %s", errInvalidFilter, value)
+			}
+			value = unquoted
+		}
+
+		conditions[attributeName(match[1])] = value
+
+		rest = rest[len(match[0]):]
+		if rest == "" {
+			break
+		}
+
+		and := andRegexp.FindString(rest)
+		if and == "" {
+			return nil, fmt.Errorf("%w: only `attribute eq value` joined with and is supported", errInvalidFilter)
+		}
+		rest = rest[len(and):]
+	}
+
+	return conditions, nil
+}
+
+// userFilter converts the filter of a user listing to the user filter
+func userFilter(filter string) (models.UserFilter, error) {
+	var userFilter models.UserFilter
+
+	conditions, err := parseFilter(filter)
+	if err != nil {
+		return userFilter, err
+	}
+
+	for attribute, value := range conditions {
+		switch attribute {
+		case "username":
+			userFilter.Username = value
+		case "emails", "emails.value":
+			userFilter.Email = value
+		case "active":
+			switch strings.ToLower(value) {
+			case "true":
+				userFilter.Status = models.UserStatusActive
+			case "false":
+				userFilter.Status = models.UserStatusDisabled
+			default:
+				return userFilter, fmt.Errorf("%w: active has to be true or false", errInvalidFilter)
+			}
+		default:
+			return userFilter, fmt.Errorf("%w: filtering by %s is not supported", errInvalidFilter, attribute)
+		}
+	}
+
+	return userFilter, nil
+}
+
+// groupFilter returns the displayName of a group listing filter
+func groupFilter(filter string) (string, error) {
+	conditions, err := parseFilter(filter)
+	if err != nil {
+		return "", err
+	}
+
+	var displayName string
+
+	for attribute, value := range conditions {
+		if attribute != "displayname" {
+			return "", fmt.Errorf("%w: filtering by %s is not supported", errInvalidFilter, attribute)
+		}
+		displayName = value
+	}
+
+	return displayName, nil
+}
+
+// attributeName returns the lowercase attribute name without the schema urn
+func attributeName(attribute string) string {
+	attribute = strings.ToLower(attribute)
+
+	if strings.HasPrefix(attribute, "urn:") {
+		attribute = attribute[strings.LastIndex(attribute, ":")+1:]
+	}
+
+	return attribute
+}
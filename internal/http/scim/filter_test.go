@@ -0,0 +1,112 @@
+package scimServer
+
+import (
+	"errors"
+	"maps"
+	"sso_go_grpc/internal/domain/models"
+	"testing"
+)
+
+func TestParseFilter(t *testing.T) {
+	tests := []struct {
+		name    string
+		filter  string
+		want    map[string]string
+		wantErr bool
+	}{
+		{name: "empty", filter: "", want: map[string]string{}},
+		{name: "one condition", filter: `userName eq "jane"`, want: map[string]string{"username": "jane"}},
+		{name: "operator in any case", filter: `userName EQ "jane"`, want: map[string]string{"username": "jane"}},
+		{name: "escaped quote", filter: `userName eq "ja\"ne"`, want: map[string]string{"username": `ja"ne`}},
+		{name: "boolean", filter: "active eq true", want: map[string]string{"active": "true"}},
+		{name: "sub attribute", filter: `emails.value eq "jane@example.com"`, want: map[string]string{"emails.value": "jane@example.com"}},
+		{
+			name:   "schema urn",
+			filter: `urn:ietf:params:scim:schemas:core:2.0:User:userName eq "jane"`,
+			want:   map[string]string{"username": "jane"},
+		},
+		{
+			name:   "and",
+			filter: ` userName eq "jane"  and active eq false `,
+			want:   map[string]string{"username": "jane", "active": "false"},
+		},
+		{name: "other operator", filter: `userName co "jan"`, wantErr: true},
+		{name: "or", filter: `userName eq "jane" or userName eq "john"`, wantErr: true},
+		{name: "trailing and", filter: `userName eq "jane" and`, wantErr: true},
+		{name: "unquoted value", filter: "userName eq jane", wantErr: true},
+		{name: "unterminated string", filter: `userName eq "jane`, wantErr: true},
+		{name: "invalid escape", filter: `userName eq "ja\qne"`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseFilter(tt.filter)
+			if tt.wantErr {
+				if !errors.Is(err, errInvalidFilter) {
+					t.Errorf("parseFilter(%q) error = %v, want errInvalidFilter", tt.filter, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("parseFilter(%q) error = %v", tt.filter, err)
+			}
+			if !maps.Equal(got, tt.want) {
+				t.Errorf("parseFilter(%q) = %v, want %v", tt.filter, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUserFilter(t *testing.T) {
+	tests := []struct {
+		name    string
+		filter  string
+		want    models.UserFilter
+		wantErr bool
+	}{
+		{name: "username", filter: `userName eq "jane"`, want: models.UserFilter{Username: "jane"}},
+		{name: "email", filter: `emails eq "jane@example.com"`, want: models.UserFilter{Email: "jane@example.com"}},
+		{name: "active", filter: "active eq true", want: models.UserFilter{Status: models.UserStatusActive}},
+		{name: "inactive", filter: `active eq "False"`, want: models.UserFilter{Status: models.UserStatusDisabled}},
+		{name: "invalid active", filter: `active eq "yes"`, wantErr: true},
+		{name: "unsupported attribute", filter: `displayName eq "Jane"`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := userFilter(tt.filter)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("userFilter(%q) error = %v, wantErr %t", tt.filter, err, tt.wantErr)
+			}
+			if !tt.wantErr && (got.Username != tt.want.Username || got.Email != tt.want.Email || got.Status != tt.want.Status) {
+				t.Errorf("userFilter(%q) = %+v, want %+v", tt.filter, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGroupFilter(t *testing.T) {
+	tests := []struct {
+		name    string
+		filter  string
+		want    string
+		wantErr bool
+	}{
+		{name: "no filter", filter: ""},
+		{name: "display name", filter: `displayName eq "admins"`, want: "admins"},
+		{name: "unsupported attribute", filter: `id eq "1"`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := groupFilter(tt.filter)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("groupFilter(%q) error = %v, wantErr %t", tt.filter, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("groupFilter(%q) = %q, want %q", tt.filter, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,250 @@
+package scimServer
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+var errInvalidPatch = errors.New("invalid patch")
+
+// memberPathRegexp matches the `members[value eq "id"]` path of a group patch
+var memberPathRegexp = regexp.MustCompile(`(?i)^members\[\s*value\s+eq\s+"([^"]*)"\s*]$`)
+
+// patchUser applies the patch operations to the user resource and returns the new password;
+// unsupported attributes like name or externalId are ignored
+func patchUser(resource *user, patch *patchRequest) (password string, err error) {
+	set := func(path string, value any) error {
+		switch attribute := attributeName(path); {
+		case attribute == "username":
+			str, ok := value.(string)
+			if !ok {
+				return fmt.Errorf("%w: userName has to be a string", errInvalidPatch)
+			}
+			resource.UserName = str
+		case attribute == "active":
+			active, err := parseBool(value)
+			if err != nil {
+				return err
+			}
+			resource.Active = &active
+		case attribute == "password":
+			str, ok := value.(string)
+			if !ok {
+				return fmt.Errorf("%w: password has to be a string", errInvalidPatch)
+			}
+			password = str
+		case attribute == "emails":
+			emails, err := parseEmails(value)
+			if err != nil {
+				return err
+			}
+			resource.Emails = emails
+		case strings.HasPrefix(attribute, "emails"):
+			// emails.value or emails[type eq "work"].value
+			str, ok := value.(string)
+			if !ok {
+				return fmt.Errorf("%w: email has to be a string", errInvalidPatch)
+			}
+			resource.Emails = []email{{Value: str, Primary: true}}
+		}
+		return nil
+	}
+
+	for _, operation := range patch.Operations {
+		switch strings.ToLower(operation.Op) {
+		case "add", "replace":
+			if operation.Path != "" {
+				if err = set(operation.Path, operation.Value); err != nil {
+					return "", err
+				}
+				continue
+			}
+
+			// without path the value is an object of attributes
+			values, ok := operation.Value.(map[string]any)
+			if !ok {
+				return "", fmt.Errorf("%w: value has to be an object without path", errInvalidPatch)
+			}
+
+			for path, value := range values {
+				if err = set(path, value); err != nil {
+					return "", err
+				}
+			}
+		case "remove":
+			switch attributeName(operation.Path) {
+			case "username", "active", "emails", "password":
+				return "", fmt.Errorf("%w: %s can not be removed", errInvalidPatch, operation.Path)
+			}
+		default:
+			return "", fmt.Errorf("%w: unknown op %q", errInvalidPatch, operation.Op)
+		}
+	}
+
+	return password, nil
+}
+
+// patchGroup applies the patch operations to the group resource
+func patchGroup(resource *group, patch *patchRequest) error {
+	setMembers := func(op string, value any) error {
+		members, err := parseMembers(value)
+		if err != nil {
+			return err
+		}
+
+		if op == "replace" {
+			resource.Members = members
+			return nil
+		}
+
+		for _, member := range members {
+			if !containsMember(resource.Members, member.Value) {
+				resource.Members = append(resource.Members, member)
+			}
+		}
+		return nil
+	}
+
+	for _, operation := range patch.Operations {
+		op := strings.ToLower(operation.Op)
+		path := attributeName(operation.Path)
+
+		switch {
+		case (op == "add" || op == "replace") && path == "members":
+			if err := setMembers(op, operation.Value); err != nil {
+				return err
+			}
+		case (op == "add" || op == "replace") && path == "displayname":
+			name, ok := operation.Value.(string)
+			if !ok {
+				return fmt.Errorf("%w: displayName has to be a string", errInvalidPatch)
+			}
+			resource.DisplayName = name
+		case (op == "add" || op == "replace") && path == "":
+			// without path the value is an object of attributes
+			values, ok := operation.Value.(map[string]any)
+			if !ok {
+				return fmt.Errorf("%w: value has to be an object without path", errInvalidPatch)
+			}
+
+			for key, value := range values {
+				switch attributeName(key) {
+				case "displayname":
+					name, ok := value.(string)
+					if !ok {
+						return fmt.Errorf("%w: displayName has to be a string", errInvalidPatch)
+					}
+					resource.DisplayName = name
+				case "members":
+					if err := setMembers(op, value); err != nil {
+						return err
+					}
+				}
+			}
+		case op == "remove" && path == "members":
+			// without value all members are removed
+			if operation.Value == nil {
+				resource.Members = nil
+				continue
+			}
+
+			members, err := parseMembers(operation.Value)
+			if err != nil {
+				return err
+			}
+
+			for _, member := range members {
+				resource.Members = removeMember(resource.Members, member.Value)
+			}
+		case op == "remove" && memberPathRegexp.MatchString(operation.Path):
+			value := memberPathRegexp.FindStringSubmatch(operation.Path)[1]
+			resource.Members = removeMember(resource.Members, value)
+		case op == "add" || op == "replace" || op == "remove":
+			return fmt.Errorf("%w: path %q is not supported", errInvalidPatch, operation.Path)
+		default:
+			return fmt.Errorf("%w: unknown op %q", errInvalidPatch, operation.Op)
+		}
+	}
+
+	return nil
+}
+
+// parseBool accepts booleans and the "True"/"False" strings some providers send
+func parseBool(value any) (bool, error) {
+	switch typed := value.(type) {
+	case bool:
+		return typed, nil
+	case string:
+		parsed, err := strconv.ParseBool(strings.ToLower(typed))
+		if err == nil {
+			return parsed, nil
+		}
+	}
+
+	return false, fmt.Errorf("%w: active has to be a boolean", errInvalidPatch)
+}
+
+// parseEmails parses the emails value of a patch
+func parseEmails(value any) ([]email, error) {
+	items, ok := value.([]any)
+	if !ok {
+		return nil, fmt.Errorf("%w: emails has to be a list", errInvalidPatch)
+	}
+
+	var emails []email
+	for _, item := range items {
+		object, ok := item.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("%w: emails has to be a list of objects", errInvalidPatch)
+		}
+
+		value, _ := object["value"].(string)
+		primary, _ := object["primary"].(bool)
+		emails = append(emails, email{Value: value, Primary: primary})
+	}
+
+	return emails, nil
+}
+
+// parseMembers parses the members value of a patch
+func parseMembers(value any) ([]reference, error) {
+	items, ok := value.([]any)
+	if !ok {
+		return nil, fmt.Errorf("%w: members has to be a list", errInvalidPatch)
+	}
+
+	var members []reference
+	for _, item := range items {
+		object, ok := item.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("%w: members has to be a list of objects", errInvalidPatch)
+		}
+
+		value, ok := object["value"].(string)
+		if !ok {
+			return nil, fmt.Errorf("%w: member value has to be a string", errInvalidPatch)
+		}
+		members = append(members, reference{Value: value})
+	}
+
+	return members, nil
+}
+
+func containsMember(members []reference, value string) bool {
+	return slices.ContainsFunc(members, func(member reference) bool { return member.Value == value })
+}
+
+func removeMember(members []reference, value string) []reference {
+	return slices.DeleteFunc(members, func(member reference) bool { return member.Value == value })
+}
+
+// isEmail reports if the value is a plain email address
+func isEmail(value string) bool {
+	address, err := mail.ParseAddress(value)
+	return err == nil && address.Address == value
+}
@@ -0,0 +1,198 @@
+package scimServer
+
+import (
+	"fmt"
+	"sso_go_grpc/internal/domain/models"
+	scimService "sso_go_grpc/internal/services/scim"
+	"strconv"
+	"time"
+)
+
+const (
+	schemaUser         = "urn:ietf:params:scim:schemas:core:2.0:User"
+	schemaGroup        = "urn:ietf:params:scim:schemas:core:2.0:Group"
+	schemaListResponse = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	schemaPatchOp      = "urn:ietf:params:scim:api:messages:2.0:PatchOp"
+	schemaError        = "urn:ietf:params:scim:api:messages:2.0:Error"
+	schemaSPConfig     = "urn:ietf:params:scim:schemas:core:2.0:ServiceProviderConfig"
+	schemaResourceType = "urn:ietf:params:scim:schemas:core:2.0:ResourceType"
+	schemaSchema       = "urn:ietf:params:scim:schemas:core:2.0:Schema"
+)
+
+type meta struct {
+	ResourceType string     `json:"resourceType"`
+	Created      *time.Time `json:"created,omitempty"`
+	Location     string     `json:"location"`
+	Version      string     `json:"version,omitempty"`
+}
+
+type email struct {
+	Value   string `json:"value"`
+	Type    string `json:"type,omitempty"`
+	Primary bool   `json:"primary,omitempty"`
+}
+
+type reference struct {
+	Value   string `json:"value"`
+	Display string `json:"display,omitempty"`
+	Ref     string `json:"$ref,omitempty"`
+}
+
+// user is the SCIM User resource, Password is write only
+type user struct {
+	Schemas  []string    `json:"schemas"`
+	Id       string      `json:"id,omitempty"`
+	UserName string      `json:"userName"`
+	Emails   []email     `json:"emails,omitempty"`
+	Active   *bool       `json:"active,omitempty"`
+	Password string      `json:"password,omitempty"`
+	Groups   []reference `json:"groups,omitempty"`
+	Meta     *meta       `json:"meta,omitempty"`
+}
+
+// group is the SCIM Group resource
+type group struct {
+	Schemas     []string    `json:"schemas"`
+	Id          string      `json:"id,omitempty"`
+	DisplayName string      `json:"displayName"`
+	Members     []reference `json:"members"`
+	Meta        *meta       `json:"meta,omitempty"`
+}
+
+type listResponse struct {
+	Schemas      []string `json:"schemas"`
+	TotalResults uint64   `json:"totalResults"`
+	StartIndex   int      `json:"startIndex"`
+	ItemsPerPage int      `json:"itemsPerPage"`
+	Resources    []any    `json:"Resources"`
+}
+
+type patchRequest struct {
+	Schemas    []string `json:"schemas"`
+	Operations []struct {
+		Op    string `json:"op"`
+		Path  string `json:"path"`
+		Value any    `json:"value"`
+	} `json:"Operations"`
+}
+
+type scimError struct {
+	Schemas  []string `json:"schemas"`
+	Status   string   `json:"status"`
+	ScimType string   `json:"scimType,omitempty"`
+	Detail   string   `json:"detail"`
+}
+
+// toUser converts the user model to the SCIM resource, baseUrl is the url of /scim/v2
+func toUser(model *models.User, baseUrl string) *user {
+	active := model.Status == models.UserStatusActive
+	id := strconv.FormatUint(model.UserId, 10)
+
+	resource := &user{
+		Schemas:  []string{schemaUser},
+		Id:       id,
+		UserName: model.Username,
+		Active:   &active,
+		Meta: &meta{
+			ResourceType: "User",
+			Location:     fmt.Sprintf("%s/Users/%s", baseUrl, id),
+			Version:      etag(model.Version),
+		},
+	}
+
+	if !model.CreatedAt.IsZero() {
+		resource.Meta.Created = &model.CreatedAt
+	}
+
+	if model.Email != "" {
+		resource.Emails = []email{{Value: model.Email, Type: "work", Primary: true}}
+	}
+
+	for _, role := range model.Roles {
+		roleId := strconv.FormatUint(role.Id, 10)
+		resource.Groups = append(resource.Groups, reference{
+			Value:   roleId,
+			Display: role.Name,
+			Ref:     fmt.Sprintf("%s/Groups/%s", baseUrl, roleId),
+		})
+	}
+
+	return resource
+}
+
+// toGroup converts the role and its members to the SCIM resource, baseUrl is the url of /scim/v2
+func toGroup(model *scimService.Group, baseUrl string) *group {
+	id := strconv.FormatUint(model.Role.Id, 10)
+
+	resource := &group{
+		Schemas:     []string{schemaGroup},
+		Id:          id,
+		DisplayName: model.Role.Name,
+		Members:     []reference{},
+		Meta: &meta{
+			ResourceType: "Group",
+			Location:     fmt.Sprintf("%s/Groups/%s", baseUrl, id),
+		},
+	}
+
+	for _, member := range model.Members {
+		userId := strconv.FormatUint(member.UserId, 10)
+		resource.Members = append(resource.Members, reference{
+			Value:   userId,
+			Display: member.Username,
+			Ref:     fmt.Sprintf("%s/Users/%s", baseUrl, userId),
+		})
+	}
+
+	return resource
+}
+
+// primaryEmail returns the primary or the first email,
+// without emails the userName is used if it is an email
+func (u *user) primaryEmail() string {
+	for _, email := range u.Emails {
+		if email.Primary {
+			return email.Value
+		}
+	}
+
+	if len(u.Emails) > 0 {
+		return u.Emails[0].Value
+	}
+
+	if isEmail(u.UserName) {
+		return u.UserName
+	}
+
+	return ""
+}
+
+// toModel returns the user model of the resource, id is 0 for new users
+func (u *user) toModel(id uint64) *models.User {
+	status := models.UserStatusActive
+	if u.Active != nil && !*u.Active {
+		status = models.UserStatusDisabled
+	}
+
+	return &models.User{
+		UserId:   id,
+		Username: u.UserName,
+		Email:    u.primaryEmail(),
+		Status:   status,
+	}
+}
+
+// memberIds returns the user ids of the members
+func memberIds(members []reference) ([]uint64, error) {
+	ids := make([]uint64, 0, len(members))
+
+	for _, member := range members {
+		id, err := strconv.ParseUint(member.Value, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid member %q", member.Value)
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
@@ -0,0 +1,453 @@
+package scimServer
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sso_go_grpc/internal/domain/models"
+	scimService "sso_go_grpc/internal/services/scim"
+	"sso_go_grpc/internal/storage"
+	"strconv"
+	"strings"
+)
+
+const (
+	// BasePath is the path all SCIM endpoints are served under
+	BasePath = "/scim/v2"
+
+	contentType     = "application/scim+json"
+	defaultCount    = 100
+	maxCount        = 1000
+	maxRequestBytes = 1 << 20
+)
+
+type serverApi struct {
+	scimService *scimService.ScimService
+	log         *slog.Logger
+	token       string
+}
+
+// NewHandler returns the handler of the SCIM 2.0 endpoints,
+// every request has to be authenticated with the bearer token
+func NewHandler(scimService *scimService.ScimService, log *slog.Logger, token string) http.Handler {
+	s := &serverApi{scimService: scimService, log: log, token: token}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(BasePath+"/Users", s.users)
+	mux.HandleFunc(BasePath+"/Users/", s.user)
+	mux.HandleFunc(BasePath+"/Groups", s.groups)
+	mux.HandleFunc(BasePath+"/Groups/", s.group)
+	mux.HandleFunc(BasePath+"/ServiceProviderConfig", s.serviceProviderConfig)
+	mux.HandleFunc(BasePath+"/ResourceTypes", s.resourceTypes)
+	mux.HandleFunc(BasePath+"/Schemas", s.schemas)
+	mux.HandleFunc(BasePath+"/Schemas/", s.schemas)
+
+	return s.authenticate(mux)
+}
+
+// authenticate rejects requests without the bearer token
+func (s *serverApi) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="scim"`)
+			writeError(w, http.StatusUnauthorized, "", "invalid bearer token")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// users handles /Users
+func (s *serverApi) users(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		s.listUsers(w, r)
+	case http.MethodPost:
+		s.createUser(w, r)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "", "method not allowed")
+	}
+}
+
+// user handles /Users/{id}
+func (s *serverApi) user(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(strings.TrimPrefix(r.URL.Path, BasePath+"/Users/"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "", storage.ErrUserNotExists.Error())
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		user, err := s.scimService.GetUser(r.Context(), id)
+		if err != nil {
+			s.writeServiceError(w, err)
+			return
+		}
+		writeUser(w, http.StatusOK, user, baseUrl(r))
+	case http.MethodPut:
+		expectedVersion, ok := ifMatch(w, r)
+		if !ok {
+			return
+		}
+
+		var resource user
+		if !decode(w, r, &resource) {
+			return
+		}
+		s.replaceUser(w, r, id, &resource, resource.Password, expectedVersion)
+	case http.MethodPatch:
+		expectedVersion, ok := ifMatch(w, r)
+		if !ok {
+			return
+		}
+
+		var patch patchRequest
+		if !decode(w, r, &patch) {
+			return
+		}
+
+		current, err := s.scimService.GetUser(r.Context(), id)
+		if err != nil {
+			s.writeServiceError(w, err)
+			return
+		}
+
+		// the patch is applied to the read user, so it is only saved if the user was not changed since
+		if expectedVersion == 0 {
+			expectedVersion = current.Version
+		}
+
+		resource := toUser(current, baseUrl(r))
+		password, err := patchUser(resource, &patch)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalidValue", err.Error())
+			return
+		}
+		s.replaceUser(w, r, id, resource, password, expectedVersion)
+	case http.MethodDelete:
+		if err := s.scimService.DeleteUser(r.Context(), id); err != nil {
+			s.writeServiceError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "", "method not allowed")
+	}
+}
+
+func (s *serverApi) listUsers(w http.ResponseWriter, r *http.Request) {
+	startIndex, count, ok := pagination(w, r)
+	if !ok {
+		return
+	}
+
+	filter, err := userFilter(r.URL.Query().Get("filter"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalidFilter", err.Error())
+		return
+	}
+
+	users, total, err := s.scimService.ListUsers(r.Context(), filter, startIndex, count)
+	if err != nil {
+		s.writeServiceError(w, err)
+		return
+	}
+
+	response := newListResponse(total, startIndex)
+	for _, user := range users {
+		response.Resources = append(response.Resources, toUser(user, baseUrl(r)))
+	}
+	response.ItemsPerPage = len(response.Resources)
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+func (s *serverApi) createUser(w http.ResponseWriter, r *http.Request) {
+	var resource user
+	if !decode(w, r, &resource) {
+		return
+	}
+
+	model := resource.toModel(0)
+	if model.Username == "" || model.Email == "" {
+		writeError(w, http.StatusBadRequest, "invalidValue", "userName and an email are required")
+		return
+	}
+
+	created, err := s.scimService.CreateUser(r.Context(), model, resource.Password)
+	if err != nil {
+		s.writeServiceError(w, err)
+		return
+	}
+
+	writeUser(w, http.StatusCreated, created, baseUrl(r))
+}
+
+func (s *serverApi) replaceUser(w http.ResponseWriter, r *http.Request, id uint64, resource *user, password string, expectedVersion uint64) {
+	model := resource.toModel(id)
+	if model.Username == "" || model.Email == "" {
+		writeError(w, http.StatusBadRequest, "invalidValue", "userName and an email are required")
+		return
+	}
+
+	updated, err := s.scimService.ReplaceUser(r.Context(), model, password, expectedVersion)
+	if err != nil {
+		s.writeServiceError(w, err)
+		return
+	}
+
+	writeUser(w, http.StatusOK, updated, baseUrl(r))
+}
+
+// groups handles /Groups
+func (s *serverApi) groups(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		startIndex, count, ok := pagination(w, r)
+		if !ok {
+			return
+		}
+
+		displayName, err := groupFilter(r.URL.Query().Get("filter"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalidFilter", err.Error())
+			return
+		}
+
+		groups, total, err := s.scimService.ListGroups(r.Context(), displayName, startIndex, count)
+		if err != nil {
+			s.writeServiceError(w, err)
+			return
+		}
+
+		response := newListResponse(total, startIndex)
+		for _, group := range groups {
+			response.Resources = append(response.Resources, toGroup(group, baseUrl(r)))
+		}
+		response.ItemsPerPage = len(response.Resources)
+
+		writeJSON(w, http.StatusOK, response)
+	case http.MethodPost:
+		var resource group
+		if !decode(w, r, &resource) {
+			return
+		}
+
+		if resource.DisplayName == "" {
+			writeError(w, http.StatusBadRequest, "invalidValue", "displayName is required")
+			return
+		}
+
+		members, err := memberIds(resource.Members)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalidValue", err.Error())
+			return
+		}
+
+		created, err := s.scimService.CreateGroup(r.Context(), resource.DisplayName, members)
+		if err != nil {
+			s.writeServiceError(w, err)
+			return
+		}
+
+		writeJSON(w, http.StatusCreated, toGroup(created, baseUrl(r)))
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "", "method not allowed")
+	}
+}
+
+// group handles /Groups/{id}
+func (s *serverApi) group(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(strings.TrimPrefix(r.URL.Path, BasePath+"/Groups/"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "", storage.ErrRoleNotExists.Error())
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		group, err := s.scimService.GetGroup(r.Context(), id)
+		if err != nil {
+			s.writeServiceError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, toGroup(group, baseUrl(r)))
+	case http.MethodPut:
+		var resource group
+		if !decode(w, r, &resource) {
+			return
+		}
+		s.replaceGroup(w, r, id, &resource)
+	case http.MethodPatch:
+		var patch patchRequest
+		if !decode(w, r, &patch) {
+			return
+		}
+
+		current, err := s.scimService.GetGroup(r.Context(), id)
+		if err != nil {
+			s.writeServiceError(w, err)
+			return
+		}
+
+		resource := toGroup(current, baseUrl(r))
+		if err = patchGroup(resource, &patch); err != nil {
+			writeError(w, http.StatusBadRequest, "invalidValue", err.Error())
+			return
+		}
+		s.replaceGroup(w, r, id, resource)
+	case http.MethodDelete:
+		if err := s.scimService.DeleteGroup(r.Context(), id); err != nil {
+			s.writeServiceError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "", "method not allowed")
+	}
+}
+
+func (s *serverApi) replaceGroup(w http.ResponseWriter, r *http.Request, id uint64, resource *group) {
+	if resource.DisplayName == "" {
+		writeError(w, http.StatusBadRequest, "invalidValue", "displayName is required")
+		return
+	}
+
+	members, err := memberIds(resource.Members)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalidValue", err.Error())
+		return
+	}
+
+	updated, err := s.scimService.ReplaceGroup(r.Context(), id, resource.DisplayName, members)
+	if err != nil {
+		s.writeServiceError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, toGroup(updated, baseUrl(r)))
+}
+
+// writeServiceError maps the service errors to SCIM errors
+func (s *serverApi) writeServiceError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, storage.ErrUserNotExists), errors.Is(err, storage.ErrRoleNotExists):
+		writeError(w, http.StatusNotFound, "", err.Error())
+	case errors.Is(err, storage.ErrUserExists), errors.Is(err, storage.ErrRoleExists):
+		writeError(w, http.StatusConflict, "uniqueness", err.Error())
+	case errors.Is(err, storage.ErrInvalidPassword), errors.Is(err, storage.ErrInvalidUsername):
+		writeError(w, http.StatusBadRequest, "invalidValue", err.Error())
+	case errors.Is(err, storage.ErrVersionMismatch):
+		writeError(w, http.StatusPreconditionFailed, "", err.Error())
+	default:
+		s.log.Error("Error on handling scim request", "err", err)
+		writeError(w, http.StatusInternalServerError, "", "Internal Server Error")
+	}
+}
+
+// pagination returns the 1-based startIndex and the count of a listing
+func pagination(w http.ResponseWriter, r *http.Request) (startIndex, count int, ok bool) {
+	startIndex, count = 1, defaultCount
+	query := r.URL.Query()
+
+	if value := query.Get("startIndex"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalidValue", "startIndex has to be a number")
+			return 0, 0, false
+		}
+		// values below 1 are interpreted as 1
+		startIndex = max(parsed, 1)
+	}
+
+	if value := query.Get("count"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalidValue", "count has to be a number")
+			return 0, 0, false
+		}
+		// negative values are interpreted as 0
+		count = min(max(parsed, 0), maxCount)
+	}
+
+	return startIndex, count, true
+}
+
+func newListResponse(total uint64, startIndex int) *listResponse {
+	return &listResponse{
+		Schemas:      []string{schemaListResponse},
+		TotalResults: total,
+		StartIndex:   startIndex,
+		Resources:    []any{},
+	}
+}
+
+// baseUrl returns the absolute url of BasePath for the meta locations
+func baseUrl(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+
+	return fmt.Sprintf("%s://%s%s", scheme, r.Host, BasePath)
+}
+
+// decode decodes the json body, on errors the response is written and false is returned
+func decode(w http.ResponseWriter, r *http.Request, target any) bool {
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBytes)).Decode(target); err != nil {
+		writeError(w, http.StatusBadRequest, "invalidSyntax", err.Error())
+		return false
+	}
+
+	return true
+}
+
+// writeUser writes the user with its version as ETag
+func writeUser(w http.ResponseWriter, status int, model *models.User, baseUrl string) {
+	w.Header().Set("ETag", etag(model.Version))
+	writeJSON(w, status, toUser(model, baseUrl))
+}
+
+// ifMatch returns the version of the If-Match header, 0 without header or for *,
+// an ETag that is no version of this server can not match and fails with 412
+func ifMatch(w http.ResponseWriter, r *http.Request) (uint64, bool) {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	if header == "" || header == "*" {
+		return 0, true
+	}
+
+	version, err := strconv.ParseUint(strings.Trim(strings.TrimPrefix(header, "W/"), `"`), 10, 64)
+	if err != nil || version == 0 {
+		writeError(w, http.StatusPreconditionFailed, "", storage.ErrVersionMismatch.Error())
+		return 0, false
+	}
+
+	return version, true
+}
+
+// etag returns the weak ETag of the version
+func etag(version uint64) string {
+	return fmt.Sprintf(`W/"%d"`, version)
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func writeError(w http.ResponseWriter, status int, scimType, detail string) {
+	writeJSON(w, status, &scimError{
+		Schemas:  []string{schemaError},
+		Status:   strconv.Itoa(status),
+		ScimType: scimType,
+		Detail:   detail,
+	})
+}
@@ -0,0 +1,110 @@
+package attributes
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"sso_go_grpc/internal/domain/models"
+	"sso_go_grpc/internal/storage"
+)
+
+// reservedClaims can not be used as claim of an attribute
+var reservedClaims = []string{"uid", "email", "roles", "exp", "iat", "nbf", "iss", "aud", "sub", "jti"}
+
+var nameRegexp = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9_]{0,63}$`)
+
+// ValidateDefinition returns storage.ErrInvalidDefinition if the definition can not be used
+func ValidateDefinition(definition *models.AttributeDefinition) error {
+	if !nameRegexp.MatchString(definition.Name) {
+		return fmt.Errorf("%w: name has to match %s", storage.ErrInvalidDefinition, nameRegexp)
+	}
+
+	switch definition.Type {
+	case models.AttributeTypeString, models.AttributeTypeNumber, models.AttributeTypeBoolean:
+	default:
+		return fmt.Errorf("%w: unknown type %q", storage.ErrInvalidDefinition, definition.Type)
+	}
+
+	if len(definition.Enum) > 0 && definition.Type != models.AttributeTypeString {
+		return fmt.Errorf("%w: enum is only allowed for string attributes", storage.ErrInvalidDefinition)
+	}
+
+	if definition.Claim != "" {
+		if !nameRegexp.MatchString(definition.Claim) {
+			return fmt.Errorf("%w: claim has to match %s", storage.ErrInvalidDefinition, nameRegexp)
+		}
+		if slices.Contains(reservedClaims, definition.Claim) {
+			return fmt.Errorf("%w: claim %q is reserved", storage.ErrInvalidDefinition, definition.Claim)
+		}
+	}
+
+	return nil
+}
+
+// Validate returns storage.ErrInvalidAttributes if the attributes do not match the definitions:
+// unknown attribute, wrong type, value not in enum or missing required attribute
+func Validate(definitions []*models.AttributeDefinition, attributes map[string]any) error {
+	known := make(map[string]*models.AttributeDefinition, len(definitions))
+
+	for _, definition := range definitions {
+		known[definition.Name] = definition
+
+		if _, ok := attributes[definition.Name]; definition.Required && !ok {
+			return fmt.Errorf("%w: %s is required", storage.ErrInvalidAttributes, definition.Name)
+		}
+	}
+
+	for name, value := range attributes {
+		definition, ok := known[name]
+		if !ok {
+			return fmt.Errorf("%w: %s is not defined", storage.ErrInvalidAttributes, name)
+		}
+
+		if err := validateValue(definition, value); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Claims returns the token claims of all attributes that are mapped to a claim
+func Claims(definitions []*models.AttributeDefinition, attributes map[string]any) map[string]any {
+	claims := make(map[string]any)
+
+	for _, definition := range definitions {
+		if definition.Claim == "" {
+			continue
+		}
+
+		if value, ok := attributes[definition.Name]; ok {
+			claims[definition.Claim] = value
+		}
+	}
+
+	return claims
+}
+
+// validateValue checks the type and the enum of one attribute value
+func validateValue(definition *models.AttributeDefinition, value any) error {
+	var valid bool
+
+	switch definition.Type {
+	case models.AttributeTypeString:
+		var str string
+		str, valid = value.(string)
+		if valid && len(definition.Enum) > 0 && !slices.Contains(definition.Enum, str) {
+			return fmt.Errorf("%w: %s has to be one of %v", storage.ErrInvalidAttributes, definition.Name, definition.Enum)
+		}
+	case models.AttributeTypeNumber:
+		_, valid = value.(float64)
+	case models.AttributeTypeBoolean:
+		_, valid = value.(bool)
+	}
+
+	if !valid {
+		return fmt.Errorf("%w: %s has to be a %s", storage.ErrInvalidAttributes, definition.Name, definition.Type)
+	}
+
+	return nil
+}
@@ -0,0 +1,127 @@
+package auditchain
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sso_go_grpc/internal/domain/models"
+	"time"
+)
+
+// Hash returns the hex hash of the entry chained to the hash of the previous entry,
+// it is an HMAC-SHA256 if a key is given and a plain SHA-256 otherwise
+func Hash(key []byte, prevHash string, entry *models.AuditEntry) (string, error) {
+	payload, err := canonical(prevHash, entry)
+	if err != nil {
+		return "", err
+	}
+
+	if len(key) > 0 {
+		mac := hmac.New(sha256.New, key)
+		mac.Write(payload)
+		return hex.EncodeToString(mac.Sum(nil)), nil
+	}
+
+	sum := sha256.Sum256(payload)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// canonical returns the bytes that are hashed; the states are re-encoded from their generic
+// json form, so the hash does not depend on how postgres formats the stored JSONB
+func canonical(prevHash string, entry *models.AuditEntry) ([]byte, error) {
+	before, err := canonicalState(entry.Before)
+	if err != nil {
+		return nil, err
+	}
+
+	after, err := canonicalState(entry.After)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal([]any{
+		prevHash,
+		entry.Id,
+		entry.ActorId,
+		entry.Action,
+		entry.TargetType,
+		entry.TargetId,
+		before,
+		after,
+		entry.RequestId,
+		entry.IP,
+		entry.CreatedAt.UTC().Format(time.RFC3339Nano),
+	})
+}
+
+// canonicalState returns the state as generic json value, maps are encoded with sorted keys
+func canonicalState(state any) (any, error) {
+	if state == nil {
+		return nil, nil
+	}
+
+	data, err := json.Marshal(state)
+	if err != nil {
+		return nil, err
+	}
+
+	var generic any
+	if err := json.Unmarshal(data, &generic); err != nil {
+		return nil, err
+	}
+
+	return generic, nil
+}
+
+// ParseSigningKey returns the ed25519 key of the base64 encoded 32 byte seed
+func ParseSigningKey(seed string) (ed25519.PrivateKey, error) {
+	decoded, err := base64.StdEncoding.DecodeString(seed)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(decoded) != ed25519.SeedSize {
+		return nil, fmt.Errorf("checkpoint key must be a base64 encoded %d byte seed", ed25519.SeedSize)
+	}
+
+	return ed25519.NewKeyFromSeed(decoded), nil
+}
+
+// PublicKey returns the base64 encoded public key of the signing key
+func PublicKey(key ed25519.PrivateKey) string {
+	return base64.StdEncoding.EncodeToString(key.Public().(ed25519.PublicKey))
+}
+
+// SignCheckpoint sets the public key and the signature of the checkpoint
+func SignCheckpoint(key ed25519.PrivateKey, checkpoint *models.AuditCheckpoint) {
+	checkpoint.PublicKey = PublicKey(key)
+	checkpoint.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(key, checkpointPayload(checkpoint)))
+}
+
+// VerifyCheckpoint returns if the signature of the checkpoint is valid for its public key
+func VerifyCheckpoint(checkpoint *models.AuditCheckpoint) bool {
+	publicKey, err := base64.StdEncoding.DecodeString(checkpoint.PublicKey)
+	if err != nil || len(publicKey) != ed25519.PublicKeySize {
+		return false
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(checkpoint.Signature)
+	if err != nil {
+		return false
+	}
+
+	return ed25519.Verify(publicKey, checkpointPayload(checkpoint), signature)
+}
+
+func checkpointPayload(checkpoint *models.AuditCheckpoint) []byte {
+	return []byte(fmt.Sprintf("sso-audit-checkpoint\n%d\n%d\n%s\n%s",
+		checkpoint.Entries,
+		checkpoint.LastId,
+		checkpoint.LastHash,
+		checkpoint.CreatedAt.UTC().Format(time.RFC3339Nano),
+	))
+}
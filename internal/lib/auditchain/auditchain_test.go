@@ -0,0 +1,155 @@
+package auditchain
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"sso_go_grpc/internal/domain/models"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testEntry() *models.AuditEntry {
+	return &models.AuditEntry{
+		Id:         7,
+		ActorId:    1,
+		Action:     models.AuditActionRoleUpdate,
+		TargetType: models.AuditTargetRole,
+		TargetId:   3,
+		Before:     map[string]any{"name": "editor", "description": "edits"},
+		After:      map[string]any{"name": "writer", "description": "edits"},
+		RequestId:  "req-1",
+		IP:         "10.0.0.1",
+		CreatedAt:  time.Date(2024, 5, 1, 12, 0, 0, 123, time.UTC),
+	}
+}
+
+func TestHash(t *testing.T) {
+	key := []byte("audit-key")
+
+	base, err := Hash(key, "prev", testEntry())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(base) != 64 {
+		t.Fatalf("Hash() = %q, want 64 hex characters", base)
+	}
+
+	tests := []struct {
+		name     string
+		key      []byte
+		prevHash string
+		change   func(entry *models.AuditEntry)
+		same     bool
+	}{
+		{name: "same entry", key: key, prevHash: "prev", change: func(*models.AuditEntry) {}, same: true},
+		{
+			name: "states from stored json", key: key, prevHash: "prev", same: true,
+			// postgres returns the JSONB keys in another order
+			change: func(entry *models.AuditEntry) {
+				entry.Before = map[string]any{"description": "edits", "name": "editor"}
+			},
+		},
+		{
+			name: "creation time in another zone", key: key, prevHash: "prev", same: true,
+			change: func(entry *models.AuditEntry) {
+				entry.CreatedAt = entry.CreatedAt.In(time.FixedZone("CEST", 2*60*60))
+			},
+		},
+		{name: "other previous hash", key: key, prevHash: "other", change: func(*models.AuditEntry) {}},
+		{name: "other key", key: []byte("other-key"), prevHash: "prev", change: func(*models.AuditEntry) {}},
+		{name: "without key", prevHash: "prev", change: func(*models.AuditEntry) {}},
+		{name: "other actor", key: key, prevHash: "prev", change: func(entry *models.AuditEntry) { entry.ActorId = 2 }},
+		{name: "other action", key: key, prevHash: "prev", change: func(entry *models.AuditEntry) { entry.Action = models.AuditActionRoleDelete }},
+		{name: "other target", key: key, prevHash: "prev", change: func(entry *models.AuditEntry) { entry.TargetId = 4 }},
+		{name: "other state", key: key, prevHash: "prev", change: func(entry *models.AuditEntry) { entry.After = nil }},
+		{name: "other ip", key: key, prevHash: "prev", change: func(entry *models.AuditEntry) { entry.IP = "10.0.0.2" }},
+		{
+			name: "other creation time", key: key, prevHash: "prev",
+			change: func(entry *models.AuditEntry) { entry.CreatedAt = entry.CreatedAt.Add(time.Nanosecond) },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry := testEntry()
+			tt.change(entry)
+
+			got, err := Hash(tt.key, tt.prevHash, entry)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (got == base) != tt.same {
+				t.Errorf("Hash() = %s, base %s, want same %t", got, base, tt.same)
+			}
+		})
+	}
+}
+
+func TestCheckpoint(t *testing.T) {
+	seed := base64.StdEncoding.EncodeToString(make([]byte, ed25519.SeedSize))
+
+	key, err := ParseSigningKey(seed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkpoint := func() *models.AuditCheckpoint {
+		checkpoint := &models.AuditCheckpoint{
+			Entries:   10,
+			LastId:    12,
+			LastHash:  strings.Repeat("a", 64),
+			CreatedAt: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+		}
+		SignCheckpoint(key, checkpoint)
+		return checkpoint
+	}
+
+	tests := []struct {
+		name   string
+		change func(checkpoint *models.AuditCheckpoint)
+		want   bool
+	}{
+		{name: "signed", change: func(*models.AuditCheckpoint) {}, want: true},
+		{name: "other entries", change: func(checkpoint *models.AuditCheckpoint) { checkpoint.Entries++ }},
+		{name: "other last id", change: func(checkpoint *models.AuditCheckpoint) { checkpoint.LastId++ }},
+		{name: "other last hash", change: func(checkpoint *models.AuditCheckpoint) { checkpoint.LastHash = strings.Repeat("b", 64) }},
+		{name: "other time", change: func(checkpoint *models.AuditCheckpoint) { checkpoint.CreatedAt = checkpoint.CreatedAt.Add(time.Second) }},
+		{name: "invalid public key", change: func(checkpoint *models.AuditCheckpoint) { checkpoint.PublicKey = "not base64" }},
+		{name: "short public key", change: func(checkpoint *models.AuditCheckpoint) { checkpoint.PublicKey = "AAAA" }},
+		{name: "invalid signature", change: func(checkpoint *models.AuditCheckpoint) { checkpoint.Signature = "not base64" }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkpoint := checkpoint()
+			tt.change(checkpoint)
+
+			if got := VerifyCheckpoint(checkpoint); got != tt.want {
+				t.Errorf("VerifyCheckpoint() = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseSigningKey(t *testing.T) {
+	tests := []struct {
+		name    string
+		seed    string
+		wantErr bool
+	}{
+		{name: "32 byte seed", seed: base64.StdEncoding.EncodeToString(make([]byte, 32))},
+		{name: "short seed", seed: base64.StdEncoding.EncodeToString(make([]byte, 16)), wantErr: true},
+		{name: "not base64", seed: "not base64", wantErr: true},
+		{name: "empty", seed: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseSigningKey(tt.seed)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseSigningKey() error = %v, wantErr %t", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -1,9 +1,19 @@
 package bcrypt
 
-import "golang.org/x/crypto/bcrypt"
+import (
+	"context"
+	"golang.org/x/crypto/bcrypt"
+	"sso_go_grpc/internal/lib/metrics"
+	"sso_go_grpc/internal/lib/tracing"
+	"time"
+)
+
+// HashPassword hashes pwd, in its own span as it takes most of the time of a registration
+func HashPassword(ctx context.Context, password string) (string, error) {
+	_, span := tracing.Start(ctx, "bcrypt.HashPassword")
+	defer span.End()
+	defer observe(metrics.BcryptHash, time.Now())
 
-// HashPassword hashes pwd
-func HashPassword(password string) (string, error) {
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 	if err != nil {
 		return "", err
@@ -11,7 +21,22 @@ func HashPassword(password string) (string, error) {
 	return string(hashedPassword), nil
 }
 
-// ComparePasswords compares pwd to hash
-func ComparePasswords(hashedPassword, enteredPassword string) error {
+// ComparePasswords compares pwd to hash, in its own span as it takes most of the time of a login
+func ComparePasswords(ctx context.Context, hashedPassword, enteredPassword string) error {
+	_, span := tracing.Start(ctx, "bcrypt.ComparePasswords")
+	defer span.End()
+	defer observe(metrics.BcryptCompare, time.Now())
+
 	return bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(enteredPassword))
 }
+
+// IsHash reports if hash is a valid bcrypt hash
+func IsHash(hash string) bool {
+	_, err := bcrypt.Cost([]byte(hash))
+	return err == nil
+}
+
+// observe records the duration of the bcrypt operation since start
+func observe(operation string, start time.Time) {
+	metrics.BcryptDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+}
@@ -0,0 +1,124 @@
+package client
+
+import (
+	"context"
+	"crypto/x509"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"net"
+)
+
+const (
+	// ForwardedForKey and ForwardedUserAgentKey are set by the http gateway,
+	// they are only trusted from a peer on the same host
+	ForwardedForKey       = "x-forwarded-for"
+	ForwardedUserAgentKey = "x-forwarded-user-agent"
+
+	// RequestIdKey is the metadata key of the request id, it is also sent back in the response headers
+	RequestIdKey = "x-request-id"
+
+	// IdempotencyKey is the metadata key of the idempotency key of mutating requests,
+	// retries with the same key and payload get the response of the first request
+	IdempotencyKey = "idempotency-key"
+)
+
+// IP returns the ip address of the calling peer, empty if it is unknown;
+// for calls of the http gateway it is the ip address of the http client
+func IP(ctx context.Context) string {
+	host := peerHost(ctx)
+
+	if forwarded := firstValue(ctx, ForwardedForKey); forwarded != "" && isLoopback(host) {
+		return forwarded
+	}
+
+	return host
+}
+
+// UserAgent returns the user agent the client sent in the metadata,
+// for calls of the http gateway it is the user agent of the http client
+func UserAgent(ctx context.Context) string {
+	if forwarded := firstValue(ctx, ForwardedUserAgentKey); forwarded != "" && isLoopback(peerHost(ctx)) {
+		return forwarded
+	}
+
+	return firstValue(ctx, "user-agent")
+}
+
+// Certificate returns the verified certificate of an mTLS client, nil if the client sent none
+func Certificate(ctx context.Context) *x509.Certificate {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return nil
+	}
+
+	info, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(info.State.VerifiedChains) == 0 || len(info.State.VerifiedChains[0]) == 0 {
+		return nil
+	}
+
+	return info.State.VerifiedChains[0][0]
+}
+
+// ServiceIdentity returns the identity of the calling service from its mTLS certificate:
+// the first URI SAN (e.g. a SPIFFE id), else the first DNS SAN, else the common name; empty without certificate
+func ServiceIdentity(ctx context.Context) string {
+	certificate := Certificate(ctx)
+	if certificate == nil {
+		return ""
+	}
+
+	switch {
+	case len(certificate.URIs) > 0:
+		return certificate.URIs[0].String()
+	case len(certificate.DNSNames) > 0:
+		return certificate.DNSNames[0]
+	default:
+		return certificate.Subject.CommonName
+	}
+}
+
+// peerHost returns the host of the calling peer, empty if it is unknown
+func peerHost(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return p.Addr.String()
+	}
+
+	return host
+}
+
+func isLoopback(host string) bool {
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// RequestId returns the request id of the x-request-id metadata,
+// the server generates one if the client sent none
+func RequestId(ctx context.Context) string {
+	return firstValue(ctx, RequestIdKey)
+}
+
+// Idempotency returns the idempotency key of the idempotency-key metadata, empty if the client sent none
+func Idempotency(ctx context.Context) string {
+	return firstValue(ctx, IdempotencyKey)
+}
+
+// firstValue returns the first value of the incoming metadata key
+func firstValue(ctx context.Context, key string) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+
+	return ""
+}
@@ -0,0 +1,25 @@
+package cursor
+
+import (
+	"encoding/base64"
+	"strconv"
+)
+
+// Encode returns an opaque page token for the given id
+func Encode(id uint64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatUint(id, 10)))
+}
+
+// Decode returns the id hidden in the page token, empty token is 0
+func Decode(token string) (uint64, error) {
+	if token == "" {
+		return 0, nil
+	}
+
+	decoded, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return 0, err
+	}
+
+	return strconv.ParseUint(string(decoded), 10, 64)
+}
@@ -0,0 +1,48 @@
+package cursor
+
+import (
+	"math"
+	"testing"
+)
+
+func TestEncodeDecode(t *testing.T) {
+	for _, id := range []uint64{0, 1, 42, 1 << 40, math.MaxUint64} {
+		token := Encode(id)
+
+		got, err := Decode(token)
+		if err != nil {
+			t.Fatalf("Decode(Encode(%d)) error = %v", id, err)
+		}
+		if got != id {
+			t.Errorf("Decode(Encode(%d)) = %d", id, got)
+		}
+	}
+}
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		name    string
+		token   string
+		want    uint64
+		wantErr bool
+	}{
+		{name: "empty is the first page", token: "", want: 0},
+		{name: "encoded id", token: "NDI", want: 42},
+		{name: "not base64", token: "!!", wantErr: true},
+		{name: "padded base64", token: "NDI=", wantErr: true},
+		{name: "not a number", token: "YWJj", wantErr: true},
+		{name: "negative", token: "LTE", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Decode(tt.token)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Decode(%q) error = %v, wantErr %t", tt.token, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Decode(%q) = %d, want %d", tt.token, got, tt.want)
+			}
+		})
+	}
+}
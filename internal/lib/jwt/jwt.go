@@ -4,17 +4,41 @@ import (
 	"fmt"
 	"github.com/dgrijalva/jwt-go"
 	"sso_go_grpc/internal/domain/models"
+	"strconv"
 	"time"
 )
 
-func NewToken(user *models.User, secret string) (string, error) {
+// Issuer is the issuer and audience of the tokens, services verifying the tokens check both
+type Issuer struct {
+	Issuer   string
+	Audience string
+}
+
+// NewToken returns a signed token of the user with the names of its roles, extraClaims are added to the standard claims
+func NewToken(user *models.User, secret string, issuer Issuer, extraClaims map[string]any) (string, error) {
 	token := jwt.New(jwt.SigningMethodHS256)
 
 	claims := token.Claims.(jwt.MapClaims)
 
+	for name, value := range extraClaims {
+		claims[name] = value
+	}
+
+	roles := make([]string, 0, len(user.Roles))
+	for _, role := range user.Roles {
+		roles = append(roles, role.Name)
+	}
+
+	now := time.Now()
+
 	claims["uid"] = user.UserId
+	claims["sub"] = strconv.FormatUint(user.UserId, 10)
 	claims["email"] = user.Email
-	claims["exp"] = time.Now().Add(time.Hour * 48).Unix()
+	claims["roles"] = roles
+	claims["iss"] = issuer.Issuer
+	claims["aud"] = issuer.Audience
+	claims["iat"] = now.Unix()
+	claims["exp"] = now.Add(time.Hour * 48).Unix()
 
 	tokenString, err := token.SignedString([]byte(secret))
 
@@ -24,3 +48,30 @@ func NewToken(user *models.User, secret string) (string, error) {
 
 	return tokenString, nil
 }
+
+// ParseToken verifies the token and returns the id of its user
+func ParseToken(tokenString, secret string) (uint64, error) {
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method %v", token.Header["alg"])
+		}
+		return []byte(secret), nil
+	})
+
+	if err != nil {
+		return 0, err
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid {
+		return 0, fmt.Errorf("invalid token")
+	}
+
+	// numbers are decoded as float64
+	uid, ok := claims["uid"].(float64)
+	if !ok {
+		return 0, fmt.Errorf("token has no uid")
+	}
+
+	return uint64(uid), nil
+}
@@ -0,0 +1,128 @@
+package metrics
+
+import (
+	"database/sql"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"net/http"
+	"sync"
+)
+
+// Namespace prefixes the names of all metrics of the service
+const Namespace = "sso"
+
+// label values of the counters
+const (
+	LoginSuccess = "success"
+	LoginFailure = "failure"
+
+	RoleAssigned = "assigned"
+	RoleRemoved  = "removed"
+
+	BcryptHash    = "hash"
+	BcryptCompare = "compare"
+)
+
+// Registry holds the metrics of the service next to the go runtime and process metrics
+var Registry = prometheus.NewRegistry()
+
+var (
+	// RPCRequests counts the finished RPCs by full method and status code
+	RPCRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Subsystem: "grpc",
+		Name:      "requests_total",
+		Help:      "Number of finished gRPC requests by method and status code.",
+	}, []string{"method", "code"})
+
+	// RPCDuration is the latency of the RPCs by full method and status code
+	RPCDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: Namespace,
+		Subsystem: "grpc",
+		Name:      "request_duration_seconds",
+		Help:      "Latency of the gRPC requests by method and status code.",
+		Buckets:   []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10},
+	}, []string{"method", "code"})
+
+	// Logins counts the login attempts by result
+	Logins = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "logins_total",
+		Help:      "Number of login attempts by result.",
+	}, []string{"result"})
+
+	// Registrations counts the registered users
+	Registrations = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "registrations_total",
+		Help:      "Number of registered users.",
+	})
+
+	// RoleAssignments counts the roles assigned to and removed from users
+	RoleAssignments = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "role_assignment_changes_total",
+		Help:      "Number of roles assigned to or removed from users.",
+	}, []string{"change"})
+
+	// BcryptDuration is the time of hashing and comparing passwords,
+	// the buckets are around the ~50-100ms of the default cost
+	BcryptDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: Namespace,
+		Name:      "bcrypt_duration_seconds",
+		Help:      "Duration of bcrypt hashing and comparing by operation.",
+		Buckets:   []float64{.01, .025, .05, .075, .1, .15, .25, .5, 1},
+	}, []string{"operation"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		RPCRequests,
+		RPCDuration,
+		Logins,
+		Registrations,
+		RoleAssignments,
+		BcryptDuration,
+	)
+}
+
+// dbCollectors are the collectors of the exported databases by name, the registry tells collectors apart
+// by their metrics only, so a replaced collector must not unregister its successor
+var (
+	dbCollectorsMu sync.Mutex
+	dbCollectors   = map[string]prometheus.Collector{}
+)
+
+// RegisterDB exports the connection pool statistics of the database with the label db_name,
+// e.g. go_sql_open_connections and go_sql_wait_duration_seconds_total; a database exported with the name before
+// is replaced, the returned function stops the export when the database is closed
+func RegisterDB(db *sql.DB, name string) (unregister func()) {
+	dbCollectorsMu.Lock()
+	defer dbCollectorsMu.Unlock()
+
+	if existing, ok := dbCollectors[name]; ok {
+		Registry.Unregister(existing)
+	}
+
+	collector := collectors.NewDBStatsCollector(db, name)
+	Registry.MustRegister(collector)
+	dbCollectors[name] = collector
+
+	return func() {
+		dbCollectorsMu.Lock()
+		defer dbCollectorsMu.Unlock()
+
+		if dbCollectors[name] == collector {
+			Registry.Unregister(collector)
+			delete(dbCollectors, name)
+		}
+	}
+}
+
+// Handler serves the metrics of the Registry in the prometheus text format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
@@ -0,0 +1,20 @@
+package normalize
+
+import (
+	"golang.org/x/text/secure/precis"
+	"golang.org/x/text/unicode/norm"
+	"strings"
+)
+
+// Email returns the form of the email used for lookups and uniqueness:
+// trimmed, NFKC normalized and lowercased, so Foo@x.com and foo@x.com are the same user
+func Email(email string) string {
+	return strings.ToLower(norm.NFKC.String(strings.TrimSpace(email)))
+}
+
+// Username returns the form of the username used for lookups and uniqueness,
+// following the PRECIS UsernameCaseMapped profile (RFC 8265);
+// usernames with characters the profile does not allow return an error
+func Username(username string) (string, error) {
+	return precis.UsernameCaseMapped.String(strings.TrimSpace(username))
+}
@@ -0,0 +1,53 @@
+package reqlog
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+)
+
+type contextKey struct{}
+
+// request is the state of one request, the principal is only known after the token is checked
+type request struct {
+	log *slog.Logger
+
+	mu        sync.Mutex
+	principal string
+}
+
+// NewContext returns a context carrying the logger of the request
+func NewContext(ctx context.Context, log *slog.Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, &request{log: log})
+}
+
+// From returns the logger of the request, fallback if the context carries none
+func From(ctx context.Context, fallback *slog.Logger) *slog.Logger {
+	if r, ok := ctx.Value(contextKey{}).(*request); ok {
+		return r.log
+	}
+
+	return fallback
+}
+
+// SetPrincipal records who made the request, e.g. "user:42", it is a no-op outside of a request
+func SetPrincipal(ctx context.Context, principal string) {
+	if r, ok := ctx.Value(contextKey{}).(*request); ok {
+		r.mu.Lock()
+		r.principal = principal
+		r.mu.Unlock()
+	}
+}
+
+// Principal returns who made the request, empty if it is unknown
+func Principal(ctx context.Context) string {
+	r, ok := ctx.Value(contextKey{}).(*request)
+	if !ok {
+		return ""
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.principal
+}
@@ -0,0 +1,33 @@
+package requestid
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+)
+
+// maxLen is the max length of request ids sent by clients
+const maxLen = 128
+
+// New returns a random request id of 32 hex characters
+func New() string {
+	id := make([]byte, 16)
+	_, _ = rand.Read(id)
+
+	return hex.EncodeToString(id)
+}
+
+// Valid reports whether a request id sent by a client can be used,
+// only printable ascii is allowed so the id can not break log lines
+func Valid(id string) bool {
+	if id == "" || len(id) > maxLen {
+		return false
+	}
+
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+
+	return true
+}
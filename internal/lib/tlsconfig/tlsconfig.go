@@ -0,0 +1,217 @@
+package tlsconfig
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// checkInterval is the min time between two checks of the files for changes
+const checkInterval = 5 * time.Second
+
+var (
+	ErrNoCertificates = errors.New("no certificates found")
+	ErrNoClientCA     = errors.New("client certificates are verified but no client CA file is configured")
+)
+
+// Reloader serves the certificate and the client CAs from their files
+// and reloads them on the next handshake after the files changed, so certificates are rotated without restart
+type Reloader struct {
+	base         *tls.Config
+	certFile     string
+	keyFile      string
+	clientCAFile string
+	log          *slog.Logger
+
+	mu        sync.Mutex
+	current   *tls.Config
+	modTimes  []time.Time
+	lastCheck time.Time
+}
+
+// NewReloader loads the files and returns the reloader, base contains the settings that do not come from files;
+// clientCAFile is required if base verifies client certificates, the system roots would accept any public certificate
+func NewReloader(base *tls.Config, certFile, keyFile, clientCAFile string, log *slog.Logger) (*Reloader, error) {
+	if base.ClientAuth >= tls.VerifyClientCertIfGiven && clientCAFile == "" {
+		return nil, ErrNoClientCA
+	}
+
+	r := &Reloader{base: base, certFile: certFile, keyFile: keyFile, clientCAFile: clientCAFile, log: log}
+
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// Config returns the tls config of the listener, every handshake uses the latest loaded files
+func (r *Reloader) Config() *tls.Config {
+	return &tls.Config{
+		MinVersion: r.base.MinVersion,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			return r.config(), nil
+		},
+	}
+}
+
+// config returns the current config, reloading it first if the files changed
+func (r *Reloader) config() *tls.Config {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if time.Since(r.lastCheck) < checkInterval {
+		return r.current
+	}
+	r.lastCheck = time.Now()
+
+	modTimes, err := r.fileModTimes()
+	if err != nil || equalTimes(modTimes, r.modTimes) {
+		return r.current
+	}
+
+	// a failed reload keeps the old files, e.g. if the key was written but the certificate not yet
+	if err = r.loadLocked(); err != nil {
+		r.log.With("op", "tlsconfig.Reloader").Error("Error on reloading tls files, keeping the old ones", "err", err)
+		return r.current
+	}
+
+	r.log.With("op", "tlsconfig.Reloader").Info("Reloaded tls files", "cert", r.certFile)
+	return r.current
+}
+
+func (r *Reloader) load() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.lastCheck = time.Now()
+	return r.loadLocked()
+}
+
+// loadLocked loads the files into a new config, r.mu has to be held
+func (r *Reloader) loadLocked() error {
+	modTimes, err := r.fileModTimes()
+	if err != nil {
+		return err
+	}
+
+	certificate, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return err
+	}
+
+	config := r.base.Clone()
+	config.Certificates = []tls.Certificate{certificate}
+
+	if r.clientCAFile != "" {
+		if config.ClientCAs, err = LoadCertPool(r.clientCAFile); err != nil {
+			return err
+		}
+	}
+
+	r.current = config
+	r.modTimes = modTimes
+	return nil
+}
+
+func (r *Reloader) fileModTimes() ([]time.Time, error) {
+	var modTimes []time.Time
+
+	for _, file := range []string{r.certFile, r.keyFile, r.clientCAFile} {
+		if file == "" {
+			continue
+		}
+
+		info, err := os.Stat(file)
+		if err != nil {
+			return nil, err
+		}
+		modTimes = append(modTimes, info.ModTime())
+	}
+
+	return modTimes, nil
+}
+
+func equalTimes(a, b []time.Time) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if !a[i].Equal(b[i]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// LoadCertPool returns a pool of the pem encoded certificates in the file
+func LoadCertPool(file string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("%w in %s", ErrNoCertificates, file)
+	}
+
+	return pool, nil
+}
+
+// ParseVersion parses the min tls version "1.2" or "1.3", empty is 1.2
+func ParseVersion(version string) (uint16, error) {
+	switch version {
+	case "", "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	default:
+		return 0, fmt.Errorf("unsupported tls version %q, expected 1.2 or 1.3", version)
+	}
+}
+
+// ParseCipherSuites returns the ids of the named cipher suites, empty names are the go defaults;
+// only the suites without known weaknesses can be used, the tls 1.3 suites are not configurable
+func ParseCipherSuites(names []string) ([]uint16, error) {
+	var ids []uint16
+
+	for _, name := range names {
+		found := false
+		for _, suite := range tls.CipherSuites() {
+			if strings.EqualFold(suite.Name, name) {
+				ids = append(ids, suite.ID)
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return nil, fmt.Errorf("unknown or insecure cipher suite %q", name)
+		}
+	}
+
+	return ids, nil
+}
+
+// ParseClientAuth parses the client certificate policy: "none", "optional" (verified if given) or "require"
+func ParseClientAuth(clientAuth string) (tls.ClientAuthType, error) {
+	switch clientAuth {
+	case "", "none":
+		return tls.NoClientCert, nil
+	case "optional":
+		return tls.VerifyClientCertIfGiven, nil
+	case "require":
+		return tls.RequireAndVerifyClientCert, nil
+	default:
+		return 0, fmt.Errorf("unsupported client auth %q, expected none, optional or require", clientAuth)
+	}
+}
@@ -0,0 +1,258 @@
+package tracing
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+	"strings"
+)
+
+// OpenDB opens the database like sql.Open, every query, exec, transaction and ping gets a span
+func OpenDB(driverName, dsn string) (*sql.DB, error) {
+	db, err := sql.Open(driverName, dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	// sql.Open only looks up the driver, nothing is connected yet
+	drv := db.Driver()
+	if err = db.Close(); err != nil {
+		return nil, err
+	}
+
+	var base driver.Connector = dsnConnector{driver: drv, dsn: dsn}
+	if driverContext, ok := drv.(driver.DriverContext); ok {
+		if base, err = driverContext.OpenConnector(dsn); err != nil {
+			return nil, err
+		}
+	}
+
+	return sql.OpenDB(&connector{base: base, system: driverName}), nil
+}
+
+type dsnConnector struct {
+	driver driver.Driver
+	dsn    string
+}
+
+func (c dsnConnector) Connect(context.Context) (driver.Conn, error) {
+	return c.driver.Open(c.dsn)
+}
+
+func (c dsnConnector) Driver() driver.Driver {
+	return c.driver
+}
+
+type connector struct {
+	base   driver.Connector
+	system string
+}
+
+func (c *connector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.base.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &tracedConn{Conn: conn, system: c.system}, nil
+}
+
+func (c *connector) Driver() driver.Driver {
+	return c.base.Driver()
+}
+
+// tracedConn traces the calls of database/sql, interfaces the driver does not implement return driver.ErrSkip,
+// so database/sql falls back like it does for the driver itself
+type tracedConn struct {
+	driver.Conn
+	system string
+}
+
+func (c *tracedConn) start(ctx context.Context, operation, query string) (context.Context, trace.Span) {
+	attrs := []attribute.KeyValue{semconv.DBSystemKey.String(c.system), semconv.DBOperation(operation)}
+	if query != "" {
+		attrs = append(attrs, semconv.DBStatement(query))
+	}
+
+	return Start(ctx, "db."+operation, attrs...)
+}
+
+func (c *tracedConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	queryer, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+
+	ctx, span := c.start(ctx, operation(query), query)
+	rows, err := queryer.QueryContext(ctx, query, args)
+	End(span, skipped(err))
+
+	return rows, err
+}
+
+func (c *tracedConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	execer, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+
+	ctx, span := c.start(ctx, operation(query), query)
+	result, err := execer.ExecContext(ctx, query, args)
+	End(span, skipped(err))
+
+	return result, err
+}
+
+func (c *tracedConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	var (
+		stmt driver.Stmt
+		err  error
+	)
+
+	if preparer, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		stmt, err = preparer.PrepareContext(ctx, query)
+	} else {
+		stmt, err = c.Conn.Prepare(query)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &tracedStmt{Stmt: stmt, conn: c, query: query}, nil
+}
+
+func (c *tracedConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	spanCtx, span := c.start(ctx, "BEGIN", "")
+
+	var (
+		tx  driver.Tx
+		err error
+	)
+
+	if beginner, ok := c.Conn.(driver.ConnBeginTx); ok {
+		tx, err = beginner.BeginTx(spanCtx, opts)
+	} else {
+		tx, err = c.Conn.Begin()
+	}
+	End(span, err)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &tracedTx{Tx: tx, ctx: ctx, conn: c}, nil
+}
+
+func (c *tracedConn) Ping(ctx context.Context) error {
+	pinger, ok := c.Conn.(driver.Pinger)
+	if !ok {
+		return nil
+	}
+
+	ctx, span := c.start(ctx, "PING", "")
+	err := pinger.Ping(ctx)
+	End(span, err)
+
+	return err
+}
+
+func (c *tracedConn) ResetSession(ctx context.Context) error {
+	if resetter, ok := c.Conn.(driver.SessionResetter); ok {
+		return resetter.ResetSession(ctx)
+	}
+
+	return nil
+}
+
+func (c *tracedConn) IsValid() bool {
+	if validator, ok := c.Conn.(driver.Validator); ok {
+		return validator.IsValid()
+	}
+
+	return true
+}
+
+func (c *tracedConn) CheckNamedValue(value *driver.NamedValue) error {
+	if checker, ok := c.Conn.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(value)
+	}
+
+	return driver.ErrSkip
+}
+
+type tracedStmt struct {
+	driver.Stmt
+	conn  *tracedConn
+	query string
+}
+
+func (s *tracedStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	queryer, ok := s.Stmt.(driver.StmtQueryContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+
+	ctx, span := s.conn.start(ctx, operation(s.query), s.query)
+	rows, err := queryer.QueryContext(ctx, args)
+	End(span, err)
+
+	return rows, err
+}
+
+func (s *tracedStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	execer, ok := s.Stmt.(driver.StmtExecContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+
+	ctx, span := s.conn.start(ctx, operation(s.query), s.query)
+	result, err := execer.ExecContext(ctx, args)
+	End(span, err)
+
+	return result, err
+}
+
+// tracedTx traces the commit and rollback as siblings of the BEGIN
+type tracedTx struct {
+	driver.Tx
+	ctx  context.Context
+	conn *tracedConn
+}
+
+func (t *tracedTx) Commit() error {
+	_, span := t.conn.start(t.ctx, "COMMIT", "")
+	err := t.Tx.Commit()
+	End(span, err)
+
+	return err
+}
+
+func (t *tracedTx) Rollback() error {
+	_, span := t.conn.start(t.ctx, "ROLLBACK", "")
+	err := t.Tx.Rollback()
+	End(span, err)
+
+	return err
+}
+
+// operation returns the first keyword of the query, e.g. SELECT
+func operation(query string) string {
+	fields := strings.Fields(query)
+	if len(fields) == 0 {
+		return "QUERY"
+	}
+
+	return strings.ToUpper(fields[0])
+}
+
+// skipped hides driver.ErrSkip, it only tells database/sql to prepare the statement first
+func skipped(err error) error {
+	if err == driver.ErrSkip {
+		return nil
+	}
+
+	return err
+}
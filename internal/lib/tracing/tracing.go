@@ -0,0 +1,107 @@
+package tracing
+
+import (
+	"context"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/metadata"
+)
+
+// InstrumentationName is the name of the tracer of all spans of the service
+const InstrumentationName = "sso_go_grpc"
+
+// Options configures the provider of NewProvider
+type Options struct {
+	ServiceName string
+	// SampleRatio is the ratio of the sampled root traces, children follow the decision of their parent
+	SampleRatio float64
+}
+
+// NewOTLPExporter returns an exporter sending the spans over OTLP/gRPC to the collector at endpoint (host:port),
+// it connects in the background so a collector that is down does not block the start
+func NewOTLPExporter(ctx context.Context, endpoint string, insecure bool) (sdktrace.SpanExporter, error) {
+	options := []otlptracegrpc.Option{otlptracegrpc.WithEndpoint(endpoint)}
+	if insecure {
+		options = append(options, otlptracegrpc.WithInsecure())
+	}
+
+	return otlptracegrpc.New(ctx, options...)
+}
+
+// NewProvider returns a provider batching the spans to the exporter,
+// tests can pass the in-memory exporter of go.opentelemetry.io/otel/sdk/trace/tracetest
+func NewProvider(exporter sdktrace.SpanExporter, opts Options) *sdktrace.TracerProvider {
+	return sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(opts.ServiceName))),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(opts.SampleRatio))),
+	)
+}
+
+// SetGlobal makes the provider and the W3C trace context propagator the globals used by Start,
+// a nil provider keeps the no-op provider, so only the trace context is propagated
+func SetGlobal(provider trace.TracerProvider) {
+	if provider != nil {
+		otel.SetTracerProvider(provider)
+	}
+
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+}
+
+// Start starts a span as child of the span in the context
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(InstrumentationName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End records the error on the span and ends it
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	span.End()
+}
+
+// Extract returns the context with the remote span of the trace context in the incoming metadata
+func Extract(ctx context.Context) context.Context {
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	return otel.GetTextMapPropagator().Extract(ctx, MetadataCarrier(md))
+}
+
+// Inject writes the trace context of the span in the context into the metadata
+func Inject(ctx context.Context, md metadata.MD) {
+	otel.GetTextMapPropagator().Inject(ctx, MetadataCarrier(md))
+}
+
+// MetadataCarrier carries the trace context in gRPC metadata
+type MetadataCarrier metadata.MD
+
+func (c MetadataCarrier) Get(key string) string {
+	if values := metadata.MD(c).Get(key); len(values) > 0 {
+		return values[0]
+	}
+
+	return ""
+}
+
+func (c MetadataCarrier) Set(key, value string) {
+	metadata.MD(c).Set(key, value)
+}
+
+func (c MetadataCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for key := range c {
+		keys = append(keys, key)
+	}
+
+	return keys
+}
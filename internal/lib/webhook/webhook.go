@@ -0,0 +1,54 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+const (
+	// SignatureHeader carries "t=<unix timestamp>,v1=<hex HMAC-SHA256 of "<timestamp>.<body>">"
+	SignatureHeader = "X-Sso-Signature"
+	EventHeader     = "X-Sso-Event"
+	DeliveryHeader  = "X-Sso-Delivery"
+)
+
+// NewSecret returns a random secret for a subscription
+func NewSecret() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+
+	return "whsec_" + hex.EncodeToString(secret), nil
+}
+
+// Sign returns the value of the signature header of the body sent at the timestamp;
+// the timestamp is signed too, so receivers can reject replayed deliveries
+func Sign(secret string, timestamp time.Time, body []byte) string {
+	unix := strconv.FormatInt(timestamp.Unix(), 10)
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(unix + "."))
+	mac.Write(body)
+
+	return fmt.Sprintf("t=%s,v1=%s", unix, hex.EncodeToString(mac.Sum(nil)))
+}
+
+// Backoff returns the delay before the next attempt after the given amount of failed attempts,
+// it doubles with every attempt and is capped at max
+func Backoff(attempts uint32, base, max time.Duration) time.Duration {
+	delay := base
+	for i := uint32(1); i < attempts; i++ {
+		delay *= 2
+		if delay >= max {
+			return max
+		}
+	}
+
+	return delay
+}
@@ -0,0 +1,92 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSign(t *testing.T) {
+	timestamp := time.Unix(1700000000, 0)
+	body := []byte(`{"type":"user.created"}`)
+
+	signature := Sign("whsec_test", timestamp, body)
+
+	unix, digest, ok := strings.Cut(signature, ",v1=")
+	if !ok || unix != "t=1700000000" {
+		t.Fatalf("Sign() = %q, want t=1700000000,v1=<hex>", signature)
+	}
+
+	mac := hmac.New(sha256.New, []byte("whsec_test"))
+	mac.Write([]byte("1700000000." + string(body)))
+	if want := hex.EncodeToString(mac.Sum(nil)); digest != want {
+		t.Errorf("digest = %s, want %s", digest, want)
+	}
+}
+
+func TestSignCoversEveryInput(t *testing.T) {
+	timestamp := time.Unix(1700000000, 0)
+	body := []byte(`{"type":"user.created"}`)
+	signature := Sign("whsec_test", timestamp, body)
+
+	tests := []struct {
+		name      string
+		secret    string
+		timestamp time.Time
+		body      []byte
+	}{
+		{"other secret", "whsec_other", timestamp, body},
+		{"other timestamp", "whsec_test", timestamp.Add(time.Second), body},
+		{"other body", "whsec_test", timestamp, []byte(`{"type":"user.deleted"}`)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Sign(tt.secret, tt.timestamp, tt.body); got == signature {
+				t.Errorf("Sign() = %s, want a signature different from %s", got, signature)
+			}
+		})
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempts uint32
+		want     time.Duration
+	}{
+		{0, time.Second},
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{3, 4 * time.Second},
+		{6, 32 * time.Second},
+		{7, time.Minute},
+		{100, time.Minute},
+	}
+
+	for _, tt := range tests {
+		if got := Backoff(tt.attempts, time.Second, time.Minute); got != tt.want {
+			t.Errorf("Backoff(%d) = %s, want %s", tt.attempts, got, tt.want)
+		}
+	}
+}
+
+func TestNewSecret(t *testing.T) {
+	first, err := NewSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := NewSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasPrefix(first, "whsec_") || len(first) != len("whsec_")+64 {
+		t.Errorf("NewSecret() = %q, want whsec_ and 64 hex characters", first)
+	}
+	if first == second {
+		t.Error("NewSecret() returned the same secret twice")
+	}
+}
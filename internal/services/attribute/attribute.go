@@ -0,0 +1,79 @@
+package attributeService
+
+import (
+	"context"
+	"log/slog"
+	"sso_go_grpc/internal/config"
+	"sso_go_grpc/internal/domain/models"
+	attrs "sso_go_grpc/internal/lib/attributes"
+	"sso_go_grpc/internal/lib/reqlog"
+	"sso_go_grpc/internal/lib/tracing"
+	userService "sso_go_grpc/internal/services/user"
+	"sso_go_grpc/internal/storage/postgres/attribute"
+)
+
+type AttributeService struct {
+	userService       *userService.UserService
+	cfg               *config.Config
+	log               *slog.Logger
+	attributeProvider *attribute.Storage
+}
+
+func New(userService *userService.UserService, cfg *config.Config, log *slog.Logger, attributeProvider *attribute.Storage) *AttributeService {
+	return &AttributeService{userService: userService, cfg: cfg, log: log, attributeProvider: attributeProvider}
+}
+
+// SetDefinition creates or replaces an attribute definition, only for admins
+func (s *AttributeService) SetDefinition(
+	ctx context.Context,
+	token string,
+	definition *models.AttributeDefinition,
+) (*models.AttributeDefinition, error) {
+	ctx, span := tracing.Start(ctx, "service.attribute.SetDefinition")
+	defer span.End()
+
+	op := "service.attribute.SetDefinition"
+	logger := reqlog.From(ctx, s.log).With("op", op)
+
+	if err := s.userService.RequireAdmin(ctx, token); err != nil {
+		return nil, err
+	}
+
+	if err := attrs.ValidateDefinition(definition); err != nil {
+		logger.Debug("Invalid attribute definition", "err", err)
+		return nil, err
+	}
+
+	return s.attributeProvider.SaveDefinition(ctx, definition)
+}
+
+// DeleteDefinition deletes an attribute definition and the attribute of all users, only for admins
+func (s *AttributeService) DeleteDefinition(
+	ctx context.Context,
+	token string,
+	name string,
+) error {
+	ctx, span := tracing.Start(ctx, "service.attribute.DeleteDefinition")
+	defer span.End()
+
+	if err := s.userService.RequireAdmin(ctx, token); err != nil {
+		return err
+	}
+
+	return s.attributeProvider.DeleteDefinition(ctx, name)
+}
+
+// ListDefinitions returns all attribute definitions
+func (s *AttributeService) ListDefinitions(
+	ctx context.Context,
+	token string,
+) ([]*models.AttributeDefinition, error) {
+	ctx, span := tracing.Start(ctx, "service.attribute.ListDefinitions")
+	defer span.End()
+
+	if _, _, err := s.userService.Principal(ctx, token); err != nil {
+		return nil, err
+	}
+
+	return s.attributeProvider.GetDefinitions(ctx)
+}
@@ -0,0 +1,174 @@
+package auditService
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sso_go_grpc/internal/config"
+	"sso_go_grpc/internal/domain/models"
+	"sso_go_grpc/internal/lib/auditchain"
+	"sso_go_grpc/internal/lib/cursor"
+	"sso_go_grpc/internal/lib/reqlog"
+	"sso_go_grpc/internal/lib/tracing"
+	userService "sso_go_grpc/internal/services/user"
+	"sso_go_grpc/internal/storage"
+	"sso_go_grpc/internal/storage/postgres/audit"
+	"time"
+)
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 500
+)
+
+type AuditService struct {
+	userService   *userService.UserService
+	cfg           *config.Config
+	log           *slog.Logger
+	auditProvider *audit.Storage
+}
+
+func New(userService *userService.UserService, cfg *config.Config, log *slog.Logger, auditProvider *audit.Storage) *AuditService {
+	return &AuditService{userService: userService, cfg: cfg, log: log, auditProvider: auditProvider}
+}
+
+// QueryAuditLog returns one page of audit entries matching the filter, newest first,
+// and the token of the next page; only for admins
+func (s *AuditService) QueryAuditLog(
+	ctx context.Context,
+	token string,
+	filter models.AuditFilter,
+	pageToken string,
+	pageSize uint32,
+) (
+	entries []*models.AuditEntry,
+	nextPageToken string,
+	err error,
+) {
+	ctx, span := tracing.Start(ctx, "service.audit.QueryAuditLog")
+	defer span.End()
+
+	op := "service.audit.QueryAuditLog"
+	logger := reqlog.From(ctx, s.log).With("op", op)
+
+	if err = s.userService.RequireAdmin(ctx, token); err != nil {
+		return nil, "", err
+	}
+
+	filter.BeforeId, err = cursor.Decode(pageToken)
+	if err != nil {
+		logger.Debug("Invalid page token", "pageToken", pageToken)
+		return nil, "", storage.ErrInvalidPageToken
+	}
+
+	switch {
+	case pageSize == 0:
+		pageSize = defaultPageSize
+	case pageSize > maxPageSize:
+		pageSize = maxPageSize
+	}
+
+	// fetch one more entry to know if there is a next page
+	filter.Limit = int(pageSize) + 1
+
+	entries, err = s.auditProvider.QueryAuditLog(ctx, filter)
+	if err != nil {
+		logger.Debug("Error on querying audit log", "err", err)
+		return nil, "", err
+	}
+
+	if len(entries) > int(pageSize) {
+		entries = entries[:pageSize]
+		nextPageToken = cursor.Encode(entries[len(entries)-1].Id)
+	}
+
+	return entries, nextPageToken, nil
+}
+
+// VerifyChain recomputes the hash chain of the audit log without checking permissions,
+// it is used by the ssoctl cli
+func (s *AuditService) VerifyChain(ctx context.Context) (*models.AuditChainVerification, error) {
+	ctx, span := tracing.Start(ctx, "service.audit.VerifyChain")
+	defer span.End()
+
+	return s.auditProvider.VerifyChain(ctx)
+}
+
+// Checkpoint verifies the chain and returns a checkpoint of its head signed with the checkpoint key,
+// it is used by the ssoctl cli
+func (s *AuditService) Checkpoint(ctx context.Context) (*models.AuditCheckpoint, error) {
+	ctx, span := tracing.Start(ctx, "service.audit.Checkpoint")
+	defer span.End()
+
+	if s.cfg.Audit.CheckpointKey == "" {
+		return nil, storage.ErrNoCheckpointKey
+	}
+
+	key, err := auditchain.ParseSigningKey(s.cfg.Audit.CheckpointKey)
+	if err != nil {
+		return nil, err
+	}
+
+	verification, err := s.auditProvider.VerifyChain(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// a checkpoint of a broken chain would certify the tampered entries
+	if verification.BrokenAt != 0 {
+		return nil, fmt.Errorf("%w at entry %d: %s", storage.ErrAuditChainBroken, verification.BrokenAt, verification.Reason)
+	}
+
+	checkpoint := &models.AuditCheckpoint{
+		Entries:   verification.Entries,
+		LastId:    verification.LastId,
+		LastHash:  verification.LastHash,
+		CreatedAt: time.Now().UTC(),
+	}
+	auditchain.SignCheckpoint(key, checkpoint)
+
+	return checkpoint, nil
+}
+
+// VerifyCheckpoint returns an error if the signature of the checkpoint is invalid
+// or if the entry of the checkpoint was changed or removed since it was signed
+func (s *AuditService) VerifyCheckpoint(ctx context.Context, checkpoint *models.AuditCheckpoint) error {
+	ctx, span := tracing.Start(ctx, "service.audit.VerifyCheckpoint")
+	defer span.End()
+
+	if !auditchain.VerifyCheckpoint(checkpoint) {
+		return errors.New("checkpoint signature is invalid")
+	}
+
+	// with a configured key only checkpoints signed by it are trusted
+	if s.cfg.Audit.CheckpointKey != "" {
+		key, err := auditchain.ParseSigningKey(s.cfg.Audit.CheckpointKey)
+		if err != nil {
+			return err
+		}
+
+		if auditchain.PublicKey(key) != checkpoint.PublicKey {
+			return errors.New("checkpoint is not signed with the configured checkpoint key")
+		}
+	}
+
+	// an empty chain has nothing to compare
+	if checkpoint.LastId == 0 {
+		return nil
+	}
+
+	hash, err := s.auditProvider.GetHash(ctx, checkpoint.LastId)
+	if err != nil {
+		if errors.Is(err, storage.ErrAuditEntryNotExists) {
+			return fmt.Errorf("%w: entry %d of the checkpoint was removed", storage.ErrAuditChainBroken, checkpoint.LastId)
+		}
+		return err
+	}
+
+	if hash != checkpoint.LastHash {
+		return fmt.Errorf("%w: hash of entry %d differs from the checkpoint", storage.ErrAuditChainBroken, checkpoint.LastId)
+	}
+
+	return nil
+}
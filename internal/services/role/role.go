@@ -3,10 +3,16 @@ package roleService
 import (
 	"context"
 	"errors"
+	"fmt"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 	"log/slog"
 	"sso_go_grpc/internal/config"
+	"sso_go_grpc/internal/domain/models"
+	"sso_go_grpc/internal/lib/client"
+	"sso_go_grpc/internal/lib/metrics"
+	"sso_go_grpc/internal/lib/reqlog"
+	"sso_go_grpc/internal/lib/tracing"
 	userService "sso_go_grpc/internal/services/user"
 	"sso_go_grpc/internal/storage"
 	"sso_go_grpc/internal/storage/postgres/role"
@@ -24,16 +30,15 @@ type roleServiceInterface interface {
 		ctx context.Context,
 		token string,
 		roleId uint64,
-		name,
-		description string,
-
+		update models.RoleUpdate,
 	) (role *sso.Role,
 		err error)
 
 	DeleteRole(
 		ctx context.Context,
 		token string,
-		roleId uint64,
+		roleId,
+		expectedVersion uint64,
 	) (
 		err error)
 
@@ -79,7 +84,15 @@ func (s *RoleService) CreateRole(
 	*sso.Role,
 	error,
 ) {
-	role, err := s.roleProvider.CreateRole(ctx, name, description)
+	ctx, span := tracing.Start(ctx, "service.role.CreateRole")
+	defer span.End()
+
+	entry, err := s.auditEntry(ctx, token, models.AuditActionRoleCreate)
+	if err != nil {
+		return nil, err
+	}
+
+	role, err := s.roleProvider.CreateRole(ctx, name, description, entry)
 
 	if err != nil {
 		if errors.Is(storage.ErrRoleExists, err) {
@@ -88,7 +101,7 @@ func (s *RoleService) CreateRole(
 		return nil, err
 	}
 
-	return &sso.Role{RoleId: role.Id, Name: role.Name, Description: role.Description}, nil
+	return &sso.Role{RoleId: role.Id, Name: role.Name, Description: role.Description, Version: role.Version}, nil
 }
 
 func (s *RoleService) GetUserRoles(
@@ -96,15 +109,28 @@ func (s *RoleService) GetUserRoles(
 	userId uint64,
 ) (roles []*sso.Role,
 	err error) {
+	ctx, span := tracing.Start(ctx, "service.role.GetUserRoles")
+	defer span.End()
+
 	return nil, status.Error(codes.Internal, "Not implemented")
 }
 
+// DeleteRole deletes the role, an expectedVersion other than 0 has to be the version of the role
 func (s *RoleService) DeleteRole(
 	ctx context.Context,
 	token string,
-	roleId uint64,
+	roleId,
+	expectedVersion uint64,
 ) error {
-	err := s.roleProvider.DeleteRole(ctx, roleId)
+	ctx, span := tracing.Start(ctx, "service.role.DeleteRole")
+	defer span.End()
+
+	entry, err := s.auditEntry(ctx, token, models.AuditActionRoleDelete)
+	if err != nil {
+		return err
+	}
+
+	err = s.roleProvider.DeleteRole(ctx, roleId, expectedVersion, entry)
 
 	if err != nil {
 		if errors.Is(storage.ErrRoleNotExists, err) {
@@ -115,17 +141,38 @@ func (s *RoleService) DeleteRole(
 	return nil
 }
 
+// UpdateRole updates the fields of the update that are not nil, the name can not be emptied;
+// an update without fields returns the role unchanged
 func (s *RoleService) UpdateRole(
 	ctx context.Context,
 	token string,
 	roleId uint64,
-	name,
-	description string,
-
+	update models.RoleUpdate,
 ) (*sso.Role,
 	error,
 ) {
-	role, err := s.roleProvider.UpdateRole(ctx, name, description, roleId)
+	ctx, span := tracing.Start(ctx, "service.role.UpdateRole")
+	defer span.End()
+
+	entry, err := s.auditEntry(ctx, token, models.AuditActionRoleUpdate)
+	if err != nil {
+		return nil, err
+	}
+
+	if update.Name != nil && *update.Name == "" {
+		return nil, fmt.Errorf("name: %w", storage.ErrEmptyValue)
+	}
+
+	var role *models.Role
+
+	if update.Name == nil && update.Description == nil {
+		role, err = s.roleProvider.GetRoleById(ctx, roleId)
+		if err == nil && update.ExpectedVersion != 0 && role.Version != update.ExpectedVersion {
+			err = storage.ErrVersionMismatch
+		}
+	} else {
+		role, err = s.roleProvider.UpdateRole(ctx, roleId, update, entry)
+	}
 
 	if err != nil {
 		return nil, err
@@ -135,6 +182,7 @@ func (s *RoleService) UpdateRole(
 		RoleId:      role.Id,
 		Name:        role.Name,
 		Description: role.Description,
+		Version:     role.Version,
 	}, nil
 }
 
@@ -144,14 +192,22 @@ func (s *RoleService) AddUserRole(
 	roleId,
 	userId uint64,
 ) (*sso.User, error) {
+	ctx, span := tracing.Start(ctx, "service.role.AddUserRole")
+	defer span.End()
+
 	op := "service.s.AddUserRole"
-	logger := s.log.With("op", op)
+	logger := reqlog.From(ctx, s.log).With("op", op)
+
+	entry, err := s.auditEntry(ctx, token, models.AuditActionUserRoleAdd)
+	if err != nil {
+		return nil, err
+	}
 
 	// check if userId and roleId are valid
-	err := s.CheckUserAndRoleExists(ctx, userId, roleId)
+	err = s.CheckUserAndRoleExists(ctx, userId, roleId)
 
 	if err != nil {
-		logger.Debug("Error on checking user and role ids", err)
+		logger.Debug("Error on checking user and role ids", "err", err)
 		return nil, storage.ErrUserAndRoleIvalid
 	}
 
@@ -163,11 +219,12 @@ func (s *RoleService) AddUserRole(
 	}
 
 	// add role
-	err = s.roleProvider.AddUserRole(ctx, roleId, userId)
+	err = s.roleProvider.AddUserRole(ctx, roleId, userId, entry)
 
 	if err != nil {
 		return nil, err
 	}
+	metrics.RoleAssignments.WithLabelValues(metrics.RoleAssigned).Inc()
 
 	return s.userService.GetUserById(ctx, userId)
 }
@@ -179,19 +236,27 @@ func (s *RoleService) RemoveUserRole(
 	userId uint64,
 
 ) (*sso.User, error) {
+	ctx, span := tracing.Start(ctx, "service.role.RemoveUserRole")
+	defer span.End()
+
 	op := "service.s.RemoveUserRole"
-	logger := s.log.With("op", op)
+	logger := reqlog.From(ctx, s.log).With("op", op)
+
+	entry, err := s.auditEntry(ctx, token, models.AuditActionUserRoleRemove)
+	if err != nil {
+		return nil, err
+	}
 
 	// check if userId and roleId are valid
-	err := s.CheckUserAndRoleExists(ctx, userId, roleId)
+	err = s.CheckUserAndRoleExists(ctx, userId, roleId)
 	if err != nil {
-		logger.Debug("Error on checking user and role ids", err)
+		logger.Debug("Error on checking user and role ids", "err", err)
 		return nil, storage.ErrUserAndRoleIvalid
 	}
 
 	hasTheRole, err := s.roleProvider.VerifyUserRole(ctx, roleId, userId)
 	if err != nil {
-		logger.Debug("Error on checking user role", err)
+		logger.Debug("Error on checking user role", "err", err)
 		return nil, err
 	}
 
@@ -200,11 +265,12 @@ func (s *RoleService) RemoveUserRole(
 		return nil, storage.ErrUserDontHaveTheRole
 	}
 
-	err = s.roleProvider.RemoveUserRole(ctx, roleId, userId)
+	err = s.roleProvider.RemoveUserRole(ctx, roleId, userId, entry)
 
 	if err != nil {
 		return nil, err
 	}
+	metrics.RoleAssignments.WithLabelValues(metrics.RoleRemoved).Inc()
 
 	//get new user with updated roles
 	user, err := s.userService.GetUserById(ctx, userId)
@@ -219,7 +285,7 @@ func (s *RoleService) RemoveUserRole(
 		roles = append(roles, &sso.Role{RoleId: role.RoleId, Description: role.Description, Name: role.Name})
 	}
 
-	return &sso.User{UserId: userId, Roles: roles, Username: user.Username, Email: user.Email}, nil
+	return &sso.User{UserId: userId, Roles: roles, Username: user.Username, Email: user.Email, Version: user.Version}, nil
 }
 
 func (s *RoleService) VerifyUserRoles(
@@ -227,6 +293,8 @@ func (s *RoleService) VerifyUserRoles(
 	roleIds []uint64,
 	userId uint64,
 ) (verified bool, err error) {
+	ctx, span := tracing.Start(ctx, "service.role.VerifyUserRoles")
+	defer span.End()
 
 	//op := "service.s.VerifyUserRole"
 	//logger := s.log.With("op", op)
@@ -266,6 +334,9 @@ func (s *RoleService) VerifyUserRoles(
 // user with that id or;
 // role with that role id do not exist;
 func (s *RoleService) CheckUserAndRoleExists(ctx context.Context, userId, roleId uint64) error {
+	ctx, span := tracing.Start(ctx, "service.role.CheckUserAndRoleExists")
+	defer span.End()
+
 	//check user exists
 	_, err := s.userService.GetUserById(ctx, userId)
 
@@ -291,3 +362,25 @@ func (s *RoleService) CheckUserAndRoleExists(ctx context.Context, userId, roleId
 
 	return nil
 }
+
+// auditEntry returns the audit entry of the change with the user of the token as actor,
+// the role RPCs are not restricted, so callers without a valid token are recorded with actor 0
+func (s *RoleService) auditEntry(ctx context.Context, token, action string) (*models.AuditEntry, error) {
+	entry := &models.AuditEntry{
+		Action:    action,
+		RequestId: client.RequestId(ctx),
+		IP:        client.IP(ctx),
+	}
+
+	principal, _, err := s.userService.Principal(ctx, token)
+	if err != nil {
+		if errors.Is(err, storage.ErrInvalidToken) {
+			return entry, nil
+		}
+		return nil, err
+	}
+
+	entry.ActorId = principal.UserId
+
+	return entry, nil
+}
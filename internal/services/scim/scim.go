@@ -0,0 +1,292 @@
+package scimService
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sso_go_grpc/internal/config"
+	"sso_go_grpc/internal/domain/models"
+	"sso_go_grpc/internal/lib/bcrypt"
+	"sso_go_grpc/internal/lib/client"
+	"sso_go_grpc/internal/lib/reqlog"
+	"sso_go_grpc/internal/lib/tracing"
+	"sso_go_grpc/internal/storage"
+	"sso_go_grpc/internal/storage/postgres/role"
+	"sso_go_grpc/internal/storage/postgres/user"
+)
+
+// ScimService maps the SCIM provisioning operations onto the users and roles,
+// SCIM groups are roles and their members are the users with the role
+type ScimService struct {
+	userProvider *user.Storage
+	roleProvider *role.Storage
+	cfg          *config.Config
+	log          *slog.Logger
+}
+
+// Group is a role with its members
+type Group struct {
+	Role    *models.Role
+	Members []*models.User
+}
+
+func New(userProvider *user.Storage, roleProvider *role.Storage, cfg *config.Config, log *slog.Logger) *ScimService {
+	return &ScimService{userProvider: userProvider, roleProvider: roleProvider, cfg: cfg, log: log}
+}
+
+// GetUser returns the user with its roles
+func (s *ScimService) GetUser(ctx context.Context, userId uint64) (*models.User, error) {
+	ctx, span := tracing.Start(ctx, "service.scim.GetUser")
+	defer span.End()
+
+	return s.userProvider.GetUserById(ctx, userId)
+}
+
+// ListUsers returns count users matching the filter starting at startIndex (1-based)
+// and the total amount of matching users
+func (s *ScimService) ListUsers(
+	ctx context.Context,
+	filter models.UserFilter,
+	startIndex,
+	count int,
+) ([]*models.User, uint64, error) {
+	ctx, span := tracing.Start(ctx, "service.scim.ListUsers")
+	defer span.End()
+
+	op := "service.scim.ListUsers"
+	logger := reqlog.From(ctx, s.log).With("op", op)
+
+	total, err := s.userProvider.CountUsers(ctx, filter)
+	if err != nil {
+		logger.Debug("Error on counting users", "err", err)
+		return nil, 0, err
+	}
+
+	if count == 0 {
+		return nil, total, nil
+	}
+
+	filter.Offset = startIndex - 1
+	filter.Limit = count
+
+	users, err := s.userProvider.ListUsers(ctx, filter)
+	if err != nil {
+		logger.Debug("Error on listing users", "err", err)
+		return nil, 0, err
+	}
+
+	userIds := make([]uint64, 0, len(users))
+	for _, user := range users {
+		userIds = append(userIds, user.UserId)
+	}
+
+	roles, err := s.userProvider.GetRolesByUserIds(ctx, userIds)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	for _, user := range users {
+		user.Roles = roles[user.UserId]
+	}
+
+	return users, total, nil
+}
+
+// CreateUser creates the user, the password is optional
+func (s *ScimService) CreateUser(ctx context.Context, user *models.User, password string) (*models.User, error) {
+	ctx, span := tracing.Start(ctx, "service.scim.CreateUser")
+	defer span.End()
+
+	op := "service.scim.CreateUser"
+	logger := reqlog.From(ctx, s.log).With("op", op)
+
+	pwdHash, err := hashPassword(ctx, password)
+	if err != nil {
+		return nil, err
+	}
+
+	// deactivated users are created with their status, so they are never active
+	created, err := s.userProvider.CreateUserWithHash(ctx, user.Email, pwdHash, user.Username, user.Status)
+	if err != nil {
+		logger.Debug("Error on creating user", "err", err)
+		return nil, err
+	}
+
+	return created, nil
+}
+
+// ReplaceUser updates email, username and status of the user,
+// the password is only changed if it is not empty;
+// returns storage.ErrVersionMismatch if expectedVersion is not 0 and the user was changed in the meantime
+func (s *ScimService) ReplaceUser(ctx context.Context, user *models.User, password string, expectedVersion uint64) (*models.User, error) {
+	ctx, span := tracing.Start(ctx, "service.scim.ReplaceUser")
+	defer span.End()
+
+	op := "service.scim.ReplaceUser"
+	logger := reqlog.From(ctx, s.log).With("op", op)
+
+	if err := s.userProvider.UpdateUser(ctx, user, expectedVersion); err != nil {
+		logger.Debug("Error on updating user", "err", err)
+		return nil, err
+	}
+
+	if password != "" {
+		pwdHash, err := hashPassword(ctx, password)
+		if err != nil {
+			return nil, err
+		}
+
+		if err = s.userProvider.UpdatePassword(ctx, user.UserId, pwdHash); err != nil {
+			return nil, err
+		}
+	}
+
+	return s.userProvider.GetUserById(ctx, user.UserId)
+}
+
+// DeleteUser deletes the user and its role assignments, every removed assignment is audited and revoked with an event
+func (s *ScimService) DeleteUser(ctx context.Context, userId uint64) error {
+	ctx, span := tracing.Start(ctx, "service.scim.DeleteUser")
+	defer span.End()
+
+	return s.userProvider.DeleteUser(ctx, userId, auditEntry(ctx, models.AuditActionUserRoleRemove))
+}
+
+// GetGroup returns the role with its members
+func (s *ScimService) GetGroup(ctx context.Context, roleId uint64) (*Group, error) {
+	ctx, span := tracing.Start(ctx, "service.scim.GetGroup")
+	defer span.End()
+
+	role, err := s.roleProvider.GetRoleById(ctx, roleId)
+	if err != nil {
+		return nil, err
+	}
+
+	members, err := s.roleProvider.GetRoleMembers(ctx, roleId)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Group{Role: role, Members: members}, nil
+}
+
+// ListGroups returns count roles starting at startIndex (1-based) and the total amount of roles,
+// displayName filters by the role name if it is not empty
+func (s *ScimService) ListGroups(
+	ctx context.Context,
+	displayName string,
+	startIndex,
+	count int,
+) ([]*Group, uint64, error) {
+	ctx, span := tracing.Start(ctx, "service.scim.ListGroups")
+	defer span.End()
+
+	roles, err := s.roleProvider.ListRoles(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var matching []*models.Role
+	for _, role := range roles {
+		if displayName == "" || role.Name == displayName {
+			matching = append(matching, role)
+		}
+	}
+
+	total := uint64(len(matching))
+
+	// the page is cut out of all matching roles
+	from := min(startIndex-1, len(matching))
+	to := min(from+count, len(matching))
+
+	var groups []*Group
+	for _, role := range matching[from:to] {
+		members, err := s.roleProvider.GetRoleMembers(ctx, role.Id)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		groups = append(groups, &Group{Role: role, Members: members})
+	}
+
+	return groups, total, nil
+}
+
+// CreateGroup creates the role and assigns it to the members in one transaction
+func (s *ScimService) CreateGroup(ctx context.Context, name string, memberIds []uint64) (*Group, error) {
+	ctx, span := tracing.Start(ctx, "service.scim.CreateGroup")
+	defer span.End()
+
+	role, err := s.roleProvider.CreateRoleWithMembers(
+		ctx,
+		name,
+		"",
+		memberIds,
+		auditEntry(ctx, models.AuditActionRoleCreate),
+		auditEntry(ctx, models.AuditActionRoleSetMembers),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetGroup(ctx, role.Id)
+}
+
+// ReplaceGroup renames the role and replaces its members
+func (s *ScimService) ReplaceGroup(ctx context.Context, roleId uint64, name string, memberIds []uint64) (*Group, error) {
+	ctx, span := tracing.Start(ctx, "service.scim.ReplaceGroup")
+	defer span.End()
+
+	role, err := s.roleProvider.GetRoleById(ctx, roleId)
+	if err != nil {
+		return nil, err
+	}
+
+	if name != role.Name {
+		existing, err := s.roleProvider.GetRoleByName(ctx, name)
+		if err == nil && existing.Id != roleId {
+			return nil, storage.ErrRoleExists
+		}
+
+		if _, err = s.roleProvider.UpdateRole(ctx, roleId, models.RoleUpdate{Name: &name}, auditEntry(ctx, models.AuditActionRoleUpdate)); err != nil {
+			return nil, err
+		}
+	}
+
+	if err = s.roleProvider.SetRoleMembers(ctx, roleId, memberIds, auditEntry(ctx, models.AuditActionRoleSetMembers)); err != nil {
+		return nil, err
+	}
+
+	return s.GetGroup(ctx, roleId)
+}
+
+// DeleteGroup deletes the role and removes it from all users
+func (s *ScimService) DeleteGroup(ctx context.Context, roleId uint64) error {
+	ctx, span := tracing.Start(ctx, "service.scim.DeleteGroup")
+	defer span.End()
+
+	if _, err := s.roleProvider.GetRoleById(ctx, roleId); err != nil {
+		return err
+	}
+
+	return s.roleProvider.DeleteRole(ctx, roleId, 0, auditEntry(ctx, models.AuditActionRoleDelete))
+}
+
+// auditEntry returns the audit entry of a change of the SCIM client, it has no user so the actor is 0
+func auditEntry(ctx context.Context, action string) *models.AuditEntry {
+	return &models.AuditEntry{Action: action, RequestId: client.RequestId(ctx), IP: client.IP(ctx)}
+}
+
+// hashPassword hashes the password, an empty password stays empty
+func hashPassword(ctx context.Context, password string) (string, error) {
+	if password == "" {
+		return "", nil
+	}
+
+	pwdHash, err := bcrypt.HashPassword(ctx, password)
+	if err != nil {
+		return "", errors.Join(storage.ErrInvalidPassword, err)
+	}
+
+	return pwdHash, nil
+}
@@ -3,9 +3,18 @@ package services
 import (
 	"log/slog"
 	"sso_go_grpc/internal/config"
+	attributeService "sso_go_grpc/internal/services/attribute"
+	auditService "sso_go_grpc/internal/services/audit"
 	roleService "sso_go_grpc/internal/services/role"
+	scimService "sso_go_grpc/internal/services/scim"
 	userService "sso_go_grpc/internal/services/user"
+	webhookService "sso_go_grpc/internal/services/webhook"
 	"sso_go_grpc/internal/storage/postgres"
+	"sso_go_grpc/internal/storage/postgres/attribute"
+	"sso_go_grpc/internal/storage/postgres/audit"
+	"sso_go_grpc/internal/storage/postgres/idempotency"
+	"sso_go_grpc/internal/storage/postgres/login"
+	"sso_go_grpc/internal/storage/postgres/outbox"
 	roleStorage "sso_go_grpc/internal/storage/postgres/role"
 	"sso_go_grpc/internal/storage/postgres/user"
 )
@@ -14,31 +23,59 @@ type Services struct {
 	Log *slog.Logger
 	Cfg *config.Config
 	Providers
-	UserService *userService.UserService
-	RoleService *roleService.RoleService
+	UserService      *userService.UserService
+	RoleService      *roleService.RoleService
+	AttributeService *attributeService.AttributeService
+	ScimService      *scimService.ScimService
+	AuditService     *auditService.AuditService
+	WebhookService   *webhookService.WebhookService
 }
 
 type Providers struct {
-	UserProvider *user.Storage
-	RoleProvider *roleStorage.Storage
+	UserProvider      *user.Storage
+	RoleProvider      *roleStorage.Storage
+	AttributeProvider *attribute.Storage
+	LoginProvider     *login.Storage
+	AuditProvider     *audit.Storage
+	OutboxProvider    *outbox.Storage
+	// IdempotencyProvider is used by the gRPC server, the services do not know the idempotency keys
+	IdempotencyProvider *idempotency.Storage
 }
 
 // New this function returns new AuthService with userProvider where are all the postgres methods
 func New(log *slog.Logger, storage *postgres.Storage, config *config.Config) *Services {
 	providers := Providers{
-		UserProvider: storage.User,
-		RoleProvider: storage.Role,
+		UserProvider:      storage.User,
+		RoleProvider:      storage.Role,
+		AttributeProvider: storage.Attribute,
+		LoginProvider:     storage.Login,
+		AuditProvider:     storage.Audit,
+		OutboxProvider:    storage.Outbox,
+
+		IdempotencyProvider: storage.Idempotency,
 	}
 
-	user := userService.New(providers.UserProvider, log, config)
+	user := userService.New(providers.UserProvider, providers.AttributeProvider, providers.LoginProvider, providers.OutboxProvider, providers.AuditProvider, log, config)
 
 	role := roleService.New(user, config, log, providers.RoleProvider)
 
+	attribute := attributeService.New(user, config, log, providers.AttributeProvider)
+
+	audit := auditService.New(user, config, log, providers.AuditProvider)
+
+	webhook := webhookService.New(user, config, log, providers.OutboxProvider)
+
+	scim := scimService.New(providers.UserProvider, providers.RoleProvider, config, log)
+
 	return &Services{
-		Providers:   providers,
-		Cfg:         config,
-		Log:         log,
-		RoleService: role,
-		UserService: user,
+		Providers:        providers,
+		Cfg:              config,
+		Log:              log,
+		RoleService:      role,
+		UserService:      user,
+		AttributeService: attribute,
+		ScimService:      scim,
+		AuditService:     audit,
+		WebhookService:   webhook,
 	}
 }
@@ -0,0 +1,135 @@
+package userService
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"sso_go_grpc/internal/domain/models"
+	"sso_go_grpc/internal/lib/reqlog"
+	"sso_go_grpc/internal/lib/tracing"
+	"sso_go_grpc/internal/storage"
+	"time"
+)
+
+// ExportUserData returns the json export of everything stored about the user,
+// users can export their own data, admins the data of anyone
+func (s *UserService) ExportUserData(
+	ctx context.Context,
+	token string,
+	userId uint64,
+) ([]byte, error) {
+	ctx, span := tracing.Start(ctx, "service.user.ExportUserData")
+	defer span.End()
+
+	principal, isAdmin, err := s.Principal(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	if !isAdmin && principal.UserId != userId {
+		return nil, storage.ErrNoPermission
+	}
+
+	export, err := s.BuildUserExport(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.MarshalIndent(export, "", "  ")
+}
+
+// BuildUserExport collects everything stored about the user without checking permissions,
+// it is used by ExportUserData and the ssoctl cli
+func (s *UserService) BuildUserExport(ctx context.Context, userId uint64) (*models.UserExport, error) {
+	ctx, span := tracing.Start(ctx, "service.user.BuildUserExport")
+	defer span.End()
+
+	op := "service.user.BuildUserExport"
+	logger := reqlog.From(ctx, s.log).With("op", op)
+
+	user, err := s.userProvider.GetUserById(ctx, userId)
+	if err != nil {
+		logger.Debug("Error on getting user", "userId", userId, "err", err)
+		return nil, err
+	}
+
+	roles, err := s.userProvider.GetRoleAssignments(ctx, userId)
+	if err != nil {
+		logger.Debug("Error on getting role assignments", "err", err)
+		return nil, err
+	}
+
+	logins, err := s.loginProvider.ListLoginHistory(ctx, userId, 0, 0)
+	if err != nil {
+		logger.Debug("Error on getting login history", "err", err)
+		return nil, err
+	}
+
+	auditEntries, err := s.userAuditEntries(ctx, userId)
+	if err != nil {
+		logger.Debug("Error on getting audit entries", "err", err)
+		return nil, err
+	}
+
+	profile := models.UserExportProfile{
+		UserId:     user.UserId,
+		Email:      user.Email,
+		Username:   user.Username,
+		Status:     user.Status,
+		CreatedAt:  user.CreatedAt,
+		Attributes: user.Attributes,
+	}
+
+	if !user.LastLoginAt.IsZero() {
+		profile.LastLoginAt = &user.LastLoginAt
+	}
+
+	return &models.UserExport{
+		ExportedAt:   time.Now().UTC(),
+		User:         profile,
+		Roles:        roles,
+		Logins:       logins,
+		AuditEntries: auditEntries,
+		Notes:        []string{models.UserExportSessionsNote},
+	}, nil
+}
+
+// userAuditEntries returns the audit entries the user is the actor or the target of, newest first
+func (s *UserService) userAuditEntries(ctx context.Context, userId uint64) ([]*models.UserExportAuditEntry, error) {
+	asActor, err := s.auditProvider.QueryAuditLog(ctx, models.AuditFilter{ActorId: &userId})
+	if err != nil {
+		return nil, err
+	}
+
+	asTarget, err := s.auditProvider.QueryAuditLog(ctx, models.AuditFilter{TargetType: models.AuditTargetUser, TargetId: userId})
+	if err != nil {
+		return nil, err
+	}
+
+	// changes of the user on itself are in both lists
+	byId := make(map[uint64]*models.AuditEntry, len(asActor)+len(asTarget))
+	for _, entry := range append(asActor, asTarget...) {
+		byId[entry.Id] = entry
+	}
+
+	entries := make([]*models.UserExportAuditEntry, 0, len(byId))
+	for _, entry := range byId {
+		entries = append(entries, &models.UserExportAuditEntry{
+			Id:         entry.Id,
+			ActorId:    entry.ActorId,
+			Action:     entry.Action,
+			TargetType: entry.TargetType,
+			TargetId:   entry.TargetId,
+			Before:     entry.Before,
+			After:      entry.After,
+			IP:         entry.IP,
+			CreatedAt:  entry.CreatedAt,
+		})
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Id > entries[j].Id
+	})
+
+	return entries, nil
+}
@@ -0,0 +1,175 @@
+package userService
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/mail"
+	"sso_go_grpc/internal/domain/models"
+	"sso_go_grpc/internal/lib/bcrypt"
+	"sso_go_grpc/internal/lib/client"
+	"sso_go_grpc/internal/lib/normalize"
+	"sso_go_grpc/internal/lib/reqlog"
+	"sso_go_grpc/internal/lib/tracing"
+	"sso_go_grpc/internal/storage"
+	"unicode/utf8"
+)
+
+// MaxImportUsers is the most users one ImportUsers call can import
+const MaxImportUsers = 10000
+
+// the limits of the rows are the rules of RegisterRequest
+const (
+	maxEmailLength    = 255
+	minUsernameLength = 3
+	maxUsernameLength = 64
+)
+
+// ImportUsers imports all users returned by next until it returns io.EOF, only for admins;
+// more than MaxImportUsers users fail with storage.ErrImportTooLarge, see RunImport
+func (s *UserService) ImportUsers(
+	ctx context.Context,
+	token string,
+	dryRun bool,
+	next func() (*models.ImportUser, error),
+) ([]*models.ImportResult, error) {
+	ctx, span := tracing.Start(ctx, "service.user.ImportUsers")
+	defer span.End()
+
+	admin, isAdmin, err := s.Principal(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	if !isAdmin {
+		return nil, storage.ErrNoPermission
+	}
+
+	// the stream is buffered by RunImport, so the number of users is bounded
+	var count int
+	limited := func() (*models.ImportUser, error) {
+		user, err := next()
+		if err != nil {
+			return nil, err
+		}
+		if count++; count > MaxImportUsers {
+			return nil, storage.ErrImportTooLarge
+		}
+		return user, nil
+	}
+
+	return s.RunImport(ctx, dryRun, admin.UserId, limited)
+}
+
+// RunImport imports all users returned by next until it returns io.EOF without checking permissions,
+// it is used by ImportUsers and the ssoctl cli, the assigned roles are audited with the actor, 0 for the cli.
+// All users are read before the transaction starts, so a slow client does not keep it open.
+// Every user gets a result, failing users do not stop the import;
+// on a dry run everything is checked but nothing is saved
+func (s *UserService) RunImport(
+	ctx context.Context,
+	dryRun bool,
+	actorId uint64,
+	next func() (*models.ImportUser, error),
+) ([]*models.ImportResult, error) {
+	ctx, span := tracing.Start(ctx, "service.user.RunImport")
+	defer span.End()
+
+	op := "service.user.RunImport"
+	logger := reqlog.From(ctx, s.log).With("op", op)
+
+	var users []*models.ImportUser
+
+	for {
+		user, err := next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			logger.Debug("Error on reading the users", "err", err)
+			return nil, err
+		}
+		users = append(users, user)
+	}
+
+	entry := &models.AuditEntry{ActorId: actorId, RequestId: client.RequestId(ctx), IP: client.IP(ctx)}
+
+	importer, err := s.userProvider.BeginImport(ctx, entry)
+	if err != nil {
+		logger.Debug("Error on starting the import", "err", err)
+		return nil, err
+	}
+
+	results := make([]*models.ImportResult, 0, len(users))
+
+	for i, user := range users {
+		row := uint64(i + 1)
+
+		result := &models.ImportResult{Row: row, Email: user.Email}
+		results = append(results, result)
+
+		if err = validateImportUser(user); err != nil {
+			result.Error = err.Error()
+			continue
+		}
+
+		userId, err := importer.Import(ctx, user)
+		if err != nil {
+			if !errors.Is(err, storage.ErrUserExists) &&
+				!errors.Is(err, storage.ErrInvalidUsername) &&
+				!errors.Is(err, storage.ErrRoleNotExists) {
+				logger.Debug("Error on importing user", "row", row, "err", err)
+				importer.Rollback()
+				return nil, err
+			}
+			result.Error = err.Error()
+			continue
+		}
+
+		// on a dry run the ids are rolled back
+		if !dryRun {
+			result.UserId = userId
+		}
+	}
+
+	if dryRun {
+		return results, importer.Rollback()
+	}
+
+	if err = importer.Commit(); err != nil {
+		logger.Debug("Error on committing the import", "err", err)
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// validateImportUser checks the row before it is imported with the rules of Register
+func validateImportUser(user *models.ImportUser) error {
+	if user.Email == "" || user.Username == "" {
+		return storage.ErrInvalidImportUser
+	}
+
+	if !isEmail(user.Email) || utf8.RuneCountInString(user.Email) > maxEmailLength {
+		return storage.ErrInvalidEmail
+	}
+
+	if length := utf8.RuneCountInString(user.Username); length < minUsernameLength || length > maxUsernameLength {
+		return storage.ErrInvalidUsernameLength
+	}
+	if _, err := normalize.Username(user.Username); err != nil {
+		return storage.ErrInvalidUsername
+	}
+
+	if user.PasswordHash != "" && !bcrypt.IsHash(user.PasswordHash) {
+		return storage.ErrInvalidPasswordHash
+	}
+
+	return nil
+}
+
+// isEmail reports whether the text is a bare address, without a display name or angle brackets
+func isEmail(text string) bool {
+	address, err := mail.ParseAddress(text)
+
+	return err == nil && address.Name == "" && address.Address == text
+}
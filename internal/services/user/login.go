@@ -0,0 +1,84 @@
+package userService
+
+import (
+	"context"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"sso_go_grpc/internal/lib/cursor"
+	"sso_go_grpc/internal/lib/reqlog"
+	"sso_go_grpc/internal/lib/tracing"
+	"sso_go_grpc/internal/storage"
+	sso "sso_go_grpc/proto/gen"
+)
+
+// ListLoginHistory returns one page of login attempts of the user, newest first, and the token of the next page;
+// users can list their own history, admins the history of anyone
+func (s *UserService) ListLoginHistory(
+	ctx context.Context,
+	token string,
+	userId uint64,
+	pageToken string,
+	pageSize uint32,
+) (
+	attempts []*sso.LoginAttempt,
+	nextPageToken string,
+	err error,
+) {
+	ctx, span := tracing.Start(ctx, "service.user.ListLoginHistory")
+	defer span.End()
+
+	op := "service.user.ListLoginHistory"
+	logger := reqlog.From(ctx, s.log).With("op", op)
+
+	principal, isAdmin, err := s.Principal(ctx, token)
+	if err != nil {
+		return nil, "", err
+	}
+
+	// without userId the own history is listed
+	if userId == 0 {
+		userId = principal.UserId
+	}
+
+	if !isAdmin && principal.UserId != userId {
+		return nil, "", storage.ErrNoPermission
+	}
+
+	beforeId, err := cursor.Decode(pageToken)
+	if err != nil {
+		logger.Debug("Invalid page token", "pageToken", pageToken)
+		return nil, "", storage.ErrInvalidPageToken
+	}
+
+	switch {
+	case pageSize == 0:
+		pageSize = defaultPageSize
+	case pageSize > maxPageSize:
+		pageSize = maxPageSize
+	}
+
+	// fetch one more attempt to know if there is a next page
+	found, err := s.loginProvider.ListLoginHistory(ctx, userId, beforeId, int(pageSize)+1)
+	if err != nil {
+		logger.Debug("Error on listing login history", "err", err)
+		return nil, "", err
+	}
+
+	if len(found) > int(pageSize) {
+		found = found[:pageSize]
+		nextPageToken = cursor.Encode(found[len(found)-1].Id)
+	}
+
+	attempts = make([]*sso.LoginAttempt, 0, len(found))
+	for _, attempt := range found {
+		attempts = append(attempts, &sso.LoginAttempt{
+			Id:        attempt.Id,
+			Email:     attempt.Email,
+			Success:   attempt.Success,
+			Ip:        attempt.IP,
+			UserAgent: attempt.UserAgent,
+			CreatedAt: timestamppb.New(attempt.CreatedAt),
+		})
+	}
+
+	return attempts, nextPageToken, nil
+}
@@ -3,13 +3,27 @@ package userService
 import (
 	"context"
 	"errors"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
 	"log/slog"
 	"sso_go_grpc/internal/config"
+	"sso_go_grpc/internal/domain/models"
+	attrs "sso_go_grpc/internal/lib/attributes"
 	"sso_go_grpc/internal/lib/bcrypt"
+	"sso_go_grpc/internal/lib/client"
+	"sso_go_grpc/internal/lib/cursor"
 	"sso_go_grpc/internal/lib/jwt"
+	"sso_go_grpc/internal/lib/metrics"
+	"sso_go_grpc/internal/lib/reqlog"
+	"sso_go_grpc/internal/lib/tracing"
 	"sso_go_grpc/internal/storage"
+	"sso_go_grpc/internal/storage/postgres/attribute"
+	"sso_go_grpc/internal/storage/postgres/audit"
+	"sso_go_grpc/internal/storage/postgres/login"
+	"sso_go_grpc/internal/storage/postgres/outbox"
 	"sso_go_grpc/internal/storage/postgres/user"
 	sso "sso_go_grpc/proto/gen"
+	"strconv"
 )
 
 type userServiceInterface interface {
@@ -52,17 +66,50 @@ type userServiceInterface interface {
 		role *sso.Role,
 		err error,
 	)
+
+	ListUsers(
+		ctx context.Context,
+		token string,
+		filter models.UserFilter,
+		pageToken string,
+		pageSize uint32,
+		includeRoles bool,
+	) (
+		users []*sso.User,
+		nextPageToken string,
+		err error,
+	)
+
+	UpdateUserAttributes(
+		ctx context.Context,
+		token string,
+		userId,
+		expectedVersion uint64,
+		attributes map[string]any,
+	) (
+		user *sso.User,
+		err error,
+	)
 }
 
+const (
+	defaultPageSize = 50
+	maxPageSize     = 500
+)
+
 type UserService struct {
-	userProvider *user.Storage
-	log          *slog.Logger
-	config       *config.Config
+	userProvider      *user.Storage
+	attributeProvider *attribute.Storage
+	loginProvider     *login.Storage
+	outboxProvider    *outbox.Storage
+	auditProvider     *audit.Storage
+	log               *slog.Logger
+	config            *config.Config
 	userServiceInterface
 }
 
-func New(userProvider *user.Storage, log *slog.Logger, cfg *config.Config) *UserService {
-	return &UserService{userProvider: userProvider, log: log}
+func New(userProvider *user.Storage, attributeProvider *attribute.Storage, loginProvider *login.Storage, outboxProvider *outbox.Storage, auditProvider *audit.Storage, log *slog.Logger, cfg *config.Config) *UserService {
+	return &UserService{userProvider: userProvider, attributeProvider: attributeProvider, loginProvider: loginProvider, outboxProvider: outboxProvider, auditProvider: auditProvider, log: log, config: cfg}
 }
 func (s *UserService) Register(
 	ctx context.Context,
@@ -70,8 +117,11 @@ func (s *UserService) Register(
 	password,
 	username string,
 ) (token string, userId uint64, err error) {
+	ctx, span := tracing.Start(ctx, "service.user.Register")
+	defer span.End()
+
 	op := "service.auth"
-	log := s.log.With("op", op)
+	log := reqlog.From(ctx, s.log).With("op", op)
 	user, err := s.userProvider.CreateUser(ctx, email, password, username)
 
 	if err != nil {
@@ -80,61 +130,99 @@ func (s *UserService) Register(
 
 			return "", 0, storage.ErrUserExists
 		}
-		log.Debug("Error", err)
+		log.Debug("Error", "err", err)
 		return "", 0, err
 	}
 
 	// generate token
-	token, err = jwt.NewToken(user, s.config.JwtSecret)
+	token, err = s.newToken(ctx, user)
 
 	if err != nil {
-		log.Debug("Error on generating jwt", err)
+		log.Debug("Error on generating jwt", "err", err)
 		return "", 0, err
 	}
+
+	metrics.Registrations.Inc()
+	reqlog.SetPrincipal(ctx, principal(user.UserId))
 	return token, user.UserId, nil
 }
 
-func (s *UserService) login(
+func (s *UserService) Login(
 	ctx context.Context,
 	email,
 	password string,
 ) (token string, userId uint64, err error) {
+	ctx, span := tracing.Start(ctx, "service.user.Login")
+	defer span.End()
+
 	op := "auth.service.login"
-	logger := s.log.With("op", op)
+	logger := reqlog.From(ctx, s.log).With("op", op)
 
 	logger.Debug("Getting User from the database")
 	user, err := s.userProvider.GetUserByEmail(ctx, email)
 
 	// if the user is not defined or
-	// if user is defined but the password is incorrect
+	// if user is defined but the password is incorrect or
+	// if the user was deactivated
 	if err != nil ||
-		bcrypt.ComparePasswords(user.Password, password) != nil {
+		bcrypt.ComparePasswords(ctx, user.Password, password) != nil ||
+		user.Status != models.UserStatusActive {
 		logger.Debug("Invalid credentials")
+
+		attempt := &models.LoginAttempt{Email: email}
+		if user != nil {
+			attempt.UserId = user.UserId
+		}
+		s.recordLogin(ctx, attempt)
+
 		return "", 0, storage.ErrAuth
 	}
 
 	// generate token
-	token, err = jwt.NewToken(user, s.config.JwtSecret)
+	token, err = s.newToken(ctx, user)
 
 	if err != nil {
-		logger.Debug("Error on generating jwt", err)
+		logger.Debug("Error on generating jwt", "err", err)
 		return "", 0, err
 	}
+
+	s.recordLogin(ctx, &models.LoginAttempt{UserId: user.UserId, Email: email, Success: true})
+	reqlog.SetPrincipal(ctx, principal(user.UserId))
+
 	return token, user.UserId, nil
 }
 
+// recordLogin saves the attempt with the ip and user agent of the caller,
+// a failure is only logged so the history never blocks a login
+func (s *UserService) recordLogin(ctx context.Context, attempt *models.LoginAttempt) {
+	attempt.IP = client.IP(ctx)
+	attempt.UserAgent = client.UserAgent(ctx)
+
+	if attempt.Success {
+		metrics.Logins.WithLabelValues(metrics.LoginSuccess).Inc()
+	} else {
+		metrics.Logins.WithLabelValues(metrics.LoginFailure).Inc()
+	}
+
+	if err := s.loginProvider.RecordLogin(ctx, attempt); err != nil {
+		reqlog.From(ctx, s.log).With("op", "service.user.recordLogin").Error("Error on recording login attempt", "err", err)
+	}
+}
+
 func (s *UserService) GetUserById(
 	ctx context.Context,
 	userId uint64,
 ) (*sso.User, error) {
+	ctx, span := tracing.Start(ctx, "service.user.GetUserById")
+	defer span.End()
 
 	op := "auth.service.GetUserById"
 
-	logger := s.log.With("op", op)
+	logger := reqlog.From(ctx, s.log).With("op", op)
 	user, err := s.userProvider.GetUserById(ctx, userId)
 
 	if err != nil {
-		logger.Debug("Error on finding user with userId", userId)
+		logger.Debug("Error on finding user", "userId", userId)
 		if errors.Is(storage.ErrUserNotExists, err) {
 			return nil, storage.ErrUserNotExists
 		}
@@ -142,14 +230,7 @@ func (s *UserService) GetUserById(
 		return nil, err
 	}
 
-	var roles []*sso.Role
-
-	for _, role := range user.Roles {
-		protoRole := &sso.Role{RoleId: role.Id, Name: role.Name, Description: role.Description}
-		roles = append(roles, protoRole)
-	}
-
-	return &sso.User{Email: user.Email, UserId: userId, Roles: roles, Username: user.Username}, nil
+	return toProtoUser(user), nil
 }
 
 func (s *UserService) GetUserByEmail(
@@ -159,17 +240,262 @@ func (s *UserService) GetUserByEmail(
 	*sso.User,
 	error,
 ) {
+	ctx, span := tracing.Start(ctx, "service.user.GetUserByEmail")
+	defer span.End()
+
 	user, err := s.userProvider.GetUserByEmail(ctx, email)
 
 	if err != nil {
 		return nil, err
 	}
 
-	var protoRoles []*sso.Role
+	return toProtoUser(user), nil
+}
+
+// ListUsers returns one page of users matching the filter and the token of the next page,
+// the roles are loaded with one query for the whole page if includeRoles is set; only for admins
+func (s *UserService) ListUsers(
+	ctx context.Context,
+	token string,
+	filter models.UserFilter,
+	pageToken string,
+	pageSize uint32,
+	includeRoles bool,
+) (
+	users []*sso.User,
+	nextPageToken string,
+	err error,
+) {
+	ctx, span := tracing.Start(ctx, "service.user.ListUsers")
+	defer span.End()
+
+	op := "service.user.ListUsers"
+	logger := reqlog.From(ctx, s.log).With("op", op)
+
+	if err = s.RequireAdmin(ctx, token); err != nil {
+		return nil, "", err
+	}
+
+	filter.AfterId, err = cursor.Decode(pageToken)
+	if err != nil {
+		logger.Debug("Invalid page token", "pageToken", pageToken)
+		return nil, "", storage.ErrInvalidPageToken
+	}
+
+	switch {
+	case pageSize == 0:
+		pageSize = defaultPageSize
+	case pageSize > maxPageSize:
+		pageSize = maxPageSize
+	}
+
+	// fetch one more user to know if there is a next page
+	filter.Limit = int(pageSize) + 1
+
+	found, err := s.userProvider.ListUsers(ctx, filter)
+	if err != nil {
+		logger.Debug("Error on listing users", "err", err)
+		return nil, "", err
+	}
+
+	if len(found) > int(pageSize) {
+		found = found[:pageSize]
+		nextPageToken = cursor.Encode(found[len(found)-1].UserId)
+	}
+
+	if includeRoles {
+		userIds := make([]uint64, 0, len(found))
+		for _, user := range found {
+			userIds = append(userIds, user.UserId)
+		}
+
+		roles, err := s.userProvider.GetRolesByUserIds(ctx, userIds)
+		if err != nil {
+			logger.Debug("Error on getting roles of users", "err", err)
+			return nil, "", err
+		}
+
+		for _, user := range found {
+			user.Roles = roles[user.UserId]
+		}
+	}
+
+	users = make([]*sso.User, 0, len(found))
+	for _, user := range found {
+		users = append(users, toProtoUser(user))
+	}
+
+	return users, nextPageToken, nil
+}
+
+// UpdateUserAttributes merges the attributes into the attributes of the user, nil removes an attribute;
+// the user itself can only change user-writable attributes, admins can change every attribute.
+// An expectedVersion other than 0 has to be the version of the user
+func (s *UserService) UpdateUserAttributes(
+	ctx context.Context,
+	token string,
+	userId,
+	expectedVersion uint64,
+	attributes map[string]any,
+) (*sso.User, error) {
+	ctx, span := tracing.Start(ctx, "service.user.UpdateUserAttributes")
+	defer span.End()
+
+	op := "service.user.UpdateUserAttributes"
+	logger := reqlog.From(ctx, s.log).With("op", op)
+
+	principal, isAdmin, err := s.Principal(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	if !isAdmin && principal.UserId != userId {
+		return nil, storage.ErrNoPermission
+	}
+
+	user, err := s.userProvider.GetUserById(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	if expectedVersion != 0 && user.Version != expectedVersion {
+		return nil, storage.ErrVersionMismatch
+	}
+
+	definitions, err := s.attributeProvider.GetDefinitions(ctx)
+	if err != nil {
+		logger.Debug("Error on getting attribute definitions", "err", err)
+		return nil, err
+	}
+
+	writable := make(map[string]bool, len(definitions))
+	for _, definition := range definitions {
+		writable[definition.Name] = definition.UserWritable
+	}
+
+	for name, value := range attributes {
+		if !isAdmin && !writable[name] {
+			logger.Debug("Attribute is not writable by the user", "attribute", name)
+			return nil, storage.ErrNoPermission
+		}
+
+		if value == nil {
+			delete(user.Attributes, name)
+			continue
+		}
+		user.Attributes[name] = value
+	}
+
+	if err = attrs.Validate(definitions, user.Attributes); err != nil {
+		return nil, err
+	}
+
+	// the version that was read is expected, so concurrent updates of other attributes are not lost
+	if user.Version, err = s.userProvider.UpdateAttributes(ctx, userId, user.Version, user.Attributes); err != nil {
+		logger.Debug("Error on updating attributes", "err", err)
+		return nil, err
+	}
+
+	return toProtoUser(user), nil
+}
+
+// Principal returns the user of the token and if the user has the admin role,
+// tokens of deactivated users are invalid even before they expire
+func (s *UserService) Principal(ctx context.Context, token string) (*models.User, bool, error) {
+	ctx, span := tracing.Start(ctx, "service.user.Principal")
+	defer span.End()
+
+	userId, err := jwt.ParseToken(token, s.config.JwtSecret)
+	if err != nil {
+		return nil, false, storage.ErrInvalidToken
+	}
+
+	user, err := s.userProvider.GetUserById(ctx, userId)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotExists) {
+			return nil, false, storage.ErrInvalidToken
+		}
+		return nil, false, err
+	}
+
+	if user.Status != models.UserStatusActive {
+		return nil, false, storage.ErrInvalidToken
+	}
+
+	reqlog.SetPrincipal(ctx, principal(user.UserId))
+
+	for _, role := range user.Roles {
+		if role.Name == s.config.AdminRole {
+			return user, true, nil
+		}
+	}
+
+	return user, false, nil
+}
+
+// RequireAdmin returns storage.ErrNoPermission if the user of the token is no admin
+func (s *UserService) RequireAdmin(ctx context.Context, token string) error {
+	ctx, span := tracing.Start(ctx, "service.user.RequireAdmin")
+	defer span.End()
+
+	_, isAdmin, err := s.Principal(ctx, token)
+	if err != nil {
+		return err
+	}
+
+	if !isAdmin {
+		return storage.ErrNoPermission
+	}
+
+	return nil
+}
+
+// principal returns how the user is named in the request logs
+func principal(userId uint64) string {
+	return "user:" + strconv.FormatUint(userId, 10)
+}
+
+// newToken returns a token of the user with the attributes mapped to claims
+func (s *UserService) newToken(ctx context.Context, user *models.User) (string, error) {
+	definitions, err := s.attributeProvider.GetDefinitions(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	issuer := jwt.Issuer{Issuer: s.config.JwtIssuer, Audience: s.config.JwtAudience}
+
+	return jwt.NewToken(user, s.config.JwtSecret, issuer, attrs.Claims(definitions, user.Attributes))
+}
+
+// toProtoUser converts the user model to its proto message, password is never exposed
+func toProtoUser(user *models.User) *sso.User {
+	var roles []*sso.Role
 
 	for _, role := range user.Roles {
-		protoRoles = append(protoRoles, &sso.Role{RoleId: role.Id, Name: role.Name, Description: role.Description})
+		roles = append(roles, &sso.Role{RoleId: role.Id, Name: role.Name, Description: role.Description})
+	}
+
+	protoUser := &sso.User{
+		UserId:   user.UserId,
+		Email:    user.Email,
+		Username: user.Username,
+		Status:   user.Status,
+		Roles:    roles,
+		Version:  user.Version,
+	}
+
+	if !user.CreatedAt.IsZero() {
+		protoUser.CreatedAt = timestamppb.New(user.CreatedAt)
+	}
+
+	if !user.LastLoginAt.IsZero() {
+		protoUser.LastLoginAt = timestamppb.New(user.LastLoginAt)
+	}
+
+	if len(user.Attributes) > 0 {
+		// attributes are decoded from json, so they are always convertible
+		protoUser.Attributes, _ = structpb.NewStruct(user.Attributes)
 	}
 
-	return &sso.User{UserId: user.UserId, Email: user.Email, Username: user.Username, Roles: protoRoles}, err
+	return protoUser
 }
@@ -0,0 +1,112 @@
+package userService
+
+import (
+	"context"
+	"slices"
+	"sso_go_grpc/internal/domain/models"
+	"sso_go_grpc/internal/lib/cursor"
+	"sso_go_grpc/internal/lib/reqlog"
+	"sso_go_grpc/internal/lib/tracing"
+	"sso_go_grpc/internal/storage"
+	"time"
+)
+
+const (
+	// watchBatchSize is the max amount of events read at once
+	watchBatchSize = 500
+	// watchPollInterval reads the events even without a notification,
+	// in case a notification was lost while the listening connection was down
+	watchPollInterval = 30 * time.Second
+)
+
+// WatchUserChanges sends every user and role event after the resume token to send, oldest first,
+// until the context is canceled or send fails; without a resume token only new events are sent;
+// every event is sent with the resume token continuing after it; ready is called once the watch is set up,
+// so the caller can tell a waiting stream from a failed one; only for admins
+func (s *UserService) WatchUserChanges(
+	ctx context.Context,
+	token string,
+	resumeToken string,
+	eventTypes []string,
+	ready func() error,
+	send func(event *models.Event, resumeToken string) error,
+) error {
+	ctx, span := tracing.Start(ctx, "service.user.WatchUserChanges")
+	defer span.End()
+
+	op := "service.user.WatchUserChanges"
+	logger := reqlog.From(ctx, s.log).With("op", op)
+
+	if err := s.RequireAdmin(ctx, token); err != nil {
+		return err
+	}
+
+	for _, eventType := range eventTypes {
+		if !slices.Contains(models.EventTypes, eventType) {
+			return storage.ErrInvalidEventType
+		}
+	}
+
+	// the resume token is the position of the last sent event
+	lastPosition, err := cursor.Decode(resumeToken)
+	if err != nil {
+		logger.Debug("Invalid resume token", "resumeToken", resumeToken)
+		return storage.ErrInvalidResumeToken
+	}
+
+	// subscribing before reading the events, so no event written in between is missed
+	wake, unsubscribe, err := s.outboxProvider.Notifier.Subscribe()
+	if err != nil {
+		logger.Error("Error on subscribing to outbox events", "err", err)
+		return err
+	}
+
+	defer unsubscribe()
+
+	if resumeToken == "" {
+		if lastPosition, err = s.outboxProvider.LatestPosition(ctx); err != nil {
+			return err
+		}
+	}
+
+	if err = ready(); err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(watchPollInterval)
+	defer ticker.Stop()
+
+	for {
+		events, err := s.outboxProvider.ListEvents(ctx, lastPosition, eventTypes, watchBatchSize)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			logger.Error("Error on listing events", "err", err)
+			return err
+		}
+
+		for _, event := range events {
+			lastPosition = event.Position
+			if err = send(event, cursor.Encode(lastPosition)); err != nil {
+				return err
+			}
+		}
+
+		// a full batch means there are probably more events to read right away
+		if len(events) == watchBatchSize {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case _, ok := <-wake:
+			// the notifier is closed on shutdown, the client resumes on another replica
+			if !ok {
+				return storage.ErrShuttingDown
+			}
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package webhookService
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sso_go_grpc/internal/config"
+	"sso_go_grpc/internal/domain/models"
+	"sso_go_grpc/internal/lib/webhook"
+	"sso_go_grpc/internal/storage/postgres/outbox"
+	"strconv"
+	"time"
+)
+
+// Dispatcher fans the outbox events out to the subscriptions and delivers them as signed webhooks;
+// failed deliveries are retried with exponential backoff until they are dead
+type Dispatcher struct {
+	cfg            config.WebhookConfig
+	log            *slog.Logger
+	outboxProvider outbox.StorageInterface
+	client         *http.Client
+}
+
+func NewDispatcher(cfg config.WebhookConfig, log *slog.Logger, outboxProvider outbox.StorageInterface) *Dispatcher {
+	return &Dispatcher{
+		cfg:            cfg,
+		log:            log,
+		outboxProvider: outboxProvider,
+		client:         &http.Client{Timeout: cfg.Timeout},
+	}
+}
+
+// Run dispatches until the context is canceled
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		d.Dispatch(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Dispatch runs one round: the new events become deliveries and the due deliveries are sent
+func (d *Dispatcher) Dispatch(ctx context.Context) {
+	op := "service.webhook.Dispatch"
+	logger := d.log.With("op", op)
+
+	// errors of a stopped dispatcher are only the cancellation
+	if _, err := d.outboxProvider.FanOut(ctx, d.cfg.BatchSize); err != nil {
+		if ctx.Err() == nil {
+			logger.Error("Error on fanning out events", "err", err)
+		}
+		return
+	}
+
+	// the lease covers the longest possible attempt, after it a crashed dispatcher's deliveries are due again
+	deliveries, err := d.outboxProvider.ClaimDeliveries(ctx, d.cfg.BatchSize, 2*d.cfg.Timeout)
+	if err != nil {
+		if ctx.Err() == nil {
+			logger.Error("Error on claiming deliveries", "err", err)
+		}
+		return
+	}
+
+	for _, delivery := range deliveries {
+		if ctx.Err() != nil {
+			return
+		}
+		d.deliver(ctx, delivery)
+	}
+}
+
+// deliver sends the delivery and records the result
+func (d *Dispatcher) deliver(ctx context.Context, delivery *models.WebhookDelivery) {
+	logger := d.log.With("op", "service.webhook.deliver", "delivery", delivery.Id, "event", delivery.Event.Type)
+
+	responseStatus, err := d.send(ctx, delivery)
+	if err == nil {
+		if err = d.outboxProvider.MarkDelivered(ctx, delivery.Id, responseStatus); err != nil {
+			logger.Error("Error on marking delivery as delivered", "err", err)
+		}
+		return
+	}
+
+	// a stopped dispatcher does not count the attempt, the delivery is due again after its lease
+	if ctx.Err() != nil {
+		return
+	}
+
+	attempts := delivery.Attempts + 1
+	dead := attempts >= d.cfg.MaxAttempts
+	nextAttemptAt := time.Now().Add(webhook.Backoff(attempts, d.cfg.BaseBackoff, d.cfg.MaxBackoff))
+
+	logger.Debug("Delivery failed", "attempts", attempts, "dead", dead, "err", err)
+
+	if err = d.outboxProvider.MarkFailed(ctx, delivery.Id, responseStatus, err.Error(), nextAttemptAt, dead); err != nil {
+		logger.Error("Error on marking delivery as failed", "err", err)
+	}
+}
+
+// send posts the signed event to the url of the subscription, every 2xx response is a success
+func (d *Dispatcher) send(ctx context.Context, delivery *models.WebhookDelivery) (int, error) {
+	body, err := json.Marshal(delivery.Event)
+	if err != nil {
+		return 0, err
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.Url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set(webhook.EventHeader, delivery.Event.Type)
+	request.Header.Set(webhook.DeliveryHeader, strconv.FormatUint(delivery.Id, 10))
+	request.Header.Set(webhook.SignatureHeader, webhook.Sign(delivery.Secret, time.Now(), body))
+
+	response, err := d.client.Do(request)
+	if err != nil {
+		return 0, err
+	}
+
+	defer response.Body.Close()
+
+	// the body is not used, reading it lets the connection be reused
+	io.Copy(io.Discard, io.LimitReader(response.Body, 1<<16))
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return response.StatusCode, fmt.Errorf("unexpected response status %d", response.StatusCode)
+	}
+
+	return response.StatusCode, nil
+}
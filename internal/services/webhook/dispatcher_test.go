@@ -0,0 +1,238 @@
+package webhookService
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sso_go_grpc/internal/config"
+	"sso_go_grpc/internal/domain/models"
+	"sso_go_grpc/internal/lib/webhook"
+	"sso_go_grpc/internal/storage/postgres/outbox"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// failure is one MarkFailed call
+type failure struct {
+	deliveryId     uint64
+	responseStatus int
+	lastError      string
+	nextAttemptAt  time.Time
+	dead           bool
+}
+
+// fakeOutbox hands out the pending deliveries and records their results like the outbox table,
+// the methods the dispatcher does not use are left to the nil interface
+type fakeOutbox struct {
+	outbox.StorageInterface
+
+	mu        sync.Mutex
+	pending   []*models.WebhookDelivery
+	delivered map[uint64]int
+	failures  []failure
+}
+
+func newFakeOutbox(deliveries ...*models.WebhookDelivery) *fakeOutbox {
+	return &fakeOutbox{pending: deliveries, delivered: make(map[uint64]int)}
+}
+
+func (o *fakeOutbox) FanOut(ctx context.Context, limit int) (int64, error) {
+	return 0, nil
+}
+
+func (o *fakeOutbox) ClaimDeliveries(ctx context.Context, limit int, lease time.Duration) ([]*models.WebhookDelivery, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	claimed := o.pending
+	o.pending = nil
+	return claimed, nil
+}
+
+func (o *fakeOutbox) MarkDelivered(ctx context.Context, deliveryId uint64, responseStatus int) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.delivered[deliveryId] = responseStatus
+	return nil
+}
+
+func (o *fakeOutbox) MarkFailed(ctx context.Context, deliveryId uint64, responseStatus int, lastError string, nextAttemptAt time.Time, dead bool) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.failures = append(o.failures, failure{deliveryId, responseStatus, lastError, nextAttemptAt, dead})
+	return nil
+}
+
+// retry makes the delivery pending again after a failed attempt, like it is due after its backoff
+func (o *fakeOutbox) retry(delivery *models.WebhookDelivery) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	delivery.Attempts++
+	o.pending = append(o.pending, delivery)
+}
+
+func testConfig() config.WebhookConfig {
+	return config.WebhookConfig{
+		BatchSize:   10,
+		Timeout:     5 * time.Second,
+		MaxAttempts: 3,
+		BaseBackoff: time.Minute,
+		MaxBackoff:  time.Hour,
+	}
+}
+
+func testDelivery(url string) *models.WebhookDelivery {
+	return &models.WebhookDelivery{
+		Id:     5,
+		Event:  &models.Event{Id: 9, Type: models.EventUserCreated, Data: &models.UserEventData{UserId: 1, Email: "jane@example.com"}},
+		Url:    url,
+		Secret: "whsec_test",
+	}
+}
+
+func discardLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+// statusServer answers with the statuses in order and the last one after them,
+// it fails the test if a request is not signed with the secret of the test delivery
+func statusServer(t *testing.T, statuses ...int) *httptest.Server {
+	var (
+		mu       sync.Mutex
+		requests int
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		unix, _, _ := strings.Cut(strings.TrimPrefix(r.Header.Get(webhook.SignatureHeader), "t="), ",")
+		timestamp, _ := strconv.ParseInt(unix, 10, 64)
+		if r.Header.Get(webhook.SignatureHeader) != webhook.Sign("whsec_test", time.Unix(timestamp, 0), body) {
+			t.Errorf("invalid signature %q", r.Header.Get(webhook.SignatureHeader))
+		}
+		if r.Header.Get(webhook.EventHeader) != models.EventUserCreated || r.Header.Get(webhook.DeliveryHeader) != "5" {
+			t.Errorf("event header %q, delivery header %q", r.Header.Get(webhook.EventHeader), r.Header.Get(webhook.DeliveryHeader))
+		}
+
+		mu.Lock()
+		status := statuses[min(requests, len(statuses)-1)]
+		requests++
+		mu.Unlock()
+
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func TestDispatchRetriesUntilDelivered(t *testing.T) {
+	server := statusServer(t, http.StatusInternalServerError, http.StatusServiceUnavailable, http.StatusNoContent)
+
+	delivery := testDelivery(server.URL)
+	store := newFakeOutbox(delivery)
+	dispatcher := NewDispatcher(testConfig(), discardLogger(), store)
+
+	for attempt := 0; attempt < 2; attempt++ {
+		start := time.Now()
+		dispatcher.Dispatch(context.Background())
+
+		if len(store.failures) != attempt+1 {
+			t.Fatalf("attempt %d: %d failures recorded, want %d", attempt+1, len(store.failures), attempt+1)
+		}
+
+		failed := store.failures[attempt]
+		if failed.dead {
+			t.Errorf("attempt %d: delivery is dead before the last attempt", attempt+1)
+		}
+		if failed.responseStatus < 500 || !strings.Contains(failed.lastError, strconv.Itoa(failed.responseStatus)) {
+			t.Errorf("attempt %d: response status %d, error %q", attempt+1, failed.responseStatus, failed.lastError)
+		}
+
+		// the backoff doubles with every failed attempt
+		backoff := webhook.Backoff(uint32(attempt+1), time.Minute, time.Hour)
+		if failed.nextAttemptAt.Before(start.Add(backoff)) || failed.nextAttemptAt.After(time.Now().Add(backoff)) {
+			t.Errorf("attempt %d: next attempt at %s, want in %s", attempt+1, failed.nextAttemptAt, backoff)
+		}
+
+		store.retry(delivery)
+	}
+
+	dispatcher.Dispatch(context.Background())
+
+	if status, ok := store.delivered[delivery.Id]; !ok || status != http.StatusNoContent {
+		t.Errorf("delivered = %v, want delivery %d with status %d", store.delivered, delivery.Id, http.StatusNoContent)
+	}
+	if len(store.failures) != 2 {
+		t.Errorf("%d failures recorded, want 2", len(store.failures))
+	}
+}
+
+func TestDispatchDeadLetter(t *testing.T) {
+	tests := []struct {
+		name     string
+		attempts uint32
+		wantDead bool
+	}{
+		{name: "first attempt", attempts: 0, wantDead: false},
+		{name: "attempt before the last", attempts: 1, wantDead: false},
+		{name: "last attempt", attempts: 2, wantDead: true},
+	}
+
+	server := statusServer(t, http.StatusBadRequest)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			delivery := testDelivery(server.URL)
+			delivery.Attempts = tt.attempts
+
+			store := newFakeOutbox(delivery)
+			NewDispatcher(testConfig(), discardLogger(), store).Dispatch(context.Background())
+
+			if len(store.failures) != 1 || len(store.delivered) != 0 {
+				t.Fatalf("failures %v, delivered %v, want one failure", store.failures, store.delivered)
+			}
+			if failed := store.failures[0]; failed.dead != tt.wantDead || failed.responseStatus != http.StatusBadRequest {
+				t.Errorf("dead = %t with status %d, want dead %t with status %d", failed.dead, failed.responseStatus, tt.wantDead, http.StatusBadRequest)
+			}
+		})
+	}
+}
+
+func TestDispatchUnreachable(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+
+	store := newFakeOutbox(testDelivery(server.URL))
+	NewDispatcher(testConfig(), discardLogger(), store).Dispatch(context.Background())
+
+	if len(store.failures) != 1 {
+		t.Fatalf("%d failures recorded, want 1", len(store.failures))
+	}
+	if failed := store.failures[0]; failed.responseStatus != 0 || failed.lastError == "" || failed.dead {
+		t.Errorf("failure = %+v, want no response status, an error and not dead", failed)
+	}
+}
+
+func TestDispatchStopped(t *testing.T) {
+	server := statusServer(t, http.StatusOK)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	store := newFakeOutbox(testDelivery(server.URL))
+	NewDispatcher(testConfig(), discardLogger(), store).Dispatch(ctx)
+
+	// a stopped dispatcher does not count the attempt
+	if len(store.failures) != 0 || len(store.delivered) != 0 {
+		t.Errorf("failures %v, delivered %v, want none", store.failures, store.delivered)
+	}
+}
@@ -0,0 +1,196 @@
+package webhookService
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"slices"
+	"sso_go_grpc/internal/config"
+	"sso_go_grpc/internal/domain/models"
+	"sso_go_grpc/internal/lib/cursor"
+	"sso_go_grpc/internal/lib/reqlog"
+	"sso_go_grpc/internal/lib/tracing"
+	"sso_go_grpc/internal/lib/webhook"
+	userService "sso_go_grpc/internal/services/user"
+	"sso_go_grpc/internal/storage"
+	"sso_go_grpc/internal/storage/postgres/outbox"
+)
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 500
+)
+
+// WebhookService manages the webhook subscriptions and their deliveries, only for admins
+type WebhookService struct {
+	userService    *userService.UserService
+	cfg            *config.Config
+	log            *slog.Logger
+	outboxProvider *outbox.Storage
+}
+
+func New(userService *userService.UserService, cfg *config.Config, log *slog.Logger, outboxProvider *outbox.Storage) *WebhookService {
+	return &WebhookService{userService: userService, cfg: cfg, log: log, outboxProvider: outboxProvider}
+}
+
+// CreateSubscription creates the subscription with a new secret, it is only returned here
+func (s *WebhookService) CreateSubscription(
+	ctx context.Context,
+	token string,
+	subscription *models.WebhookSubscription,
+) (*models.WebhookSubscription, error) {
+	ctx, span := tracing.Start(ctx, "service.webhook.CreateSubscription")
+	defer span.End()
+
+	op := "service.webhook.CreateSubscription"
+	logger := reqlog.From(ctx, s.log).With("op", op)
+
+	if err := s.userService.RequireAdmin(ctx, token); err != nil {
+		return nil, err
+	}
+
+	if err := validateSubscription(subscription); err != nil {
+		return nil, err
+	}
+
+	secret, err := webhook.NewSecret()
+	if err != nil {
+		return nil, err
+	}
+	subscription.Secret = secret
+	subscription.Active = true
+
+	created, err := s.outboxProvider.CreateSubscription(ctx, subscription)
+	if err != nil {
+		logger.Debug("Error on creating subscription", "err", err)
+		return nil, err
+	}
+
+	return created, nil
+}
+
+// UpdateSubscription replaces url, event types and active state of the subscription
+func (s *WebhookService) UpdateSubscription(
+	ctx context.Context,
+	token string,
+	subscription *models.WebhookSubscription,
+) (*models.WebhookSubscription, error) {
+	ctx, span := tracing.Start(ctx, "service.webhook.UpdateSubscription")
+	defer span.End()
+
+	if err := s.userService.RequireAdmin(ctx, token); err != nil {
+		return nil, err
+	}
+
+	if err := validateSubscription(subscription); err != nil {
+		return nil, err
+	}
+
+	return s.outboxProvider.UpdateSubscription(ctx, subscription)
+}
+
+// DeleteSubscription deletes the subscription and its deliveries
+func (s *WebhookService) DeleteSubscription(ctx context.Context, token string, subscriptionId uint64) error {
+	ctx, span := tracing.Start(ctx, "service.webhook.DeleteSubscription")
+	defer span.End()
+
+	if err := s.userService.RequireAdmin(ctx, token); err != nil {
+		return err
+	}
+
+	return s.outboxProvider.DeleteSubscription(ctx, subscriptionId)
+}
+
+// ListSubscriptions returns all subscriptions without their secrets
+func (s *WebhookService) ListSubscriptions(ctx context.Context, token string) ([]*models.WebhookSubscription, error) {
+	ctx, span := tracing.Start(ctx, "service.webhook.ListSubscriptions")
+	defer span.End()
+
+	if err := s.userService.RequireAdmin(ctx, token); err != nil {
+		return nil, err
+	}
+
+	return s.outboxProvider.ListSubscriptions(ctx)
+}
+
+// ListDeliveries returns one page of deliveries matching the filter, newest first, and the token of the next page
+func (s *WebhookService) ListDeliveries(
+	ctx context.Context,
+	token string,
+	filter models.DeliveryFilter,
+	pageToken string,
+	pageSize uint32,
+) (
+	deliveries []*models.WebhookDelivery,
+	nextPageToken string,
+	err error,
+) {
+	ctx, span := tracing.Start(ctx, "service.webhook.ListDeliveries")
+	defer span.End()
+
+	op := "service.webhook.ListDeliveries"
+	logger := reqlog.From(ctx, s.log).With("op", op)
+
+	if err = s.userService.RequireAdmin(ctx, token); err != nil {
+		return nil, "", err
+	}
+
+	filter.BeforeId, err = cursor.Decode(pageToken)
+	if err != nil {
+		logger.Debug("Invalid page token", "pageToken", pageToken)
+		return nil, "", storage.ErrInvalidPageToken
+	}
+
+	switch {
+	case pageSize == 0:
+		pageSize = defaultPageSize
+	case pageSize > maxPageSize:
+		pageSize = maxPageSize
+	}
+
+	// fetch one more delivery to know if there is a next page
+	filter.Limit = int(pageSize) + 1
+
+	deliveries, err = s.outboxProvider.ListDeliveries(ctx, filter)
+	if err != nil {
+		logger.Debug("Error on listing deliveries", "err", err)
+		return nil, "", err
+	}
+
+	if len(deliveries) > int(pageSize) {
+		deliveries = deliveries[:pageSize]
+		nextPageToken = cursor.Encode(deliveries[len(deliveries)-1].Id)
+	}
+
+	return deliveries, nextPageToken, nil
+}
+
+// Redeliver queues the delivery again with fresh attempts, mostly used for dead deliveries
+func (s *WebhookService) Redeliver(ctx context.Context, token string, deliveryId uint64) error {
+	ctx, span := tracing.Start(ctx, "service.webhook.Redeliver")
+	defer span.End()
+
+	if err := s.userService.RequireAdmin(ctx, token); err != nil {
+		return err
+	}
+
+	return s.outboxProvider.Redeliver(ctx, deliveryId)
+}
+
+// validateSubscription returns storage.ErrInvalidSubscription if the url is no absolute http(s) url
+// or if an event type is unknown
+func validateSubscription(subscription *models.WebhookSubscription) error {
+	parsed, err := url.Parse(subscription.Url)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return fmt.Errorf("%w: url must be an absolute http or https url", storage.ErrInvalidSubscription)
+	}
+
+	for _, eventType := range subscription.EventTypes {
+		if !slices.Contains(models.EventTypes, eventType) {
+			return fmt.Errorf("%w: unknown event type %q", storage.ErrInvalidSubscription, eventType)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,161 @@
+package attribute
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/lib/pq"
+	"log/slog"
+	"sso_go_grpc/internal/domain/models"
+	"sso_go_grpc/internal/storage"
+)
+
+type StorageInterface interface {
+	SaveDefinition(ctx context.Context, definition *models.AttributeDefinition) (*models.AttributeDefinition, error)
+	DeleteDefinition(ctx context.Context, name string) error
+	GetDefinitions(ctx context.Context) ([]*models.AttributeDefinition, error)
+}
+
+type Storage struct {
+	StorageInterface
+	Db  *sql.DB
+	Log *slog.Logger
+}
+
+func CreateStorage(db *sql.DB, log *slog.Logger) *Storage {
+	return &Storage{Db: db, Log: log}
+}
+
+// SaveDefinition creates the definition or replaces the one with the same name
+func (s *Storage) SaveDefinition(ctx context.Context, definition *models.AttributeDefinition) (*models.AttributeDefinition, error) {
+	op := "storage.postgres.SaveDefinition"
+	logger := s.Log.With("op", op)
+
+	_, err := s.Db.ExecContext(ctx, `
+        INSERT INTO "attributeDefinitions" (name, type, required, enum, userWritable, claim, description)
+        VALUES ($1, $2, $3, $4, $5, $6, $7)
+        ON CONFLICT (name) DO UPDATE SET
+            type = EXCLUDED.type,
+            required = EXCLUDED.required,
+            enum = EXCLUDED.enum,
+            userWritable = EXCLUDED.userWritable,
+            claim = EXCLUDED.claim,
+            description = EXCLUDED.description`,
+		definition.Name,
+		definition.Type,
+		definition.Required,
+		pq.Array(definition.Enum),
+		definition.UserWritable,
+		definition.Claim,
+		definition.Description,
+	)
+
+	if err != nil {
+		logger.Debug("Error on executing query", "err", err)
+		return nil, err
+	}
+
+	return s.GetDefinition(ctx, definition.Name)
+}
+
+// GetDefinition returns the definition with that name
+func (s *Storage) GetDefinition(ctx context.Context, name string) (*models.AttributeDefinition, error) {
+	definition, err := scanDefinition(s.Db.QueryRowContext(ctx, `
+        SELECT name, type, required, enum, userWritable, claim, description
+        FROM "attributeDefinitions"
+        WHERE name = $1`, name))
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, storage.ErrAttributeNotExists
+		}
+		return nil, err
+	}
+
+	return definition, nil
+}
+
+// GetDefinitions returns all definitions ordered by name
+func (s *Storage) GetDefinitions(ctx context.Context) ([]*models.AttributeDefinition, error) {
+	rows, err := s.Db.QueryContext(ctx, `
+        SELECT name, type, required, enum, userWritable, claim, description
+        FROM "attributeDefinitions"
+        ORDER BY name`)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var definitions []*models.AttributeDefinition
+	for rows.Next() {
+		definition, err := scanDefinition(rows)
+		if err != nil {
+			return nil, err
+		}
+		definitions = append(definitions, definition)
+	}
+
+	return definitions, rows.Err()
+}
+
+// DeleteDefinition deletes the definition and removes the attribute from all users
+func (s *Storage) DeleteDefinition(ctx context.Context, name string) error {
+	tx, err := s.Db.BeginTx(ctx, nil)
+
+	// if there was an error on creating transaction
+	if err != nil {
+		return err
+	}
+
+	result, err := tx.ExecContext(ctx, `DELETE FROM "attributeDefinitions" WHERE name = $1`, name)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	deletedRows, err := result.RowsAffected()
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if deletedRows == 0 {
+		tx.Rollback()
+		return storage.ErrAttributeNotExists
+	}
+
+	// remove the attribute from all users
+	if _, err = tx.ExecContext(ctx, `UPDATE users SET attributes = attributes - $1, version = version + 1 WHERE attributes ? $1`, name); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// scanDefinition scans one definition row of a query
+func scanDefinition(row interface{ Scan(dest ...any) error }) (*models.AttributeDefinition, error) {
+	var (
+		definition  models.AttributeDefinition
+		description sql.NullString
+	)
+
+	err := row.Scan(
+		&definition.Name,
+		&definition.Type,
+		&definition.Required,
+		pq.Array(&definition.Enum),
+		&definition.UserWritable,
+		&definition.Claim,
+		&description,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	definition.Description = description.String
+	return &definition, nil
+}
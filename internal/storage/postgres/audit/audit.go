@@ -0,0 +1,277 @@
+package audit
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sso_go_grpc/internal/domain/models"
+	"sso_go_grpc/internal/lib/auditchain"
+	"sso_go_grpc/internal/storage"
+	"strings"
+	"time"
+)
+
+// chainLock is the key of the advisory lock serializing the appends to the hash chain
+const chainLock = 7_034_001
+
+type StorageInterface interface {
+	Insert(ctx context.Context, tx *sql.Tx, entry *models.AuditEntry) error
+	QueryAuditLog(ctx context.Context, filter models.AuditFilter) ([]*models.AuditEntry, error)
+	VerifyChain(ctx context.Context) (*models.AuditChainVerification, error)
+	GetHash(ctx context.Context, id uint64) (string, error)
+}
+
+type Storage struct {
+	StorageInterface
+	Db  *sql.DB
+	Log *slog.Logger
+	// HmacKey keys the hashes of the chain, without it plain SHA-256 is used
+	HmacKey []byte
+}
+
+func CreateStorage(db *sql.DB, log *slog.Logger, hmacKey []byte) *Storage {
+	return &Storage{Db: db, Log: log, HmacKey: hmacKey}
+}
+
+// Insert appends the entry to the hash chain in the transaction of the change it describes,
+// so the change and its audit entry are committed or rolled back together
+func (s *Storage) Insert(ctx context.Context, tx *sql.Tx, entry *models.AuditEntry) error {
+	before, err := encodeState(entry.Before)
+	if err != nil {
+		return err
+	}
+
+	after, err := encodeState(entry.After)
+	if err != nil {
+		return err
+	}
+
+	// the lock is held until the transaction ends, so no other entry can be appended
+	// between reading the head of the chain and committing the new entry
+	if _, err = tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1)`, chainLock); err != nil {
+		return err
+	}
+
+	var prevHash sql.NullString
+	err = tx.QueryRowContext(ctx, `SELECT hash FROM "auditLog" ORDER BY id DESC LIMIT 1`).Scan(&prevHash)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+
+	if err = tx.QueryRowContext(ctx, `SELECT nextval(pg_get_serial_sequence('"auditLog"', 'id'))`).Scan(&entry.Id); err != nil {
+		return err
+	}
+
+	// postgres stores microseconds, the hashed time has to be the stored one
+	entry.CreatedAt = time.Now().UTC().Truncate(time.Microsecond)
+	entry.PrevHash = prevHash.String
+
+	if entry.Hash, err = auditchain.Hash(s.HmacKey, entry.PrevHash, entry); err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+        INSERT INTO "auditLog" (id, actorId, action, targetType, targetId, before, after, requestId, ip, created_at, prevHash, hash)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`,
+		entry.Id, entry.ActorId, entry.Action, entry.TargetType, entry.TargetId, before, after,
+		entry.RequestId, entry.IP, entry.CreatedAt, entry.PrevHash, entry.Hash,
+	)
+
+	return err
+}
+
+// VerifyChain walks the audit log from the oldest entry and recomputes every hash,
+// it stops at the first broken link; entries from before the chain are skipped,
+// the first chained entry has no previous hash, so deleting the oldest entries breaks the chain
+func (s *Storage) VerifyChain(ctx context.Context) (*models.AuditChainVerification, error) {
+	rows, err := s.Db.QueryContext(ctx, `
+        SELECT id, actorId, action, targetType, targetId, before, after, requestId, ip, created_at, prevHash, hash
+        FROM "auditLog"
+        ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var (
+		result  models.AuditChainVerification
+		started bool
+	)
+
+	for rows.Next() {
+		entry, err := scanEntry(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		// entries from before the migration have no hash
+		if !started && entry.Hash == "" {
+			continue
+		}
+
+		switch {
+		case !started && entry.PrevHash != "":
+			result.Reason = "first entry of the chain has a previous hash"
+		case started && entry.PrevHash != result.LastHash:
+			result.Reason = "previous hash does not match the hash of the previous entry"
+		case entry.Hash == "":
+			result.Reason = "entry has no hash"
+		default:
+			hash, err := auditchain.Hash(s.HmacKey, entry.PrevHash, entry)
+			if err != nil {
+				return nil, err
+			}
+			if hash != entry.Hash {
+				result.Reason = "hash does not match the content of the entry"
+			}
+		}
+
+		if result.Reason != "" {
+			result.BrokenAt = entry.Id
+			return &result, nil
+		}
+
+		started = true
+		result.Entries++
+		result.LastId = entry.Id
+		result.LastHash = entry.Hash
+	}
+
+	return &result, rows.Err()
+}
+
+// QueryAuditLog returns the newest entries matching the filter, limit 0 returns all entries
+func (s *Storage) QueryAuditLog(ctx context.Context, filter models.AuditFilter) ([]*models.AuditEntry, error) {
+	op := "storage.postgres.QueryAuditLog"
+	logger := s.Log.With("op", op)
+
+	// every filter adds a condition and its argument
+	conditions := []string{"TRUE"}
+	var args []any
+
+	addCondition := func(condition string, arg any) {
+		args = append(args, arg)
+		conditions = append(conditions, strings.ReplaceAll(condition, "$?", fmt.Sprintf("$%d", len(args))))
+	}
+
+	if filter.ActorId != nil {
+		addCondition("actorId = $?", *filter.ActorId)
+	}
+	if filter.Action != "" {
+		addCondition("action = $?", filter.Action)
+	}
+	if filter.TargetType != "" {
+		addCondition("targetType = $?", filter.TargetType)
+	}
+	if filter.TargetId != 0 {
+		addCondition("targetId = $?", filter.TargetId)
+	}
+	if !filter.From.IsZero() {
+		addCondition("created_at >= $?", filter.From)
+	}
+	if !filter.To.IsZero() {
+		addCondition("created_at < $?", filter.To)
+	}
+	if filter.BeforeId != 0 {
+		addCondition("id < $?", filter.BeforeId)
+	}
+
+	args = append(args, filter.Limit)
+	query := fmt.Sprintf(`
+        SELECT id, actorId, action, targetType, targetId, before, after, requestId, ip, created_at, prevHash, hash
+        FROM "auditLog"
+        WHERE %s
+        ORDER BY id DESC
+        LIMIT NULLIF($%d, 0)`, strings.Join(conditions, " AND "), len(args))
+
+	rows, err := s.Db.QueryContext(ctx, query, args...)
+	if err != nil {
+		logger.Debug("Error on querying audit log", "err", err)
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	entries := make([]*models.AuditEntry, 0)
+	for rows.Next() {
+		entry, err := scanEntry(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries, rows.Err()
+}
+
+// GetHash returns the stored hash of the entry, storage.ErrAuditEntryNotExists if there is no entry with the id
+func (s *Storage) GetHash(ctx context.Context, id uint64) (string, error) {
+	var hash sql.NullString
+
+	err := s.Db.QueryRowContext(ctx, `SELECT hash FROM "auditLog" WHERE id = $1`, id).Scan(&hash)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", storage.ErrAuditEntryNotExists
+		}
+		return "", err
+	}
+
+	return hash.String, nil
+}
+
+// scanEntry scans a row of the columns
+// id, actorId, action, targetType, targetId, before, after, requestId, ip, created_at, prevHash, hash
+func scanEntry(rows *sql.Rows) (*models.AuditEntry, error) {
+	var (
+		entry                         models.AuditEntry
+		before, after                 []byte
+		requestId, ip, prevHash, hash sql.NullString
+		err                           error
+	)
+
+	if err = rows.Scan(&entry.Id, &entry.ActorId, &entry.Action, &entry.TargetType, &entry.TargetId, &before, &after, &requestId, &ip, &entry.CreatedAt, &prevHash, &hash); err != nil {
+		return nil, err
+	}
+
+	if entry.Before, err = decodeState(before); err != nil {
+		return nil, err
+	}
+	if entry.After, err = decodeState(after); err != nil {
+		return nil, err
+	}
+
+	entry.RequestId = requestId.String
+	entry.IP = ip.String
+	entry.PrevHash = prevHash.String
+	entry.Hash = hash.String
+
+	return &entry, nil
+}
+
+// encodeState returns the json of the state, nil is stored as NULL
+func encodeState(state any) ([]byte, error) {
+	if state == nil {
+		return nil, nil
+	}
+
+	return json.Marshal(state)
+}
+
+// decodeState returns the stored json as generic value, NULL is nil
+func decodeState(data []byte) (any, error) {
+	if data == nil {
+		return nil, nil
+	}
+
+	var state any
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, err
+	}
+
+	return state, nil
+}
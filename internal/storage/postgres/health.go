@@ -0,0 +1,44 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sso_go_grpc/internal/storage"
+)
+
+// SchemaVersion is the migration version this build needs, it has to be raised with every new migration
+const SchemaVersion = 13
+
+// CheckReady returns an error if the database can not be reached or its schema is not migrated to SchemaVersion;
+// newer schemas are accepted, so old replicas stay ready while a rollout migrates the database
+func (s *Storage) CheckReady(ctx context.Context) error {
+	if err := s.Db.PingContext(ctx); err != nil {
+		return err
+	}
+
+	var (
+		version uint64
+		dirty   bool
+	)
+
+	// schema_migrations is the version table of golang-migrate
+	err := s.Db.QueryRowContext(ctx, `SELECT version, dirty FROM schema_migrations LIMIT 1`).Scan(&version, &dirty)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("%w: no migration was applied", storage.ErrSchemaOutdated)
+		}
+		return err
+	}
+
+	if dirty {
+		return fmt.Errorf("%w: version %d", storage.ErrSchemaDirty, version)
+	}
+
+	if version < SchemaVersion {
+		return fmt.Errorf("%w: version %d, needs %d", storage.ErrSchemaOutdated, version, SchemaVersion)
+	}
+
+	return nil
+}
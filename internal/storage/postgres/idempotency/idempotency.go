@@ -0,0 +1,106 @@
+package idempotency
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"sso_go_grpc/internal/domain/models"
+	"time"
+)
+
+type StorageInterface interface {
+	Claim(ctx context.Context, request *models.IdempotentRequest, lockFor time.Duration) (*models.IdempotentRequest, error)
+	Complete(ctx context.Context, request *models.IdempotentRequest, response []byte, keepFor time.Duration) error
+	Release(ctx context.Context, request *models.IdempotentRequest) error
+	DeleteExpired(ctx context.Context) (int64, error)
+}
+
+type Storage struct {
+	StorageInterface
+	Db  *sql.DB
+	Log *slog.Logger
+}
+
+func CreateStorage(db *sql.DB, log *slog.Logger) *Storage {
+	return &Storage{Db: db, Log: log}
+}
+
+// Claim locks the key of the request for lockFor, keys whose lock or response expired are claimed again;
+// returns nil if the request got the key, else the stored request of the key with its response
+func (s *Storage) Claim(ctx context.Context, request *models.IdempotentRequest, lockFor time.Duration) (*models.IdempotentRequest, error) {
+	op := "storage.postgres.Claim"
+	logger := s.Log.With("op", op)
+
+	var claimed bool
+
+	err := s.Db.QueryRowContext(ctx, `
+        INSERT INTO "idempotencyKeys" (scope, key, method, requestHash, expires_at)
+        VALUES ($1, $2, $3, $4, now() + $5 * interval '1 microsecond')
+        ON CONFLICT (scope, key, method) DO UPDATE
+        SET requestHash = EXCLUDED.requestHash, response = NULL, created_at = now(), expires_at = EXCLUDED.expires_at
+        WHERE "idempotencyKeys".expires_at < now()
+        RETURNING true`,
+		request.Scope, request.Key, request.Method, request.RequestHash, lockFor.Microseconds(),
+	).Scan(&claimed)
+	if err == nil {
+		return nil, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		logger.Debug("Error on claiming idempotency key", "err", err)
+		return nil, err
+	}
+
+	// the key is held by another request or has its response
+	stored := models.IdempotentRequest{Scope: request.Scope, Key: request.Key, Method: request.Method}
+
+	err = s.Db.QueryRowContext(ctx, `
+        SELECT requestHash, response
+        FROM "idempotencyKeys"
+        WHERE scope = $1 AND key = $2 AND method = $3`,
+		request.Scope, request.Key, request.Method,
+	).Scan(&stored.RequestHash, &stored.Response)
+	if errors.Is(err, sql.ErrNoRows) {
+		// the key was released in the meantime
+		return s.Claim(ctx, request, lockFor)
+	}
+	if err != nil {
+		logger.Debug("Error on reading idempotency key", "err", err)
+		return nil, err
+	}
+
+	return &stored, nil
+}
+
+// Complete stores the response of the claimed request, it is replayed for keepFor
+func (s *Storage) Complete(ctx context.Context, request *models.IdempotentRequest, response []byte, keepFor time.Duration) error {
+	_, err := s.Db.ExecContext(ctx, `
+        UPDATE "idempotencyKeys"
+        SET response = $1, expires_at = now() + $2 * interval '1 microsecond'
+        WHERE scope = $3 AND key = $4 AND method = $5 AND requestHash = $6`,
+		response, keepFor.Microseconds(), request.Scope, request.Key, request.Method, request.RequestHash,
+	)
+
+	return err
+}
+
+// Release deletes the claim of a request that failed, so a retry runs it again
+func (s *Storage) Release(ctx context.Context, request *models.IdempotentRequest) error {
+	_, err := s.Db.ExecContext(ctx, `
+        DELETE FROM "idempotencyKeys"
+        WHERE scope = $1 AND key = $2 AND method = $3 AND requestHash = $4 AND response IS NULL`,
+		request.Scope, request.Key, request.Method, request.RequestHash,
+	)
+
+	return err
+}
+
+// DeleteExpired deletes the keys whose lock or response expired and returns how many were deleted
+func (s *Storage) DeleteExpired(ctx context.Context) (int64, error) {
+	result, err := s.Db.ExecContext(ctx, `DELETE FROM "idempotencyKeys" WHERE expires_at < now()`)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
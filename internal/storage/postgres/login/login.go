@@ -0,0 +1,96 @@
+package login
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"sso_go_grpc/internal/domain/models"
+)
+
+type StorageInterface interface {
+	RecordLogin(ctx context.Context, attempt *models.LoginAttempt) error
+	ListLoginHistory(ctx context.Context, userId, beforeId uint64, limit int) ([]*models.LoginAttempt, error)
+}
+
+type Storage struct {
+	StorageInterface
+	Db  *sql.DB
+	Log *slog.Logger
+}
+
+func CreateStorage(db *sql.DB, log *slog.Logger) *Storage {
+	return &Storage{Db: db, Log: log}
+}
+
+// RecordLogin saves the login attempt, successful logins also update the last login of the user
+func (s *Storage) RecordLogin(ctx context.Context, attempt *models.LoginAttempt) error {
+	op := "storage.postgres.RecordLogin"
+	logger := s.Log.With("op", op)
+
+	tx, err := s.Db.BeginTx(ctx, nil)
+
+	// if there was an error on creating transaction
+	if err != nil {
+		return err
+	}
+
+	// unknown users are saved without userId
+	userId := sql.NullInt64{Int64: int64(attempt.UserId), Valid: attempt.UserId != 0}
+
+	_, err = tx.ExecContext(ctx, `
+        INSERT INTO "loginHistory" (userId, email, success, ip, userAgent)
+        VALUES ($1, $2, $3, $4, $5)`,
+		userId, attempt.Email, attempt.Success, attempt.IP, attempt.UserAgent,
+	)
+	if err != nil {
+		logger.Debug("Error on saving login attempt", "err", err)
+		tx.Rollback()
+		return err
+	}
+
+	if attempt.Success {
+		if _, err = tx.ExecContext(ctx, `UPDATE users SET last_login_at = now() WHERE id = $1`, attempt.UserId); err != nil {
+			logger.Debug("Error on updating last login", "err", err)
+			tx.Rollback()
+			return err
+		}
+	}
+
+	//commit the changes to the database
+	return tx.Commit()
+}
+
+// ListLoginHistory returns the newest login attempts of the user,
+// beforeId is the cursor, 0 starts with the newest attempt, limit 0 returns all attempts
+func (s *Storage) ListLoginHistory(ctx context.Context, userId, beforeId uint64, limit int) ([]*models.LoginAttempt, error) {
+	rows, err := s.Db.QueryContext(ctx, `
+        SELECT id, email, success, ip, userAgent, created_at
+        FROM "loginHistory"
+        WHERE userId = $1 AND ($2::bigint = 0 OR id < $2::bigint)
+        ORDER BY id DESC
+        LIMIT NULLIF($3, 0)`, userId, beforeId, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	attempts := make([]*models.LoginAttempt, 0)
+	for rows.Next() {
+		var (
+			attempt              = models.LoginAttempt{UserId: userId}
+			email, ip, userAgent sql.NullString
+		)
+
+		if err := rows.Scan(&attempt.Id, &email, &attempt.Success, &ip, &userAgent, &attempt.CreatedAt); err != nil {
+			return nil, err
+		}
+
+		attempt.Email = email.String
+		attempt.IP = ip.String
+		attempt.UserAgent = userAgent.String
+		attempts = append(attempts, &attempt)
+	}
+
+	return attempts, rows.Err()
+}
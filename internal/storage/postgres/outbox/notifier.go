@@ -0,0 +1,127 @@
+package outbox
+
+import (
+	"github.com/lib/pq"
+	"log/slog"
+	"sso_go_grpc/internal/storage"
+	"sync"
+	"time"
+)
+
+// notifyChannel is the channel the outbox trigger notifies on every new event
+const notifyChannel = "outbox_events"
+
+// Notifier listens for the notifications of new outbox events and wakes its subscribers,
+// so every replica learns about the events written by the others;
+// the listening connection is only opened by the first subscriber
+type Notifier struct {
+	dbLink string
+	log    *slog.Logger
+
+	mu          sync.Mutex
+	listener    *pq.Listener
+	subscribers map[chan struct{}]struct{}
+	closed      bool
+}
+
+func NewNotifier(dbLink string, log *slog.Logger) *Notifier {
+	return &Notifier{dbLink: dbLink, log: log, subscribers: make(map[chan struct{}]struct{})}
+}
+
+// Subscribe returns a channel receiving a value whenever there may be new events,
+// wakeups are coalesced, so the subscriber has to read all events after its last one;
+// the returned function ends the subscription; the channel is closed when the notifier is closed
+func (n *Notifier) Subscribe() (<-chan struct{}, func(), error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.closed {
+		return nil, nil, storage.ErrShuttingDown
+	}
+
+	if n.listener == nil {
+		if err := n.listen(); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	wake := make(chan struct{}, 1)
+	n.subscribers[wake] = struct{}{}
+
+	return wake, func() {
+		n.mu.Lock()
+		delete(n.subscribers, wake)
+		n.mu.Unlock()
+	}, nil
+}
+
+// Close stops listening and closes the channels of all subscribers, so their watches end
+func (n *Notifier) Close() error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.closed {
+		return nil
+	}
+	n.closed = true
+
+	for wake := range n.subscribers {
+		close(wake)
+		delete(n.subscribers, wake)
+	}
+
+	if n.listener == nil {
+		return nil
+	}
+
+	return n.listener.Close()
+}
+
+// listen opens the listening connection, n.mu has to be held
+func (n *Notifier) listen() error {
+	logger := n.log.With("op", "storage.postgres.Notifier")
+
+	listener := pq.NewListener(n.dbLink, time.Second, time.Minute, func(event pq.ListenerEventType, err error) {
+		if err != nil {
+			logger.Error("Error on listening for outbox events", "err", err)
+		}
+	})
+
+	if err := listener.Listen(notifyChannel); err != nil {
+		listener.Close()
+		return err
+	}
+
+	n.listener = listener
+	go n.run(listener)
+
+	return nil
+}
+
+func (n *Notifier) run(listener *pq.Listener) {
+	for {
+		select {
+		// a nil notification is sent after a reconnect, notifications may have been lost, so it wakes too
+		case _, ok := <-listener.Notify:
+			if !ok {
+				return
+			}
+			n.wake()
+		// the ping detects a dead connection that would otherwise silently stop the notifications
+		case <-time.After(90 * time.Second):
+			go listener.Ping()
+		}
+	}
+}
+
+func (n *Notifier) wake() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	for wake := range n.subscribers {
+		select {
+		case wake <- struct{}{}:
+		default:
+		}
+	}
+}
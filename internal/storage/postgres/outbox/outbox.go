@@ -0,0 +1,403 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/lib/pq"
+	"log/slog"
+	"sso_go_grpc/internal/domain/models"
+	"sso_go_grpc/internal/storage"
+	"strings"
+	"time"
+)
+
+type StorageInterface interface {
+	Enqueue(ctx context.Context, tx *sql.Tx, eventType string, data any) error
+	ListEvents(ctx context.Context, afterPosition uint64, eventTypes []string, limit int) ([]*models.Event, error)
+	LatestPosition(ctx context.Context) (uint64, error)
+
+	CreateSubscription(ctx context.Context, subscription *models.WebhookSubscription) (*models.WebhookSubscription, error)
+	UpdateSubscription(ctx context.Context, subscription *models.WebhookSubscription) (*models.WebhookSubscription, error)
+	DeleteSubscription(ctx context.Context, subscriptionId uint64) error
+	GetSubscription(ctx context.Context, subscriptionId uint64) (*models.WebhookSubscription, error)
+	ListSubscriptions(ctx context.Context) ([]*models.WebhookSubscription, error)
+
+	FanOut(ctx context.Context, limit int) (int64, error)
+	ClaimDeliveries(ctx context.Context, limit int, lease time.Duration) ([]*models.WebhookDelivery, error)
+	MarkDelivered(ctx context.Context, deliveryId uint64, responseStatus int) error
+	MarkFailed(ctx context.Context, deliveryId uint64, responseStatus int, lastError string, nextAttemptAt time.Time, dead bool) error
+	ListDeliveries(ctx context.Context, filter models.DeliveryFilter) ([]*models.WebhookDelivery, error)
+	Redeliver(ctx context.Context, deliveryId uint64) error
+}
+
+type Storage struct {
+	StorageInterface
+	Db  *sql.DB
+	Log *slog.Logger
+	// Notifier wakes the streams when events are written, also by other replicas
+	Notifier *Notifier
+}
+
+func CreateStorage(db *sql.DB, log *slog.Logger, notifier *Notifier) *Storage {
+	return &Storage{Db: db, Log: log, Notifier: notifier}
+}
+
+// Enqueue writes the event into the outbox in the transaction of the change it describes,
+// so the event is only published if the change is committed; its position is assigned on commit
+func (s *Storage) Enqueue(ctx context.Context, tx *sql.Tx, eventType string, data any) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `INSERT INTO "outbox" (type, payload) VALUES ($1, $2)`, eventType, payload)
+
+	return err
+}
+
+// ListEvents returns the oldest events after the position, only of the event types if any are given;
+// the positions are in commit order, so no event is committed later with a lower position
+func (s *Storage) ListEvents(ctx context.Context, afterPosition uint64, eventTypes []string, limit int) ([]*models.Event, error) {
+	rows, err := s.Db.QueryContext(ctx, `
+        SELECT id, position, type, payload, created_at FROM "outbox"
+        WHERE position > $1 AND (cardinality($2::text[]) = 0 OR type = ANY($2))
+        ORDER BY position
+        LIMIT $3`, afterPosition, pq.Array(eventTypes), limit)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	events := make([]*models.Event, 0)
+	for rows.Next() {
+		var (
+			event   models.Event
+			payload []byte
+		)
+
+		if err := rows.Scan(&event.Id, &event.Position, &event.Type, &payload, &event.CreatedAt); err != nil {
+			return nil, err
+		}
+
+		event.Data = json.RawMessage(payload)
+		events = append(events, &event)
+	}
+
+	return events, rows.Err()
+}
+
+// LatestPosition returns the position of the newest committed event, 0 if there are no events
+func (s *Storage) LatestPosition(ctx context.Context) (uint64, error) {
+	var position uint64
+
+	err := s.Db.QueryRowContext(ctx, `SELECT COALESCE(max(position), 0) FROM "outbox"`).Scan(&position)
+
+	return position, err
+}
+
+// CreateSubscription saves the subscription and returns it with id and creation time
+func (s *Storage) CreateSubscription(ctx context.Context, subscription *models.WebhookSubscription) (*models.WebhookSubscription, error) {
+	op := "storage.postgres.CreateSubscription"
+	logger := s.Log.With("op", op)
+
+	created := *subscription
+
+	err := s.Db.QueryRowContext(ctx, `
+        INSERT INTO "webhookSubscriptions" (url, secret, eventTypes, active)
+        VALUES ($1, $2, $3, $4) RETURNING id, created_at`,
+		subscription.Url, subscription.Secret, pq.Array(subscription.EventTypes), subscription.Active,
+	).Scan(&created.Id, &created.CreatedAt)
+
+	if err != nil {
+		logger.Debug("Error on executing query", "err", err)
+		return nil, err
+	}
+
+	return &created, nil
+}
+
+// UpdateSubscription updates url, event types and active state, the secret is never changed
+func (s *Storage) UpdateSubscription(ctx context.Context, subscription *models.WebhookSubscription) (*models.WebhookSubscription, error) {
+	result, err := s.Db.ExecContext(ctx, `
+        UPDATE "webhookSubscriptions"
+        SET url = $1, eventTypes = $2, active = $3
+        WHERE id = $4`,
+		subscription.Url, pq.Array(subscription.EventTypes), subscription.Active, subscription.Id,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = expectOneRow(result); err != nil {
+		return nil, err
+	}
+
+	return s.GetSubscription(ctx, subscription.Id)
+}
+
+// DeleteSubscription deletes the subscription and all of its deliveries
+func (s *Storage) DeleteSubscription(ctx context.Context, subscriptionId uint64) error {
+	result, err := s.Db.ExecContext(ctx, `DELETE FROM "webhookSubscriptions" WHERE id = $1`, subscriptionId)
+	if err != nil {
+		return err
+	}
+
+	return expectOneRow(result)
+}
+
+// GetSubscription returns the subscription without its secret
+func (s *Storage) GetSubscription(ctx context.Context, subscriptionId uint64) (*models.WebhookSubscription, error) {
+	var subscription models.WebhookSubscription
+
+	err := s.Db.QueryRowContext(ctx, `
+        SELECT id, url, eventTypes, active, created_at
+        FROM "webhookSubscriptions"
+        WHERE id = $1`, subscriptionId,
+	).Scan(&subscription.Id, &subscription.Url, pq.Array(&subscription.EventTypes), &subscription.Active, &subscription.CreatedAt)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, storage.ErrSubscriptionNotExists
+		}
+		return nil, err
+	}
+
+	return &subscription, nil
+}
+
+// ListSubscriptions returns all subscriptions without their secrets ordered by id
+func (s *Storage) ListSubscriptions(ctx context.Context) ([]*models.WebhookSubscription, error) {
+	rows, err := s.Db.QueryContext(ctx, `
+        SELECT id, url, eventTypes, active, created_at
+        FROM "webhookSubscriptions"
+        ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	subscriptions := make([]*models.WebhookSubscription, 0)
+	for rows.Next() {
+		var subscription models.WebhookSubscription
+
+		if err := rows.Scan(&subscription.Id, &subscription.Url, pq.Array(&subscription.EventTypes), &subscription.Active, &subscription.CreatedAt); err != nil {
+			return nil, err
+		}
+
+		subscriptions = append(subscriptions, &subscription)
+	}
+
+	return subscriptions, rows.Err()
+}
+
+// FanOut creates a pending delivery of the oldest undispatched events for every active subscription
+// of their type and marks the events as dispatched, returns the amount of dispatched events;
+// SKIP LOCKED lets several dispatchers run next to each other
+func (s *Storage) FanOut(ctx context.Context, limit int) (int64, error) {
+	result, err := s.Db.ExecContext(ctx, `
+        WITH events AS (
+            SELECT id, type FROM "outbox"
+            WHERE dispatched_at IS NULL
+            ORDER BY id
+            LIMIT $1
+            FOR UPDATE SKIP LOCKED
+        ), deliveries AS (
+            INSERT INTO "webhookDeliveries" (eventId, subscriptionId)
+            SELECT e.id, s.id FROM events e
+            JOIN "webhookSubscriptions" s ON s.active AND (cardinality(s.eventTypes) = 0 OR e.type = ANY(s.eventTypes))
+        )
+        UPDATE "outbox" o SET dispatched_at = now()
+        FROM events e
+        WHERE o.id = e.id`, limit)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+// ClaimDeliveries returns the due pending deliveries with their event and subscription;
+// the next attempt is moved by the lease, so other dispatchers do not claim them while they are sent
+func (s *Storage) ClaimDeliveries(ctx context.Context, limit int, lease time.Duration) ([]*models.WebhookDelivery, error) {
+	rows, err := s.Db.QueryContext(ctx, `
+        WITH claimed AS (
+            UPDATE "webhookDeliveries" d
+            SET next_attempt_at = now() + $2 * interval '1 millisecond'
+            WHERE d.id IN (
+                SELECT id FROM "webhookDeliveries"
+                WHERE status = 'pending' AND next_attempt_at <= now()
+                ORDER BY next_attempt_at
+                LIMIT $1
+                FOR UPDATE SKIP LOCKED
+            )
+            RETURNING d.id, d.subscriptionId, d.eventId, d.attempts, d.created_at
+        )
+        SELECT c.id, c.subscriptionId, c.attempts, c.created_at, e.id, e.type, e.payload, e.created_at, s.url, s.secret
+        FROM claimed c
+        JOIN "outbox" e ON e.id = c.eventId
+        JOIN "webhookSubscriptions" s ON s.id = c.subscriptionId`, limit, lease.Milliseconds())
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	deliveries := make([]*models.WebhookDelivery, 0)
+	for rows.Next() {
+		var (
+			delivery = models.WebhookDelivery{Status: models.DeliveryStatusPending, Event: &models.Event{}}
+			payload  []byte
+		)
+
+		err := rows.Scan(
+			&delivery.Id, &delivery.SubscriptionId, &delivery.Attempts, &delivery.CreatedAt,
+			&delivery.Event.Id, &delivery.Event.Type, &payload, &delivery.Event.CreatedAt,
+			&delivery.Url, &delivery.Secret,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		// the payload is sent as it is stored
+		delivery.Event.Data = json.RawMessage(payload)
+		deliveries = append(deliveries, &delivery)
+	}
+
+	return deliveries, rows.Err()
+}
+
+// MarkDelivered counts the successful attempt and finishes the delivery
+func (s *Storage) MarkDelivered(ctx context.Context, deliveryId uint64, responseStatus int) error {
+	_, err := s.Db.ExecContext(ctx, `
+        UPDATE "webhookDeliveries"
+        SET status = 'delivered', attempts = attempts + 1, responseStatus = $1, lastError = NULL, delivered_at = now()
+        WHERE id = $2`, responseStatus, deliveryId)
+
+	return err
+}
+
+// MarkFailed counts the failed attempt and schedules the next one, dead deliveries are not retried
+func (s *Storage) MarkFailed(ctx context.Context, deliveryId uint64, responseStatus int, lastError string, nextAttemptAt time.Time, dead bool) error {
+	status := models.DeliveryStatusPending
+	if dead {
+		status = models.DeliveryStatusDead
+	}
+
+	// 0 means there was no response
+	response := sql.NullInt64{Int64: int64(responseStatus), Valid: responseStatus != 0}
+
+	_, err := s.Db.ExecContext(ctx, `
+        UPDATE "webhookDeliveries"
+        SET status = $1, attempts = attempts + 1, responseStatus = $2, lastError = $3, next_attempt_at = $4
+        WHERE id = $5`, status, response, lastError, nextAttemptAt, deliveryId)
+
+	return err
+}
+
+// ListDeliveries returns the newest deliveries matching the filter with their events
+func (s *Storage) ListDeliveries(ctx context.Context, filter models.DeliveryFilter) ([]*models.WebhookDelivery, error) {
+	// every filter adds a condition and its argument
+	conditions := []string{"TRUE"}
+	var args []any
+
+	addCondition := func(condition string, arg any) {
+		args = append(args, arg)
+		conditions = append(conditions, strings.ReplaceAll(condition, "$?", fmt.Sprintf("$%d", len(args))))
+	}
+
+	if filter.SubscriptionId != 0 {
+		addCondition("d.subscriptionId = $?", filter.SubscriptionId)
+	}
+	if filter.Status != "" {
+		addCondition("d.status = $?", filter.Status)
+	}
+	if filter.BeforeId != 0 {
+		addCondition("d.id < $?", filter.BeforeId)
+	}
+
+	args = append(args, filter.Limit)
+	query := fmt.Sprintf(`
+        SELECT d.id, d.subscriptionId, d.status, d.attempts, d.next_attempt_at, d.lastError, d.responseStatus,
+               d.delivered_at, d.created_at, e.id, e.type, e.payload, e.created_at
+        FROM "webhookDeliveries" d
+        JOIN "outbox" e ON e.id = d.eventId
+        WHERE %s
+        ORDER BY d.id DESC
+        LIMIT $%d`, strings.Join(conditions, " AND "), len(args))
+
+	rows, err := s.Db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	deliveries := make([]*models.WebhookDelivery, 0)
+	for rows.Next() {
+		var (
+			delivery       = models.WebhookDelivery{Event: &models.Event{}}
+			lastError      sql.NullString
+			responseStatus sql.NullInt64
+			deliveredAt    sql.NullTime
+			payload        []byte
+		)
+
+		err := rows.Scan(
+			&delivery.Id, &delivery.SubscriptionId, &delivery.Status, &delivery.Attempts, &delivery.NextAttemptAt,
+			&lastError, &responseStatus, &deliveredAt, &delivery.CreatedAt,
+			&delivery.Event.Id, &delivery.Event.Type, &payload, &delivery.Event.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		delivery.Event.Data = json.RawMessage(payload)
+		delivery.LastError = lastError.String
+		delivery.ResponseStatus = int(responseStatus.Int64)
+		delivery.DeliveredAt = deliveredAt.Time
+		deliveries = append(deliveries, &delivery)
+	}
+
+	return deliveries, rows.Err()
+}
+
+// Redeliver puts a dead or delivered delivery back into the queue with fresh attempts
+func (s *Storage) Redeliver(ctx context.Context, deliveryId uint64) error {
+	result, err := s.Db.ExecContext(ctx, `
+        UPDATE "webhookDeliveries"
+        SET status = 'pending', attempts = 0, next_attempt_at = now()
+        WHERE id = $1`, deliveryId)
+	if err != nil {
+		return err
+	}
+
+	affectedRows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affectedRows == 0 {
+		return storage.ErrDeliveryNotExists
+	}
+
+	return nil
+}
+
+// expectOneRow returns storage.ErrSubscriptionNotExists if no row was affected
+func expectOneRow(result sql.Result) error {
+	affectedRows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affectedRows == 0 {
+		return storage.ErrSubscriptionNotExists
+	}
+
+	return nil
+}
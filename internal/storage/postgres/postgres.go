@@ -6,6 +6,13 @@ import (
 	_ "github.com/golang-migrate/migrate/v4/database/postgres"
 	"log/slog"
 	"sso_go_grpc/internal/config"
+	"sso_go_grpc/internal/lib/metrics"
+	"sso_go_grpc/internal/lib/tracing"
+	"sso_go_grpc/internal/storage/postgres/attribute"
+	"sso_go_grpc/internal/storage/postgres/audit"
+	"sso_go_grpc/internal/storage/postgres/idempotency"
+	"sso_go_grpc/internal/storage/postgres/login"
+	"sso_go_grpc/internal/storage/postgres/outbox"
 	"sso_go_grpc/internal/storage/postgres/role"
 	"sso_go_grpc/internal/storage/postgres/user"
 	_ "strconv"
@@ -16,25 +23,63 @@ type Storage struct {
 	Config *config.Config
 	Log    *slog.Logger
 
-	User *user.Storage
-	Role *role.Storage
+	User      *user.Storage
+	Role      *role.Storage
+	Attribute *attribute.Storage
+	Login     *login.Storage
+	Audit     *audit.Storage
+	Outbox    *outbox.Storage
+	// Idempotency stores the responses of the requests with an idempotency key
+	Idempotency *idempotency.Storage
+
+	// unregisterMetrics stops the export of the pool statistics on Close
+	unregisterMetrics func()
 }
 
 // MustLoad this function returns a Storage, if there is an error , it panics
 func MustLoad(cfg *config.Config, log *slog.Logger) *Storage {
 	op := "storage.postgres.MustLoad"
-	db, err := sql.Open(cfg.DbType, cfg.DbLink)
+	// every query gets a span in the trace of its request
+	db, err := tracing.OpenDB(cfg.DbType, cfg.DbLink)
 
 	if err != nil {
-		panic(fmt.Sprintf("%s: %w", op, err))
+		panic(fmt.Errorf("%s: %w", op, err))
 	}
 
 	fmt.Printf("Database was succesfully connected\n")
 
+	// the pool statistics are exported with the other metrics until the storage is closed
+	unregisterMetrics := metrics.RegisterDB(db, cfg.DbType)
+
+	// the role changes, also the removals of the roles of deleted users, are audited in their transactions,
+	// user and role changes write their events into the outbox in their transactions
+	auditStorage := audit.CreateStorage(db, log, []byte(cfg.Audit.HmacKey))
+	outboxStorage := outbox.CreateStorage(db, log, outbox.NewNotifier(cfg.DbLink, log))
+
 	return &Storage{
-		Db:   db,
-		Log:  log,
-		User: user.CreateStorage(db, log),
-		Role: role.CreateStorage(db, log),
+		Db:        db,
+		Log:       log,
+		User:      user.CreateStorage(db, log, outboxStorage, auditStorage),
+		Role:      role.CreateStorage(db, log, auditStorage, outboxStorage),
+		Attribute: attribute.CreateStorage(db, log),
+		Login:     login.CreateStorage(db, log),
+		Audit:     auditStorage,
+		Outbox:    outboxStorage,
+
+		Idempotency: idempotency.CreateStorage(db, log),
+
+		unregisterMetrics: unregisterMetrics,
 	}
 }
+
+// Close closes the listening connection of the outbox and the connection pool and stops exporting its statistics,
+// it has to be called after the servers stopped using the storage
+func (s *Storage) Close() error {
+	s.unregisterMetrics()
+
+	if err := s.Outbox.Notifier.Close(); err != nil {
+		s.Log.With("op", "storage.postgres.Close").Error("Error on closing the outbox notifier", "err", err)
+	}
+
+	return s.Db.Close()
+}
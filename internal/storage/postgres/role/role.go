@@ -4,29 +4,48 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"github.com/lib/pq"
 	"log/slog"
 	"sso_go_grpc/internal/domain/models"
 	"sso_go_grpc/internal/storage"
+	"sso_go_grpc/internal/storage/postgres/audit"
+	"sso_go_grpc/internal/storage/postgres/outbox"
 )
 
+// the methods changing roles take an audit entry, it is completed with the target and
+// its before/after state and inserted in the same transaction; nil skips the audit.
+// changes of role assignments also write their events into the outbox in that transaction
 type StorageInterface interface {
-	CreateRole(ctx context.Context, name, description string) (*models.Role, error)
-	UpdateRole(ctx context.Context, name, description string, roleId uint64) (*models.Role, error)
-	DeleteRole(ctx context.Context, roleId uint64) error
+	CreateRole(ctx context.Context, name, description string, entry *models.AuditEntry) (*models.Role, error)
+	UpdateRole(ctx context.Context, roleId uint64, update models.RoleUpdate, entry *models.AuditEntry) (*models.Role, error)
+	DeleteRole(ctx context.Context, roleId, expectedVersion uint64, entry *models.AuditEntry) error
+	AddUserRole(ctx context.Context, roleId, userId uint64, entry *models.AuditEntry) error
+	RemoveUserRole(ctx context.Context, roleId, userId uint64, entry *models.AuditEntry) error
+	ListRoles(ctx context.Context) ([]*models.Role, error)
+	GetRoleMembers(ctx context.Context, roleId uint64) ([]*models.User, error)
+	SetRoleMembers(ctx context.Context, roleId uint64, userIds []uint64, entry *models.AuditEntry) error
+	CreateRoleWithMembers(ctx context.Context, name, description string, userIds []uint64, entry, membersEntry *models.AuditEntry) (*models.Role, error)
+}
+
+// queryer is implemented by *sql.DB and *sql.Tx
+type queryer interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
 }
 
 type Storage struct {
 	StorageInterface
-	Db  *sql.DB
-	Log *slog.Logger
+	Db     *sql.DB
+	Log    *slog.Logger
+	Audit  *audit.Storage
+	Outbox *outbox.Storage
 }
 
-func CreateStorage(db *sql.DB, log *slog.Logger) *Storage {
-	return &Storage{Db: db, Log: log}
+func CreateStorage(db *sql.DB, log *slog.Logger, audit *audit.Storage, outbox *outbox.Storage) *Storage {
+	return &Storage{Db: db, Log: log, Audit: audit, Outbox: outbox}
 }
 
 // CreateRole this creates a new Role in the database
-func (s *Storage) CreateRole(ctx context.Context, name, description string) (*models.Role, error) {
+func (s *Storage) CreateRole(ctx context.Context, name, description string, entry *models.AuditEntry) (*models.Role, error) {
 	op := "storage.postgres.CreateRole"
 
 	_, err := s.GetRoleByName(ctx, name)
@@ -38,44 +57,131 @@ func (s *Storage) CreateRole(ctx context.Context, name, description string) (*mo
 	//setting up logger
 	logger := s.Log.With("op", op)
 
-	//the new role ID
-	var roleId sql.NullInt64
+	tx, err := s.Db.BeginTx(ctx, nil)
 
-	//prepare sql call to create new role
-	prepared, err := s.Db.Prepare(`INSERT INTO roles(name, description)  VALUES ($1, $2) RETURNING id`)
+	// if there was an error on creating transaction
+	if err != nil {
+		return nil, err
+	}
 
-	//if there was an error in preparing sql
+	role, err := s.insertRole(ctx, tx, name, description, entry)
 	if err != nil {
-		logger.Debug("Error in preparing sql", err)
+		logger.Debug("Error on creating role", "err", err)
+		tx.Rollback()
 		return nil, err
 	}
 
-	//call the prepared request
-	//if there ws an error return it
-	if err = prepared.QueryRowContext(ctx, name, description).Scan(&roleId); err != nil {
+	//commit the changes to the database
+	if err = tx.Commit(); err != nil {
 		return nil, err
 	}
 
-	//get the new role by its ID
-	role, err := s.GetRoleById(ctx, uint64(roleId.Int64))
+	//return new role
+	return role, nil
+}
 
-	//if there ws an error return it
+// CreateRoleWithMembers creates the role and assigns it to the users in one transaction,
+// the creation is recorded with the entry and the members with the membersEntry
+func (s *Storage) CreateRoleWithMembers(
+	ctx context.Context,
+	name,
+	description string,
+	userIds []uint64,
+	entry,
+	membersEntry *models.AuditEntry,
+) (*models.Role, error) {
+	op := "storage.postgres.CreateRoleWithMembers"
+	logger := s.Log.With("op", op)
+
+	if _, err := s.GetRoleByName(ctx, name); err == nil {
+		return nil, storage.ErrRoleExists
+	}
+
+	tx, err := s.Db.BeginTx(ctx, nil)
+
+	// if there was an error on creating transaction
 	if err != nil {
 		return nil, err
 	}
 
-	//return new role
+	role, err := s.insertRole(ctx, tx, name, description, entry)
+	if err != nil {
+		logger.Debug("Error on creating role", "err", err)
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err = s.setRoleMembers(ctx, tx, role, userIds, membersEntry); err != nil {
+		logger.Debug("Error on setting role members", "err", err)
+		tx.Rollback()
+		return nil, err
+	}
+
+	//commit the changes to the database
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return role, nil
+}
+
+// insertRole inserts the role in the transaction and records the entry
+func (s *Storage) insertRole(ctx context.Context, tx *sql.Tx, name, description string, entry *models.AuditEntry) (*models.Role, error) {
+	//the new role ID
+	var roleId sql.NullInt64
+	var version uint64
+
+	err := tx.QueryRowContext(ctx, `INSERT INTO roles(name, description)  VALUES ($1, $2) RETURNING id, version`, name, description).Scan(&roleId, &version)
+	if err != nil {
+		// a role with the name was created after the check
+		if isUniqueViolation(err) {
+			return nil, storage.ErrRoleExists
+		}
+		return nil, err
+	}
+
+	role := &models.Role{Id: uint64(roleId.Int64), Name: name, Description: description, Version: version}
+
+	if err = s.recordAudit(ctx, tx, entry, models.AuditTargetRole, role.Id, nil, role); err != nil {
+		return nil, err
+	}
+
 	return role, nil
 }
 
 // GetRoleById is getting a role by id and returns &models.Role
 func (s *Storage) GetRoleById(ctx context.Context, id uint64) (*models.Role, error) {
+	return getRole(ctx, s.Db, id)
+}
+
+// getRole is getting a role by id in the database or in a transaction
+func getRole(ctx context.Context, q queryer, id uint64) (*models.Role, error) {
+	return scanRole(q.QueryRowContext(ctx, "SELECT name, description, version FROM roles r WHERE r.id = $1", id), id)
+}
+
+// lockRole gets the role and locks it until the end of the transaction,
+// returns storage.ErrVersionMismatch if expectedVersion is not 0 and not the version of the role
+func lockRole(ctx context.Context, tx *sql.Tx, id, expectedVersion uint64) (*models.Role, error) {
+	role, err := scanRole(tx.QueryRowContext(ctx, "SELECT name, description, version FROM roles r WHERE r.id = $1 FOR UPDATE", id), id)
+	if err != nil {
+		return nil, err
+	}
+
+	if expectedVersion != 0 && role.Version != expectedVersion {
+		return nil, storage.ErrVersionMismatch
+	}
+
+	return role, nil
+}
+
+func scanRole(row *sql.Row, id uint64) (*models.Role, error) {
 	//role params
-	var description, name *sql.NullString
+	var (
+		description, name *sql.NullString
+		version           uint64
+	)
 
-	//check if there is
-	//sql call to get the information
-	err := s.Db.QueryRowContext(ctx, "SELECT name, description FROM roles r WHERE r.id = $1", id).Scan(&name, &description)
+	err := row.Scan(&name, &description, &version)
 
 	//handle error
 	if err != nil {
@@ -90,7 +196,7 @@ func (s *Storage) GetRoleById(ctx context.Context, id uint64) (*models.Role, err
 	}
 
 	//return the Role model
-	return &models.Role{Id: id, Description: description.String, Name: name.String}, nil
+	return &models.Role{Id: id, Description: description.String, Name: name.String, Version: version}, nil
 }
 
 // GetRoleByName is getting a role by name and returns &models.Role
@@ -100,10 +206,11 @@ func (s *Storage) GetRoleByName(ctx context.Context, name string) (*models.Role,
 	var (
 		description *sql.NullString
 		id          *sql.NullInt64
+		version     uint64
 	)
 
 	//sql call to get the information
-	err := s.Db.QueryRowContext(ctx, "SELECT id, description FROM roles r WHERE r.name = $1", name).Scan(&id, &description)
+	err := s.Db.QueryRowContext(ctx, "SELECT id, description, version FROM roles r WHERE r.name = $1", name).Scan(&id, &description, &version)
 
 	//handle error
 	if err != nil {
@@ -115,10 +222,12 @@ func (s *Storage) GetRoleByName(ctx context.Context, name string) (*models.Role,
 	}
 
 	//return the Role model
-	return &models.Role{Id: uint64(id.Int64), Description: description.String, Name: name}, nil
+	return &models.Role{Id: uint64(id.Int64), Description: description.String, Name: name, Version: version}, nil
 }
 
-func (s *Storage) DeleteRole(ctx context.Context, roleId uint64) error {
+// DeleteRole deletes the role and removes it from all users,
+// returns storage.ErrVersionMismatch if expectedVersion is not 0 and not the version of the role
+func (s *Storage) DeleteRole(ctx context.Context, roleId, expectedVersion uint64, entry *models.AuditEntry) error {
 	tx, err := s.Db.BeginTx(ctx, nil)
 
 	// if there was an error on creating transaction
@@ -126,6 +235,20 @@ func (s *Storage) DeleteRole(ctx context.Context, roleId uint64) error {
 		return err
 	}
 
+	// the deleted role is the before state of the audit entry
+	role, err := lockRole(ctx, tx, roleId, expectedVersion)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	// the users losing the role are part of the event
+	members, err := memberIds(ctx, tx, roleId)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
 	// delete the role from all users
 	if _, err = tx.ExecContext(ctx, `DELETE FROM "userRoles" ur WHERE ur.roleId = $1`, roleId); err != nil {
 		tx.Rollback()
@@ -138,6 +261,17 @@ func (s *Storage) DeleteRole(ctx context.Context, roleId uint64) error {
 		return err
 	}
 
+	err = s.Outbox.Enqueue(ctx, tx, models.EventRoleDeleted, &models.RoleEventData{RoleId: roleId, RoleName: role.Name, UserIds: members})
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err = s.recordAudit(ctx, tx, entry, models.AuditTargetRole, roleId, role, nil); err != nil {
+		tx.Rollback()
+		return err
+	}
+
 	//commit the changes to the database
 	if err = tx.Commit(); err != nil {
 		return err
@@ -147,81 +281,158 @@ func (s *Storage) DeleteRole(ctx context.Context, roleId uint64) error {
 	return nil
 }
 
-func (s *Storage) UpdateRole(ctx context.Context, name, description string, roleId uint64) (*models.Role, error) {
+// UpdateRole updates the fields of the update that are not nil, the other fields keep their values
+func (s *Storage) UpdateRole(ctx context.Context, roleId uint64, update models.RoleUpdate, entry *models.AuditEntry) (*models.Role, error) {
 	op := "storage.postgres.UpdateRole"
 	logger := s.Log.With("op", op)
-	prepared, err := s.Db.Prepare(`UPDATE roles r SET name = $1, description = $2 WHERE r.id = $3`)
 
+	tx, err := s.Db.BeginTx(ctx, nil)
+
+	// if there was an error on creating transaction
 	if err != nil {
 		return nil, err
 	}
 
-	defer prepared.Close()
+	before, err := lockRole(ctx, tx, roleId, update.ExpectedVersion)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
 
-	_, err = prepared.ExecContext(ctx, name, description, roleId)
+	after := &models.Role{Id: roleId}
+
+	// null keeps the value of the column
+	err = tx.QueryRowContext(ctx, `
+		UPDATE roles r SET name = COALESCE($1, r.name), description = COALESCE($2, r.description), version = r.version + 1
+		WHERE r.id = $3
+		RETURNING r.name, COALESCE(r.description, ''), r.version
+	`, nullString(update.Name), nullString(update.Description), roleId).Scan(&after.Name, &after.Description, &after.Version)
 
 	if err != nil {
-		logger.Debug("Error  On executing query", err)
+		logger.Debug("Error  On executing query", "err", err)
+		tx.Rollback()
+		if isUniqueViolation(err) {
+			return nil, storage.ErrRoleExists
+		}
+		return nil, err
+	}
+
+	if err = s.recordAudit(ctx, tx, entry, models.AuditTargetRole, roleId, before, after); err != nil {
+		logger.Debug("Error on recording audit entry", "err", err)
+		tx.Rollback()
+		return nil, err
+	}
+
+	//commit the changes to the database
+	if err = tx.Commit(); err != nil {
 		return nil, err
 	}
 
-	return s.GetRoleById(ctx, roleId)
+	return after, nil
 }
 
 func (s *Storage) AddUserRole(
 	ctx context.Context,
 	roleId,
 	userId uint64,
+	entry *models.AuditEntry,
 ) error {
 	op := "storage.postgres.AddUserRole"
 	logger := s.Log.With("op", op)
 
-	prepared, err := s.Db.Prepare(`INSERT INTO "userRoles" (userId, roleId) VALUES($1,$2)`)
+	tx, err := s.Db.BeginTx(ctx, nil)
+
+	// if there was an error on creating transaction
+	if err != nil {
+		return err
+	}
 
+	role, err := getRole(ctx, tx, roleId)
 	if err != nil {
-		logger.Debug("Error on preparing the query")
+		tx.Rollback()
 		return err
 	}
 
-	_, err = prepared.ExecContext(ctx, userId, roleId)
+	_, err = tx.ExecContext(ctx, `INSERT INTO "userRoles" (userId, roleId) VALUES($1,$2)`, userId, roleId)
 
 	if err != nil {
 		logger.Debug("Error on executing query")
+		tx.Rollback()
 		return err
 	}
 
-	defer prepared.Close()
+	err = s.Outbox.Enqueue(ctx, tx, models.EventRoleAssigned, &models.RoleEventData{RoleId: roleId, RoleName: role.Name, UserId: userId})
+	if err != nil {
+		logger.Debug("Error on writing event", "err", err)
+		tx.Rollback()
+		return err
+	}
 
-	return nil
+	// the target is the user, the added role is its after state
+	if err = s.recordAudit(ctx, tx, entry, models.AuditTargetUser, userId, nil, role); err != nil {
+		logger.Debug("Error on recording audit entry", "err", err)
+		tx.Rollback()
+		return err
+	}
+
+	//commit the changes to the database
+	return tx.Commit()
 }
 
-func (s *Storage) RemoveUserRole(ctx context.Context, roleId, userId uint64) error {
+func (s *Storage) RemoveUserRole(ctx context.Context, roleId, userId uint64, entry *models.AuditEntry) error {
 	op := "storage.postgres.RemoveUserRole"
 	logger := s.Log.With("op", op)
 
-	prepared, err := s.Db.Prepare(`DELETE FROM "userRoles" ur WHERE ur.userId = $1 AND ur.roleId = $2 `)
+	tx, err := s.Db.BeginTx(ctx, nil)
+
+	// if there was an error on creating transaction
+	if err != nil {
+		return err
+	}
 
+	role, err := getRole(ctx, tx, roleId)
 	if err != nil {
-		logger.Debug("Error on preparing the query")
+		tx.Rollback()
 		return err
 	}
 
-	defer prepared.Close()
+	result, err := tx.ExecContext(ctx, `DELETE FROM "userRoles" ur WHERE ur.userId = $1 AND ur.roleId = $2 `, userId, roleId)
 
-	result, err := prepared.ExecContext(ctx, userId, roleId)
+	if err != nil {
+		logger.Debug("Error on executing query")
+		tx.Rollback()
+		return err
+	}
 
 	deletedRows, err := result.RowsAffected()
 
 	if err != nil {
 		logger.Debug("Error on Getting deletedRows count")
+		tx.Rollback()
 		return err
 	}
 
 	if deletedRows == 0 {
+		tx.Rollback()
 		return storage.ErrNoDelete
 	}
 
-	return nil
+	err = s.Outbox.Enqueue(ctx, tx, models.EventRoleRevoked, &models.RoleEventData{RoleId: roleId, RoleName: role.Name, UserId: userId})
+	if err != nil {
+		logger.Debug("Error on writing event", "err", err)
+		tx.Rollback()
+		return err
+	}
+
+	// the target is the user, the removed role is its before state
+	if err = s.recordAudit(ctx, tx, entry, models.AuditTargetUser, userId, role, nil); err != nil {
+		logger.Debug("Error on recording audit entry", "err", err)
+		tx.Rollback()
+		return err
+	}
+
+	//commit the changes to the database
+	return tx.Commit()
 }
 
 func (s *Storage) VerifyUserRole(ctx context.Context, roleId, userId uint64) (bool, error) {
@@ -242,3 +453,191 @@ func (s *Storage) VerifyUserRole(ctx context.Context, roleId, userId uint64) (bo
 
 	return true, nil
 }
+
+// ListRoles returns all roles ordered by id
+func (s *Storage) ListRoles(ctx context.Context) ([]*models.Role, error) {
+	rows, err := s.Db.QueryContext(ctx, `SELECT id, name, description, version FROM roles ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var roles []*models.Role
+	for rows.Next() {
+		var (
+			role        models.Role
+			description sql.NullString
+		)
+
+		if err := rows.Scan(&role.Id, &role.Name, &description, &role.Version); err != nil {
+			return nil, err
+		}
+
+		role.Description = description.String
+		roles = append(roles, &role)
+	}
+
+	return roles, rows.Err()
+}
+
+// GetRoleMembers returns id and username of all users with the role
+func (s *Storage) GetRoleMembers(ctx context.Context, roleId uint64) ([]*models.User, error) {
+	rows, err := s.Db.QueryContext(ctx, `
+        SELECT u.id, u.username
+        FROM "userRoles" ur
+        JOIN users u ON ur.userId = u.id
+        WHERE ur.roleId = $1
+        ORDER BY u.id`, roleId)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var members []*models.User
+	for rows.Next() {
+		var member models.User
+
+		if err := rows.Scan(&member.UserId, &member.Username); err != nil {
+			return nil, err
+		}
+
+		members = append(members, &member)
+	}
+
+	return members, rows.Err()
+}
+
+// SetRoleMembers replaces all users of the role with the given users
+func (s *Storage) SetRoleMembers(ctx context.Context, roleId uint64, userIds []uint64, entry *models.AuditEntry) error {
+	tx, err := s.Db.BeginTx(ctx, nil)
+
+	// if there was an error on creating transaction
+	if err != nil {
+		return err
+	}
+
+	role, err := getRole(ctx, tx, roleId)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err = s.setRoleMembers(ctx, tx, role, userIds, entry); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	//commit the changes to the database
+	return tx.Commit()
+}
+
+// setRoleMembers replaces the users of the role in the transaction with events for the changed users
+// and records the entry
+func (s *Storage) setRoleMembers(ctx context.Context, tx *sql.Tx, role *models.Role, userIds []uint64, entry *models.AuditEntry) error {
+	roleId := role.Id
+
+	before, err := memberIds(ctx, tx, roleId)
+	if err != nil {
+		return err
+	}
+
+	// remove the role from users who are not in the list anymore
+	if _, err = tx.ExecContext(ctx, `DELETE FROM "userRoles" ur WHERE ur.roleId = $1 AND NOT ur.userId = ANY($2::int[])`, roleId, pq.Array(userIds)); err != nil {
+		return err
+	}
+
+	// add the role to the new users
+	_, err = tx.ExecContext(ctx, `
+        INSERT INTO "userRoles" (userId, roleId)
+        SELECT u.id, $1::int FROM users u
+        WHERE u.id = ANY($2::int[])
+          AND NOT EXISTS (SELECT 1 FROM "userRoles" ur WHERE ur.roleId = $1::int AND ur.userId = u.id)`, roleId, pq.Array(userIds))
+	if err != nil {
+		return err
+	}
+
+	after, err := memberIds(ctx, tx, roleId)
+	if err != nil {
+		return err
+	}
+
+	// every user gaining or losing the role gets its own event
+	for _, userId := range difference(after, before) {
+		if err = s.Outbox.Enqueue(ctx, tx, models.EventRoleAssigned, &models.RoleEventData{RoleId: roleId, RoleName: role.Name, UserId: userId}); err != nil {
+			return err
+		}
+	}
+	for _, userId := range difference(before, after) {
+		if err = s.Outbox.Enqueue(ctx, tx, models.EventRoleRevoked, &models.RoleEventData{RoleId: roleId, RoleName: role.Name, UserId: userId}); err != nil {
+			return err
+		}
+	}
+
+	return s.recordAudit(ctx, tx, entry, models.AuditTargetRole, roleId, map[string]any{"memberIds": before}, map[string]any{"memberIds": after})
+}
+
+// memberIds returns the ids of the users with the role ordered by id
+func memberIds(ctx context.Context, q queryer, roleId uint64) ([]uint64, error) {
+	var ids []int64
+
+	err := q.QueryRowContext(ctx, `SELECT COALESCE(array_agg(userId ORDER BY userId), '{}') FROM "userRoles" WHERE roleId = $1`, roleId).Scan(pq.Array(&ids))
+	if err != nil {
+		return nil, err
+	}
+
+	members := make([]uint64, 0, len(ids))
+	for _, id := range ids {
+		members = append(members, uint64(id))
+	}
+
+	return members, nil
+}
+
+// difference returns the ids of a that are not in b
+func difference(a, b []uint64) []uint64 {
+	inB := make(map[uint64]bool, len(b))
+	for _, id := range b {
+		inB[id] = true
+	}
+
+	var ids []uint64
+	for _, id := range a {
+		if !inB[id] {
+			ids = append(ids, id)
+		}
+	}
+
+	return ids
+}
+
+// recordAudit completes the entry with the target and its states and inserts it in the transaction,
+// a nil entry is not recorded
+func (s *Storage) recordAudit(ctx context.Context, tx *sql.Tx, entry *models.AuditEntry, targetType string, targetId uint64, before, after any) error {
+	if entry == nil {
+		return nil
+	}
+
+	entry.TargetType = targetType
+	entry.TargetId = targetId
+	entry.Before = before
+	entry.After = after
+
+	return s.Audit.Insert(ctx, tx, entry)
+}
+
+// isUniqueViolation reports if the error is a violated unique constraint
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
+
+// nullString returns a null string for nil
+func nullString(value *string) sql.NullString {
+	if value == nil {
+		return sql.NullString{}
+	}
+
+	return sql.NullString{String: *value, Valid: true}
+}
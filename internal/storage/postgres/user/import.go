@@ -0,0 +1,166 @@
+package user
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sso_go_grpc/internal/domain/models"
+	"sso_go_grpc/internal/storage"
+	"sso_go_grpc/internal/storage/postgres/audit"
+	"sso_go_grpc/internal/storage/postgres/outbox"
+)
+
+// Importer imports users in one transaction, every user is imported in its own savepoint,
+// so a failing user does not break the import of the others
+type Importer struct {
+	tx     *sql.Tx
+	outbox *outbox.Storage
+	audit  *audit.Storage
+	// entry is copied for the audit entry of every assigned role
+	entry *models.AuditEntry
+	// roles caches the roles of the role names
+	roles map[string]*models.Role
+}
+
+// BeginImport starts the transaction of an import, every assigned role is recorded
+// with a copy of the audit entry, no entries are recorded for a nil entry
+func (s *Storage) BeginImport(ctx context.Context, entry *models.AuditEntry) (*Importer, error) {
+	tx, err := s.Db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Importer{tx: tx, outbox: s.Outbox, audit: s.Audit, entry: entry, roles: make(map[string]*models.Role)}, nil
+}
+
+// Import inserts the user with the already hashed password and assigns the roles by name,
+// returns storage.ErrUserExists, storage.ErrInvalidUsername or storage.ErrRoleNotExists
+func (i *Importer) Import(ctx context.Context, user *models.ImportUser) (uint64, error) {
+	if _, err := i.tx.ExecContext(ctx, "SAVEPOINT import_user"); err != nil {
+		return 0, err
+	}
+
+	userId, err := i.importUser(ctx, user)
+	if err != nil {
+		if _, rollbackErr := i.tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT import_user"); rollbackErr != nil {
+			return 0, rollbackErr
+		}
+		return 0, err
+	}
+
+	if _, err = i.tx.ExecContext(ctx, "RELEASE SAVEPOINT import_user"); err != nil {
+		return 0, err
+	}
+
+	return userId, nil
+}
+
+// Commit commits all imported users
+func (i *Importer) Commit() error {
+	return i.tx.Commit()
+}
+
+// Rollback discards all imported users, used for dry runs
+func (i *Importer) Rollback() error {
+	return i.tx.Rollback()
+}
+
+func (i *Importer) importUser(ctx context.Context, user *models.ImportUser) (uint64, error) {
+	// uniqueness is checked by the unique indexes on the normalized columns
+	emailNormalized, usernameNormalized, err := normalizeIdentity(user.Email, user.Username)
+	if err != nil {
+		return 0, err
+	}
+
+	var (
+		userId       uint64
+		status       string
+		passwordHash sql.NullString
+	)
+
+	// users without a password hash can not login until the password is set
+	if user.PasswordHash != "" {
+		passwordHash = sql.NullString{String: user.PasswordHash, Valid: true}
+	}
+
+	err = i.tx.QueryRowContext(ctx, `
+        INSERT INTO users(email, password, username, email_normalized, username_normalized)
+        VALUES($1, $2, $3, $4, $5) RETURNING id, status`,
+		user.Email, passwordHash, user.Username, emailNormalized, usernameNormalized,
+	).Scan(&userId, &status)
+
+	if err != nil {
+		if isUniqueViolation(err) {
+			return 0, storage.ErrUserExists
+		}
+		return 0, err
+	}
+
+	// the events are rolled back with the savepoint if the user fails
+	err = i.outbox.Enqueue(ctx, i.tx, models.EventUserCreated, &models.UserEventData{UserId: userId, Email: user.Email, Username: user.Username, Status: status})
+	if err != nil {
+		return 0, err
+	}
+
+	// a role named twice in the row is assigned once
+	assigned := make(map[string]bool, len(user.Roles))
+
+	for _, roleName := range user.Roles {
+		if assigned[roleName] {
+			continue
+		}
+		assigned[roleName] = true
+
+		role, err := i.role(ctx, roleName)
+		if err != nil {
+			return 0, err
+		}
+
+		if _, err = i.tx.ExecContext(ctx, `INSERT INTO "userRoles" (userId, roleId) VALUES($1, $2)`, userId, role.Id); err != nil {
+			return 0, err
+		}
+
+		err = i.outbox.Enqueue(ctx, i.tx, models.EventRoleAssigned, &models.RoleEventData{RoleId: role.Id, RoleName: role.Name, UserId: userId})
+		if err != nil {
+			return 0, err
+		}
+
+		if i.entry == nil {
+			continue
+		}
+
+		// like an added role, the target is the user and the role is its after state
+		roleEntry := *i.entry
+		roleEntry.Action = models.AuditActionUserRoleAdd
+		roleEntry.TargetType = models.AuditTargetUser
+		roleEntry.TargetId = userId
+		roleEntry.After = role
+
+		if err = i.audit.Insert(ctx, i.tx, &roleEntry); err != nil {
+			return 0, err
+		}
+	}
+
+	return userId, nil
+}
+
+// role returns the role with that name
+func (i *Importer) role(ctx context.Context, name string) (*models.Role, error) {
+	if role, ok := i.roles[name]; ok {
+		return role, nil
+	}
+
+	var role models.Role
+
+	err := i.tx.QueryRowContext(ctx, "SELECT id, name, COALESCE(description, '') FROM roles WHERE name = $1", name).
+		Scan(&role.Id, &role.Name, &role.Description)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, storage.ErrRoleNotExists
+		}
+		return nil, err
+	}
+
+	i.roles[name] = &role
+	return &role, nil
+}
@@ -3,14 +3,22 @@ package user
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
+	"fmt"
 	_ "github.com/golang-migrate/migrate/v4/database/postgres"
+	"github.com/lib/pq"
 	"log/slog"
 	"sso_go_grpc/internal/domain/models"
 	"sso_go_grpc/internal/lib/bcrypt"
+	"sso_go_grpc/internal/lib/normalize"
 	"sso_go_grpc/internal/storage"
+	"sso_go_grpc/internal/storage/postgres/audit"
+	"sso_go_grpc/internal/storage/postgres/outbox"
 	"strconv"
 	_ "strconv"
+	"strings"
+	"time"
 )
 
 type StorageInterface interface {
@@ -18,16 +26,27 @@ type StorageInterface interface {
 	GetUserByEmail(ctx context.Context, email string) (*models.User, error)
 	GetUserById(ctx context.Context, userId uint64) (*models.User, error)
 	GetRoleById(ctx context.Context, roleId uint64) (*models.Role, error)
+	ListUsers(ctx context.Context, filter models.UserFilter) ([]*models.User, error)
+	GetRolesByUserIds(ctx context.Context, userIds []uint64) (map[uint64][]*models.Role, error)
+	UpdateAttributes(ctx context.Context, userId, expectedVersion uint64, attributes map[string]any) (uint64, error)
+	GetRoleAssignments(ctx context.Context, userId uint64) ([]*models.RoleAssignment, error)
+	CountUsers(ctx context.Context, filter models.UserFilter) (uint64, error)
+	UpdateUser(ctx context.Context, user *models.User, expectedVersion uint64) error
+	UpdatePassword(ctx context.Context, userId uint64, pwdHash string) error
+	DeleteUser(ctx context.Context, userId uint64, entry *models.AuditEntry) error
 }
 
+// the methods creating and changing users write their events into the outbox in the same transaction
 type Storage struct {
 	StorageInterface
-	Db  *sql.DB
-	Log *slog.Logger
+	Db     *sql.DB
+	Log    *slog.Logger
+	Outbox *outbox.Storage
+	Audit  *audit.Storage
 }
 
-func CreateStorage(db *sql.DB, log *slog.Logger) *Storage {
-	return &Storage{Db: db, Log: log}
+func CreateStorage(db *sql.DB, log *slog.Logger, outbox *outbox.Storage, audit *audit.Storage) *Storage {
+	return &Storage{Db: db, Log: log, Outbox: outbox, Audit: audit}
 }
 
 // GetUserByEmail this method gets a user if it not exist it return UserNotExist err
@@ -36,17 +55,20 @@ func (s *Storage) GetUserByEmail(ctx context.Context, email string) (*models.Use
 	log := s.Log.With("op", op)
 
 	var (
-		username, hashedPwd, roleName, roleDescription sql.NullString
-		userId, roleId                                 sql.NullInt64
+		storedEmail, username, hashedPwd, status, roleName, roleDescription sql.NullString
+		userId, roleId                                                      sql.NullInt64
+		createdAt, lastLoginAt                                              sql.NullTime
+		attributes                                                          []byte
+		version                                                             uint64
 	)
 
 	rows, err := s.Db.QueryContext(ctx, `
-        SELECT u.id, u.username, u.password, r.name, r.id, r.description
+        SELECT u.id, u.email, u.username, u.password, u.status, u.created_at, u.last_login_at, u.attributes, u.version, r.name, r.id, r.description
         FROM users u
         LEFT JOIN "userRoles" ur ON u.id = ur.userId
         LEFT JOIN roles r ON ur.roleId = r.id
-		WHERE email = $1
-`, email)
+		WHERE u.email_normalized = $1
+`, normalize.Email(email))
 
 	if err != nil {
 		log.Debug("Error by Getting user by email in the database")
@@ -57,7 +79,7 @@ func (s *Storage) GetUserByEmail(ctx context.Context, email string) (*models.Use
 
 	var roles []*models.Role
 	for rows.Next() {
-		err := rows.Scan(&userId, &username, &hashedPwd, &roleName, &roleId, &roleDescription)
+		err := rows.Scan(&userId, &storedEmail, &username, &hashedPwd, &status, &createdAt, &lastLoginAt, &attributes, &version, &roleName, &roleId, &roleDescription)
 		if err != nil {
 			return nil, err
 		}
@@ -70,24 +92,45 @@ func (s *Storage) GetUserByEmail(ctx context.Context, email string) (*models.Use
 		return nil, storage.ErrUserNotExists
 	}
 
-	return &models.User{Email: email, Username: username.String, UserId: uint64(userId.Int64), Password: hashedPwd.String, Roles: roles}, nil
+	userAttributes, err := decodeAttributes(attributes)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.User{
+		Email:       storedEmail.String,
+		Username:    username.String,
+		UserId:      uint64(userId.Int64),
+		Password:    hashedPwd.String,
+		Status:      status.String,
+		CreatedAt:   createdAt.Time,
+		LastLoginAt: lastLoginAt.Time,
+		Roles:       roles,
+		Attributes:  userAttributes,
+		Version:     version,
+	}, nil
 }
 
 // GetUserByUsername this method gets a user if it not exist it return UserNotExist err
 func (s *Storage) GetUserByUsername(ctx context.Context, username string) (*models.User, error) {
 
 	// user email and hashed password
-	var email, hashedPwd, roleName, roleDescription sql.NullString
+	var storedUsername, email, hashedPwd, roleName, roleDescription sql.NullString
 	var userId, roleId sql.NullInt64
 
+	usernameNormalized, err := normalize.Username(username)
+	if err != nil {
+		return nil, storage.ErrUserNotExists
+	}
+
 	//create and execute sql
 	rows, err := s.Db.QueryContext(ctx, `
-        SELECT u.username, u.email, u.password, r.name, r.id, r.description
+        SELECT u.id, u.username, u.email, u.password, r.id, r.name, r.description
         FROM users u
         LEFT JOIN "userRoles" ur ON u.id = ur.userId
         LEFT JOIN roles r ON ur.roleId = r.id
-		                            WHERE username = $1
-`, username)
+		                            WHERE u.username_normalized = $1
+`, usernameNormalized)
 
 	// if there was an error in sql
 	if err != nil {
@@ -98,33 +141,33 @@ func (s *Storage) GetUserByUsername(ctx context.Context, username string) (*mode
 
 	var roles []*models.Role
 	for rows.Next() {
-		if err = rows.Scan(&userId, &email, &hashedPwd, &roleId, &roleName, &roleDescription); err != nil {
+		if err = rows.Scan(&userId, &storedUsername, &email, &hashedPwd, &roleId, &roleName, &roleDescription); err != nil {
 			return nil, err
 		}
 
-		if !userId.Valid {
-			return nil, storage.ErrUserNotExists
-		}
-
 		if roleId.Valid {
 			roles = append(roles, &models.Role{Id: uint64(roleId.Int64), Description: roleDescription.String, Name: roleName.String})
 		}
 	}
-	//if there was an error in converting string to uint64
-	if err != nil {
-		return nil, err
+
+	if !userId.Valid {
+		return nil, storage.ErrUserNotExists
 	}
 
-	return &models.User{Email: email.String, Username: username, UserId: uint64(userId.Int64)}, nil
+	return &models.User{Email: email.String, Username: storedUsername.String, UserId: uint64(userId.Int64), Password: hashedPwd.String, Roles: roles}, nil
 }
 
 func (s *Storage) GetUserById(ctx context.Context, userId uint64) (*models.User, error) {
 	var (
-		email, username, hashedPwd string
-		userFound                  bool
+		email, username, hashedPwd, status string
+		createdAt                          time.Time
+		lastLoginAt                        sql.NullTime
+		attributes                         []byte
+		version                            uint64
+		userFound                          bool
 	)
 	rows, err := s.Db.QueryContext(ctx, `
-        SELECT u.username, u.email, u.password, r.name, r.id, r.description
+        SELECT u.username, u.email, u.password, u.status, u.created_at, u.last_login_at, u.attributes, u.version, r.name, r.id, r.description
         FROM users u
         LEFT JOIN "userRoles" ur ON u.id = ur.userId
         LEFT JOIN roles r ON ur.roleId = r.id
@@ -143,7 +186,7 @@ func (s *Storage) GetUserById(ctx context.Context, userId uint64) (*models.User,
 			roleDescription sql.NullString
 		)
 
-		if err := rows.Scan(&username, &email, &hashedPwd, &roleName, &roleId, &roleDescription); err != nil {
+		if err := rows.Scan(&username, &email, &hashedPwd, &status, &createdAt, &lastLoginAt, &attributes, &version, &roleName, &roleId, &roleDescription); err != nil {
 			if errors.Is(sql.ErrNoRows, err) {
 				return nil, storage.ErrUserNotExists
 			}
@@ -158,77 +201,600 @@ func (s *Storage) GetUserById(ctx context.Context, userId uint64) (*models.User,
 		return nil, storage.ErrUserNotExists
 	}
 	defer rows.Close()
-	return &models.User{Email: email, Username: username, UserId: userId, Roles: roles}, nil
+
+	userAttributes, err := decodeAttributes(attributes)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.User{
+		Email:       email,
+		Username:    username,
+		UserId:      userId,
+		Status:      status,
+		CreatedAt:   createdAt,
+		LastLoginAt: lastLoginAt.Time,
+		Roles:       roles,
+		Attributes:  userAttributes,
+		Version:     version,
+	}, nil
 }
 
 // CreateUser this method creates new user and proofs if user with that email or username does exist
 func (s *Storage) CreateUser(ctx context.Context, email, password, username string) (*models.User, error) {
+	pwdHash, err := bcrypt.HashPassword(ctx, password)
+
+	//if err in hashing password
+	if err != nil {
+		s.Log.Error("Error on hashing password", "err", err)
+		return nil, err
+	}
+
+	return s.CreateUserWithHash(ctx, email, pwdHash, username, models.UserStatusActive)
+}
+
+// CreateUserWithHash creates new user with an already hashed password and the status, empty for active,
+// an empty hash creates a user without password that can not login
+func (s *Storage) CreateUserWithHash(ctx context.Context, email, pwdHash, username, status string) (*models.User, error) {
 	op := "app.grpc.app"
 
 	log := s.Log.With("op", op)
 
 	// userId as string
 	var userIdStr string
-	var userExists bool
-	// check if user with that email exists
-	if _, err := s.GetUserByEmail(ctx, email); err == nil {
-		log.Error("User with that username already exists", err)
-		return nil, storage.ErrUserExists
+
+	// uniqueness is checked by the unique indexes on the normalized columns
+	emailNormalized, usernameNormalized, err := normalizeIdentity(email, username)
+	if err != nil {
+		return nil, err
 	}
 
-	// check if user with that username exists
-	if _, err := s.GetUserByUsername(ctx, username); err == nil {
-		log.Error("User with that username already exists", err)
-		return nil, storage.ErrUserExists
+	tx, err := s.Db.BeginTx(ctx, nil)
+
+	// if there was an error on creating transaction
+	if err != nil {
+		return nil, err
+	}
+
+	//users without password have NULL as password
+	password := sql.NullString{String: pwdHash, Valid: pwdHash != ""}
+
+	if status == "" {
+		status = models.UserStatusActive
+	}
+
+	//execute sql
+	err = tx.QueryRowContext(ctx, `
+        INSERT INTO users(email, password, username, email_normalized, username_normalized, status)
+        VALUES($1, $2, $3, $4, $5, $6) RETURNING id`,
+		email, password, username, emailNormalized, usernameNormalized, status,
+	).Scan(&userIdStr)
+
+	//if there was an error in executing sql
+	if err != nil {
+		tx.Rollback()
+		if isUniqueViolation(err) {
+			log.Debug("User with that email or username already exists")
+			return nil, storage.ErrUserExists
+		}
+		log.Error("Error on executing sql", "err", err)
+		return nil, err
+	}
+
+	// str to uint64
+	userId, err := strconv.ParseUint(userIdStr, 10, 64)
+
+	//if there was an error in converting string to uint64
+	if err != nil {
+		tx.Rollback()
+		log.Error("Error on converting string to uint64", "err", err)
+		return nil, err
 	}
 
-	err := s.Db.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM users WHERE email = $1 OR username = $2)", email, username).Scan(&userExists)
+	err = s.Outbox.Enqueue(ctx, tx, models.EventUserCreated, &models.UserEventData{UserId: userId, Email: email, Username: username, Status: status})
 	if err != nil {
-		log.Debug("Error in Querying user by email or username", err)
+		tx.Rollback()
+		log.Error("Error on writing event", "err", err)
 		return nil, err
 	}
 
-	if userExists {
-		return nil, storage.ErrUserExists
+	//commit the changes to the database
+	if err = tx.Commit(); err != nil {
+		return nil, err
 	}
 
-	//prepare Db CALL
-	prepared, err := s.Db.Prepare("INSERT INTO users(email, password, username) VALUES($1, $2, $3) RETURNING id")
+	//fill the user model and return it
+	return s.GetUserById(ctx, userId)
+}
+
+// ListUsers returns users matching the filter ordered by id,
+// roles are not loaded, use GetRolesByUserIds for that
+func (s *Storage) ListUsers(ctx context.Context, filter models.UserFilter) ([]*models.User, error) {
+	op := "storage.postgres.ListUsers"
+	log := s.Log.With("op", op)
 
-	//if there was an error in preparing sql
+	where, args, err := filterConditions(filter)
 	if err != nil {
-		log.Debug("Error in preparing sql", err)
 		return nil, err
 	}
 
-	pwdHash, err := bcrypt.HashPassword(password)
+	args = append(args, filter.Limit, filter.Offset)
+	query := fmt.Sprintf(`
+        SELECT u.id, u.username, u.email, u.status, u.created_at, u.last_login_at, u.attributes, u.version
+        FROM users u
+        WHERE %s
+        ORDER BY u.id
+        LIMIT $%d OFFSET $%d`, where, len(args)-1, len(args))
 
-	//if err in hashing password
+	rows, err := s.Db.QueryContext(ctx, query, args...)
 	if err != nil {
-		log.Error("Error on hashing password", err)
+		log.Debug("Error on listing users", "err", err)
 		return nil, err
 	}
 
-	//execute sql
-	err = prepared.QueryRowContext(ctx, email, pwdHash, username).Scan(&userIdStr)
+	defer rows.Close()
 
-	//if there was an error in executing sql
+	var users []*models.User
+	for rows.Next() {
+		var (
+			user            models.User
+			username, email sql.NullString
+			lastLoginAt     sql.NullTime
+			attributes      []byte
+			err             error
+		)
+
+		if err = rows.Scan(&user.UserId, &username, &email, &user.Status, &user.CreatedAt, &lastLoginAt, &attributes, &user.Version); err != nil {
+			return nil, err
+		}
+
+		user.LastLoginAt = lastLoginAt.Time
+
+		if user.Attributes, err = decodeAttributes(attributes); err != nil {
+			return nil, err
+		}
+
+		user.Username = username.String
+		user.Email = email.String
+		users = append(users, &user)
+	}
+
+	return users, rows.Err()
+}
+
+// CountUsers returns the amount of users matching the filter, AfterId, Limit and Offset are ignored
+func (s *Storage) CountUsers(ctx context.Context, filter models.UserFilter) (uint64, error) {
+	filter.AfterId = 0
+
+	where, args, err := filterConditions(filter)
+	if err != nil {
+		return 0, err
+	}
+
+	var count uint64
+	err = s.Db.QueryRowContext(ctx, fmt.Sprintf(`SELECT count(*) FROM users u WHERE %s`, where), args...).Scan(&count)
+
+	return count, err
+}
+
+// filterConditions returns the WHERE conditions of the filter and their arguments
+func filterConditions(filter models.UserFilter) (string, []any, error) {
+	// every filter adds a condition and its argument
+	conditions := []string{"u.id > $1"}
+	args := []any{filter.AfterId}
+
+	addCondition := func(condition string, arg any) {
+		args = append(args, arg)
+		conditions = append(conditions, strings.ReplaceAll(condition, "$?", fmt.Sprintf("$%d", len(args))))
+	}
+
+	if len(filter.RoleIds) > 0 {
+		addCondition(`EXISTS (SELECT 1 FROM "userRoles" ur WHERE ur.userId = u.id AND ur.roleId = ANY($?::int[]))`, pq.Array(filter.RoleIds))
+	}
+	if filter.Status != "" {
+		addCondition("u.status = $?", filter.Status)
+	}
+	if !filter.CreatedAfter.IsZero() {
+		addCondition("u.created_at >= $?", filter.CreatedAfter)
+	}
+	if !filter.CreatedBefore.IsZero() {
+		addCondition("u.created_at < $?", filter.CreatedBefore)
+	}
+	if filter.EmailDomain != "" {
+		addCondition("lower(split_part(u.email, '@', 2)) = lower($?)", filter.EmailDomain)
+	}
+	if filter.Search != "" {
+		pattern := escapeLike(filter.Search) + "%"
+		if !filter.PrefixSearch {
+			pattern = "%" + pattern
+		}
+		addCondition("(u.username ILIKE $? OR u.email ILIKE $?)", pattern)
+	}
+	if filter.Username != "" {
+		username, err := normalize.Username(filter.Username)
+		if err != nil {
+			// no user can have an invalid username
+			username = ""
+		}
+		addCondition("u.username_normalized = $?", username)
+	}
+	if filter.Email != "" {
+		addCondition("u.email_normalized = $?", normalize.Email(filter.Email))
+	}
+	if len(filter.Attributes) > 0 {
+		attributes, err := json.Marshal(filter.Attributes)
+		if err != nil {
+			return "", nil, err
+		}
+		addCondition("u.attributes @> $?::jsonb", string(attributes))
+	}
+
+	return strings.Join(conditions, " AND "), args, nil
+}
+
+// UpdateUser updates email, username and status of the user,
+// returns storage.ErrVersionMismatch if expectedVersion is not 0 and not the version of the user
+func (s *Storage) UpdateUser(ctx context.Context, user *models.User, expectedVersion uint64) error {
+	op := "storage.postgres.UpdateUser"
+	log := s.Log.With("op", op)
+
+	emailNormalized, usernameNormalized, err := normalizeIdentity(user.Email, user.Username)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.Db.BeginTx(ctx, nil)
+
+	// if there was an error on creating transaction
+	if err != nil {
+		return err
+	}
+
+	// the previous values tell which fields the event reports as changed
+	var previousEmail, previousUsername sql.NullString
+	var previousStatus string
+	var version uint64
+
+	err = tx.QueryRowContext(ctx, `SELECT email, username, status, version FROM users WHERE id = $1 FOR UPDATE`, user.UserId).
+		Scan(&previousEmail, &previousUsername, &previousStatus, &version)
+	if err != nil {
+		tx.Rollback()
+		if errors.Is(err, sql.ErrNoRows) {
+			return storage.ErrUserNotExists
+		}
+		return err
+	}
+
+	if expectedVersion != 0 && version != expectedVersion {
+		tx.Rollback()
+		return storage.ErrVersionMismatch
+	}
+
+	_, err = tx.ExecContext(ctx, `
+        UPDATE users
+        SET email = $1, username = $2, status = $3, email_normalized = $4, username_normalized = $5, version = version + 1
+        WHERE id = $6`,
+		user.Email, user.Username, user.Status, emailNormalized, usernameNormalized, user.UserId,
+	)
+	if err != nil {
+		tx.Rollback()
+		if isUniqueViolation(err) {
+			return storage.ErrUserExists
+		}
+		log.Debug("Error on executing query", "err", err)
+		return err
+	}
+
+	var changed []string
+	if previousEmail.String != user.Email {
+		changed = append(changed, "email")
+	}
+	if previousUsername.String != user.Username {
+		changed = append(changed, "username")
+	}
+	if previousStatus != user.Status {
+		changed = append(changed, "status")
+	}
+
+	// nothing to tell if the values are the same
+	if len(changed) > 0 {
+		err = s.Outbox.Enqueue(ctx, tx, models.EventUserUpdated, &models.UserEventData{
+			UserId:   user.UserId,
+			Email:    user.Email,
+			Username: user.Username,
+			Status:   user.Status,
+			Changed:  changed,
+		})
+		if err != nil {
+			tx.Rollback()
+			log.Debug("Error on writing event", "err", err)
+			return err
+		}
+	}
+
+	//commit the changes to the database
+	return tx.Commit()
+}
+
+// UpdatePassword sets the already hashed password of the user
+func (s *Storage) UpdatePassword(ctx context.Context, userId uint64, pwdHash string) error {
+	result, err := s.Db.ExecContext(ctx, "UPDATE users SET password = $1, version = version + 1 WHERE id = $2", pwdHash, userId)
+	if err != nil {
+		return err
+	}
+
+	return expectOneRow(result)
+}
+
+// DeleteUser deletes the user and all its roles, every removed role is revoked with an event
+// and recorded with the audit entry like a removal of the role, no entry is recorded for a nil entry
+func (s *Storage) DeleteUser(ctx context.Context, userId uint64, entry *models.AuditEntry) error {
+	op := "storage.postgres.DeleteUser"
+	logger := s.Log.With("op", op)
+
+	tx, err := s.Db.BeginTx(ctx, nil)
+
+	// if there was an error on creating transaction
+	if err != nil {
+		return err
+	}
+
+	// delete the user from all roles, the removed roles are returned for their events
+	rows, err := tx.QueryContext(ctx, `
+        WITH removed AS (DELETE FROM "userRoles" ur WHERE ur.userId = $1 RETURNING ur.roleId)
+        SELECT DISTINCT r.id, r.name, COALESCE(r.description, '')
+        FROM removed JOIN roles r ON r.id = removed.roleId
+        ORDER BY r.id`, userId)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	var roles []*models.Role
+	for rows.Next() {
+		var role models.Role
+		if err = rows.Scan(&role.Id, &role.Name, &role.Description); err != nil {
+			rows.Close()
+			tx.Rollback()
+			return err
+		}
+		roles = append(roles, &role)
+	}
+	rows.Close()
+
+	if err = rows.Err(); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	for _, role := range roles {
+		err = s.Outbox.Enqueue(ctx, tx, models.EventRoleRevoked, &models.RoleEventData{RoleId: role.Id, RoleName: role.Name, UserId: userId})
+		if err != nil {
+			logger.Debug("Error on writing event", "err", err)
+			tx.Rollback()
+			return err
+		}
+
+		if entry == nil {
+			continue
+		}
+
+		// the target is the user, the removed role is its before state
+		roleEntry := *entry
+		roleEntry.Action = models.AuditActionUserRoleRemove
+		roleEntry.TargetType = models.AuditTargetUser
+		roleEntry.TargetId = userId
+		roleEntry.Before = role
+
+		if err = s.Audit.Insert(ctx, tx, &roleEntry); err != nil {
+			logger.Debug("Error on recording audit entry", "err", err)
+			tx.Rollback()
+			return err
+		}
+	}
+
+	result, err := tx.ExecContext(ctx, `DELETE FROM users u WHERE u.id = $1`, userId)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err = expectOneRow(result); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// normalizeIdentity returns the normalized email and username,
+// storage.ErrInvalidUsername if the username has characters that are not allowed
+func normalizeIdentity(email, username string) (string, string, error) {
+	usernameNormalized, err := normalize.Username(username)
+	if err != nil {
+		return "", "", storage.ErrInvalidUsername
+	}
+
+	return normalize.Email(email), usernameNormalized, nil
+}
+
+// isUniqueViolation reports if the error is a violated unique constraint
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
+
+// expectOneRow returns storage.ErrUserNotExists if no row was affected
+func expectOneRow(result sql.Result) error {
+	affectedRows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affectedRows == 0 {
+		return storage.ErrUserNotExists
+	}
+
+	return nil
+}
+
+// GetRolesByUserIds returns the roles of all given users with one query,
+// users without roles are not in the map
+func (s *Storage) GetRolesByUserIds(ctx context.Context, userIds []uint64) (map[uint64][]*models.Role, error) {
+	roles := make(map[uint64][]*models.Role)
+
+	if len(userIds) == 0 {
+		return roles, nil
+	}
+
+	rows, err := s.Db.QueryContext(ctx, `
+        SELECT ur.userId, r.id, r.name, r.description
+        FROM "userRoles" ur
+        JOIN roles r ON ur.roleId = r.id
+        WHERE ur.userId = ANY($1::int[])
+        ORDER BY ur.userId, r.id`, pq.Array(userIds))
 	if err != nil {
-		log.Error("Error on executing sql", err)
 		return nil, err
 	}
 
-	// str to uint64
-	userId, err := strconv.ParseUint(userIdStr, 10, 64)
+	defer rows.Close()
 
-	//if there was an error in converting string to uint64
+	for rows.Next() {
+		var (
+			userId      uint64
+			role        models.Role
+			description sql.NullString
+		)
+
+		if err := rows.Scan(&userId, &role.Id, &role.Name, &description); err != nil {
+			return nil, err
+		}
+
+		role.Description = description.String
+		roles[userId] = append(roles[userId], &role)
+	}
+
+	return roles, rows.Err()
+}
+
+// GetRoleAssignments returns the roles of the user with the time they were assigned
+func (s *Storage) GetRoleAssignments(ctx context.Context, userId uint64) ([]*models.RoleAssignment, error) {
+	rows, err := s.Db.QueryContext(ctx, `
+        SELECT r.id, r.name, r.description, ur.created_at
+        FROM "userRoles" ur
+        JOIN roles r ON ur.roleId = r.id
+        WHERE ur.userId = $1
+        ORDER BY ur.created_at`, userId)
 	if err != nil {
-		log.Error("Error on converting string to uint64", err)
 		return nil, err
 	}
 
-	user, err := s.GetUserById(ctx, userId)
+	defer rows.Close()
 
-	//fill the user model and return it
-	return user, nil
+	assignments := make([]*models.RoleAssignment, 0)
+	for rows.Next() {
+		var (
+			assignment  models.RoleAssignment
+			description sql.NullString
+		)
+
+		if err := rows.Scan(&assignment.RoleId, &assignment.Name, &description, &assignment.AssignedAt); err != nil {
+			return nil, err
+		}
+
+		assignment.Description = description.String
+		assignments = append(assignments, &assignment)
+	}
+
+	return assignments, rows.Err()
+}
+
+// UpdateAttributes replaces all custom attributes of the user and returns its new version,
+// returns storage.ErrVersionMismatch if expectedVersion is not 0 and not the version of the user
+func (s *Storage) UpdateAttributes(ctx context.Context, userId, expectedVersion uint64, attributes map[string]any) (uint64, error) {
+	op := "storage.postgres.UpdateAttributes"
+	log := s.Log.With("op", op)
+
+	encoded, err := json.Marshal(attributes)
+	if err != nil {
+		return 0, err
+	}
+
+	tx, err := s.Db.BeginTx(ctx, nil)
+
+	// if there was an error on creating transaction
+	if err != nil {
+		return 0, err
+	}
+
+	var version uint64
+
+	err = tx.QueryRowContext(ctx, `SELECT version FROM users WHERE id = $1 FOR UPDATE`, userId).Scan(&version)
+	if err != nil {
+		tx.Rollback()
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, storage.ErrUserNotExists
+		}
+		return 0, err
+	}
+
+	if expectedVersion != 0 && version != expectedVersion {
+		tx.Rollback()
+		return 0, storage.ErrVersionMismatch
+	}
+
+	var (
+		email, username sql.NullString
+		status          string
+	)
+
+	err = tx.QueryRowContext(ctx, `
+        UPDATE users SET attributes = $1::jsonb, version = version + 1 WHERE id = $2
+        RETURNING email, username, status, version`, string(encoded), userId,
+	).Scan(&email, &username, &status, &version)
+	if err != nil {
+		tx.Rollback()
+		log.Debug("Error on executing query", "err", err)
+		return 0, err
+	}
+
+	err = s.Outbox.Enqueue(ctx, tx, models.EventUserUpdated, &models.UserEventData{
+		UserId:   userId,
+		Email:    email.String,
+		Username: username.String,
+		Status:   status,
+		Changed:  []string{"attributes"},
+	})
+	if err != nil {
+		tx.Rollback()
+		log.Debug("Error on writing event", "err", err)
+		return 0, err
+	}
+
+	//commit the changes to the database
+	if err = tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	return version, nil
+}
+
+// decodeAttributes decodes the jsonb attributes column, NULL is an empty map
+func decodeAttributes(encoded []byte) (map[string]any, error) {
+	attributes := make(map[string]any)
+
+	if len(encoded) == 0 {
+		return attributes, nil
+	}
+
+	if err := json.Unmarshal(encoded, &attributes); err != nil {
+		return nil, err
+	}
+
+	return attributes, nil
+}
+
+// escapeLike escapes the LIKE wildcards in a search term
+func escapeLike(term string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(term)
 }
@@ -14,4 +14,30 @@ var (
 	ErrUserAndRoleIvalid     = errors.New("user or role by this id do not exist")
 	ErrUserAlreadyHasTHeRole = errors.New("user already has the role")
 	ErrUserDontHaveTheRole   = errors.New("user dont have the role")
+	ErrInvalidPageToken      = errors.New("invalid page token")
+	ErrInvalidToken          = errors.New("invalid or expired token")
+	ErrAttributeNotExists    = errors.New("attribute with that name does not exist")
+	ErrInvalidAttributes     = errors.New("attributes do not match the attribute definitions")
+	ErrInvalidDefinition     = errors.New("invalid attribute definition")
+	ErrInvalidImportUser     = errors.New("email and username are required")
+	ErrInvalidEmail          = errors.New("email must be a valid address of at most 255 characters")
+	ErrInvalidUsernameLength = errors.New("username must be 3 to 64 characters long")
+	ErrInvalidPasswordHash   = errors.New("password hash is not a valid bcrypt hash")
+	ErrImportTooLarge        = errors.New("the import has too many users")
+	ErrInvalidPassword       = errors.New("password can not be used")
+	ErrInvalidUsername       = errors.New("username contains characters that are not allowed")
+	ErrAuditEntryNotExists   = errors.New("audit entry with that id does not exist")
+	ErrAuditChainBroken      = errors.New("audit hash chain is broken")
+	ErrNoCheckpointKey       = errors.New("no audit checkpoint key is configured")
+	ErrSubscriptionNotExists = errors.New("webhook subscription with that id does not exist")
+	ErrDeliveryNotExists     = errors.New("webhook delivery with that id does not exist")
+	ErrInvalidSubscription   = errors.New("invalid webhook subscription")
+	ErrInvalidResumeToken    = errors.New("invalid resume token")
+	ErrInvalidEventType      = errors.New("unknown event type")
+	ErrSchemaOutdated        = errors.New("database schema is not migrated to the needed version")
+	ErrSchemaDirty           = errors.New("database schema is dirty after a failed migration")
+	ErrShuttingDown          = errors.New("server is shutting down")
+	ErrVersionMismatch       = errors.New("the version does not match, the data was changed in the meantime")
+	ErrIdempotencyKeyInUse   = errors.New("a request with that idempotency key is still running")
+	ErrIdempotencyKeyReused  = errors.New("idempotency key was already used with a different request")
 )
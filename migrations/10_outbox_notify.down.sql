@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS "outbox_type_idx";
+DROP TRIGGER IF EXISTS "outbox_notify" ON "outbox";
+DROP FUNCTION IF EXISTS "outbox_notify"();
@@ -0,0 +1,16 @@
+-- wakes the WatchUserChanges streams of every replica, the notification is only sent on commit
+CREATE OR REPLACE FUNCTION "outbox_notify"() RETURNS trigger AS
+$$
+BEGIN
+    PERFORM pg_notify('outbox_events', NEW.id::text);
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER "outbox_notify"
+    AFTER INSERT
+    ON "outbox"
+    FOR EACH ROW
+EXECUTE FUNCTION "outbox_notify"();
+
+CREATE INDEX IF NOT EXISTS "outbox_type_idx" ON "outbox" (type, id);
@@ -0,0 +1,5 @@
+-- the version is incremented by every change of the row, updates and deletes can expect a version
+ALTER TABLE "users"
+    ADD COLUMN IF NOT EXISTS version BIGINT NOT NULL DEFAULT 1;
+ALTER TABLE "roles"
+    ADD COLUMN IF NOT EXISTS version BIGINT NOT NULL DEFAULT 1;
@@ -0,0 +1,17 @@
+-- the first response of a mutating request with an idempotency-key, replayed to retries with the same key and payload;
+-- scope is the caller of the request, key is the sha256 of the idempotency key,
+-- requestHash is an HMAC of the payload keyed with the server secret, the payload of Register has the password,
+-- response is encrypted with a key derived from the idempotency key and NULL while the first request is running
+CREATE TABLE IF NOT EXISTS "idempotencyKeys"
+(
+    scope       VARCHAR(64)  NOT NULL,
+    key         VARCHAR(255) NOT NULL,
+    method      VARCHAR(255) NOT NULL,
+    requestHash BYTEA        NOT NULL,
+    response    BYTEA,
+    created_at  TIMESTAMPTZ  NOT NULL DEFAULT now(),
+    expires_at  TIMESTAMPTZ  NOT NULL,
+    PRIMARY KEY (scope, key, method)
+);
+
+CREATE INDEX IF NOT EXISTS "idempotencyKeys_expires_at_idx" ON "idempotencyKeys" (expires_at);
@@ -0,0 +1,31 @@
+-- the ids are taken on insert, so a transaction can commit its event after a newer id was already streamed;
+-- position is assigned at commit under a lock, so the positions become visible in their order
+-- and a stream resuming after a position misses no event
+CREATE SEQUENCE IF NOT EXISTS "outbox_position_seq";
+
+ALTER TABLE "outbox"
+    ADD COLUMN IF NOT EXISTS position BIGINT;
+
+-- the resume tokens of the ids stay valid
+UPDATE "outbox" SET position = id WHERE position IS NULL;
+SELECT setval('outbox_position_seq', GREATEST((SELECT COALESCE(max(id), 0) FROM "outbox"), 1));
+
+CREATE UNIQUE INDEX IF NOT EXISTS "outbox_position_idx" ON "outbox" (position);
+CREATE INDEX IF NOT EXISTS "outbox_type_position_idx" ON "outbox" (type, position);
+
+-- 7036001 is the advisory lock key serializing the commits of the events, it is held until the commit is done
+CREATE OR REPLACE FUNCTION "outbox_position"() RETURNS trigger AS
+$$
+BEGIN
+    PERFORM pg_advisory_xact_lock(7036001);
+    UPDATE "outbox" SET position = nextval('outbox_position_seq') WHERE id = NEW.id;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE CONSTRAINT TRIGGER "outbox_position"
+    AFTER INSERT
+    ON "outbox"
+    DEFERRABLE INITIALLY DEFERRED
+    FOR EACH ROW
+EXECUTE FUNCTION "outbox_position"();
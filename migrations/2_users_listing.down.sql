@@ -0,0 +1,10 @@
+DROP INDEX IF EXISTS "userRoles_roleId_idx";
+DROP INDEX IF EXISTS "userRoles_userId_idx";
+
+DROP INDEX IF EXISTS "users_email_domain_idx";
+DROP INDEX IF EXISTS "users_created_at_idx";
+DROP INDEX IF EXISTS "users_status_idx";
+
+ALTER TABLE "users"
+    DROP COLUMN IF EXISTS created_at,
+    DROP COLUMN IF EXISTS status;
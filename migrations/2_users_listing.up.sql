@@ -0,0 +1,10 @@
+ALTER TABLE "users"
+    ADD COLUMN IF NOT EXISTS status     VARCHAR(32) NOT NULL DEFAULT 'active',
+    ADD COLUMN IF NOT EXISTS created_at TIMESTAMPTZ NOT NULL DEFAULT now();
+
+CREATE INDEX IF NOT EXISTS "users_status_idx" ON "users" (status);
+CREATE INDEX IF NOT EXISTS "users_created_at_idx" ON "users" (created_at);
+CREATE INDEX IF NOT EXISTS "users_email_domain_idx" ON "users" (lower(split_part(email, '@', 2)));
+
+CREATE INDEX IF NOT EXISTS "userRoles_userId_idx" ON "userRoles" (userId);
+CREATE INDEX IF NOT EXISTS "userRoles_roleId_idx" ON "userRoles" (roleId);
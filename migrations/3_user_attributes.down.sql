@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS "attributeDefinitions";
+
+DROP INDEX IF EXISTS "users_attributes_idx";
+
+ALTER TABLE "users"
+    DROP COLUMN IF EXISTS attributes;
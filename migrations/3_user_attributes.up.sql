@@ -0,0 +1,15 @@
+ALTER TABLE "users"
+    ADD COLUMN IF NOT EXISTS attributes JSONB NOT NULL DEFAULT '{}';
+
+CREATE INDEX IF NOT EXISTS "users_attributes_idx" ON "users" USING GIN (attributes jsonb_path_ops);
+
+CREATE TABLE IF NOT EXISTS "attributeDefinitions"
+(
+    name         VARCHAR(64) PRIMARY KEY,
+    type         VARCHAR(16)  NOT NULL,
+    required     BOOLEAN      NOT NULL DEFAULT FALSE,
+    enum         TEXT[]       NOT NULL DEFAULT '{}',
+    userWritable BOOLEAN      NOT NULL DEFAULT FALSE,
+    claim        VARCHAR(64)  NOT NULL DEFAULT '',
+    description  TEXT
+);
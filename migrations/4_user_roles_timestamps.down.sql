@@ -0,0 +1,2 @@
+ALTER TABLE "userRoles"
+    DROP COLUMN IF EXISTS created_at;
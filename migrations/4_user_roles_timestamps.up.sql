@@ -0,0 +1,2 @@
+ALTER TABLE "userRoles"
+    ADD COLUMN IF NOT EXISTS created_at TIMESTAMPTZ NOT NULL DEFAULT now();
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS "users_username_normalized_key";
+DROP INDEX IF EXISTS "users_email_normalized_key";
+
+ALTER TABLE "users"
+    DROP COLUMN IF EXISTS username_normalized,
+    DROP COLUMN IF EXISTS email_normalized;
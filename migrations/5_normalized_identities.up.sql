@@ -0,0 +1,18 @@
+ALTER TABLE "users"
+    ADD COLUMN IF NOT EXISTS email_normalized    VARCHAR(255),
+    ADD COLUMN IF NOT EXISTS username_normalized VARCHAR(255);
+
+-- existing users get an approximation of the normalized form,
+-- the application stores the full NFKC / PRECIS form from now on;
+-- if two existing users collide, the unique indexes can not be created
+-- and the accounts have to be merged before migrating
+UPDATE "users"
+SET email_normalized    = lower(normalize(trim(email), NFKC)),
+    username_normalized = lower(normalize(trim(username), NFKC))
+WHERE username_normalized IS NULL;
+
+ALTER TABLE "users"
+    ALTER COLUMN username_normalized SET NOT NULL;
+
+CREATE UNIQUE INDEX IF NOT EXISTS "users_email_normalized_key" ON "users" (email_normalized);
+CREATE UNIQUE INDEX IF NOT EXISTS "users_username_normalized_key" ON "users" (username_normalized);
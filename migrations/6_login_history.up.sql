@@ -0,0 +1,16 @@
+ALTER TABLE "users"
+    ADD COLUMN IF NOT EXISTS last_login_at TIMESTAMPTZ;
+
+-- every login attempt, userId is NULL if there is no user with the email
+CREATE TABLE IF NOT EXISTS "loginHistory"
+(
+    id         BIGSERIAL PRIMARY KEY,
+    userId     INT references users(id) ON DELETE CASCADE,
+    email      VARCHAR(255),
+    success    BOOLEAN     NOT NULL,
+    ip         VARCHAR(64),
+    userAgent  TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS "loginHistory_userId_idx" ON "loginHistory" (userId, id DESC);
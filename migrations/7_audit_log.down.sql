@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS "auditLog";
+
+DROP FUNCTION IF EXISTS "auditLog_append_only"();
@@ -0,0 +1,32 @@
+-- append-only log of administrative changes, actorId is 0 for changes of the SCIM client;
+-- actorId and targetId are no foreign keys so the entries survive deletions
+CREATE TABLE IF NOT EXISTS "auditLog"
+(
+    id         BIGSERIAL PRIMARY KEY,
+    actorId    INT         NOT NULL,
+    action     VARCHAR(64) NOT NULL,
+    targetType VARCHAR(32) NOT NULL,
+    targetId   INT         NOT NULL,
+    before     JSONB,
+    after      JSONB,
+    requestId  VARCHAR(128),
+    ip         VARCHAR(64),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS "auditLog_actorId_idx" ON "auditLog" (actorId, id DESC);
+CREATE INDEX IF NOT EXISTS "auditLog_target_idx" ON "auditLog" (targetType, targetId, id DESC);
+CREATE INDEX IF NOT EXISTS "auditLog_created_at_idx" ON "auditLog" (created_at);
+
+CREATE OR REPLACE FUNCTION "auditLog_append_only"() RETURNS trigger AS
+$$
+BEGIN
+    RAISE EXCEPTION 'auditLog is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER "auditLog_append_only"
+    BEFORE UPDATE OR DELETE
+    ON "auditLog"
+    FOR EACH ROW
+EXECUTE FUNCTION "auditLog_append_only"();
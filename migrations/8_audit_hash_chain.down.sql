@@ -0,0 +1,3 @@
+ALTER TABLE "auditLog"
+    DROP COLUMN IF EXISTS prevHash,
+    DROP COLUMN IF EXISTS hash;
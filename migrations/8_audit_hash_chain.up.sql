@@ -0,0 +1,4 @@
+-- entries from before the chain keep NULL hashes, the chain starts with the first new entry
+ALTER TABLE "auditLog"
+    ADD COLUMN IF NOT EXISTS prevHash VARCHAR(64),
+    ADD COLUMN IF NOT EXISTS hash     VARCHAR(64);
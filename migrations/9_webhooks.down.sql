@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS "webhookDeliveries";
+DROP TABLE IF EXISTS "webhookSubscriptions";
+DROP TABLE IF EXISTS "outbox";
@@ -0,0 +1,40 @@
+-- domain events, written in the transaction of the change and fanned out to the subscriptions by the dispatcher
+CREATE TABLE IF NOT EXISTS "outbox"
+(
+    id            BIGSERIAL PRIMARY KEY,
+    type          VARCHAR(64) NOT NULL,
+    payload       JSONB       NOT NULL,
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT now(),
+    dispatched_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS "outbox_pending_idx" ON "outbox" (id) WHERE dispatched_at IS NULL;
+
+-- an empty eventTypes array subscribes to every event
+CREATE TABLE IF NOT EXISTS "webhookSubscriptions"
+(
+    id         SERIAL PRIMARY KEY,
+    url        TEXT         NOT NULL,
+    secret     VARCHAR(128) NOT NULL,
+    eventTypes TEXT[]       NOT NULL DEFAULT '{}',
+    active     BOOLEAN      NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMPTZ  NOT NULL DEFAULT now()
+);
+
+-- one delivery of an event to a subscription, status is pending, delivered or dead
+CREATE TABLE IF NOT EXISTS "webhookDeliveries"
+(
+    id              BIGSERIAL PRIMARY KEY,
+    eventId         BIGINT      NOT NULL references outbox(id) ON DELETE CASCADE,
+    subscriptionId  INT         NOT NULL references "webhookSubscriptions"(id) ON DELETE CASCADE,
+    status          VARCHAR(16) NOT NULL DEFAULT 'pending',
+    attempts        INT         NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    lastError       TEXT,
+    responseStatus  INT,
+    delivered_at    TIMESTAMPTZ,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS "webhookDeliveries_due_idx" ON "webhookDeliveries" (next_attempt_at) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS "webhookDeliveries_subscriptionId_idx" ON "webhookDeliveries" (subscriptionId, id DESC);
@@ -0,0 +1,38 @@
+package ssoauth
+
+import (
+	"context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"strings"
+)
+
+type principalKey struct{}
+
+// NewContext returns a context with the principal
+func NewContext(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// FromContext returns the principal set by the interceptors or the middleware, false if there is none
+func FromContext(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(*Principal)
+	return p, ok && p != nil
+}
+
+// RequireRole returns nil if the principal of the context has at least one of the roles,
+// otherwise a status error with Unauthenticated without principal or PermissionDenied
+func RequireRole(ctx context.Context, roles ...string) error {
+	p, ok := FromContext(ctx)
+	if !ok {
+		return status.Error(codes.Unauthenticated, ErrMissingToken.Error())
+	}
+
+	for _, role := range roles {
+		if p.HasRole(role) {
+			return nil
+		}
+	}
+
+	return status.Errorf(codes.PermissionDenied, "one of the roles %s is required", strings.Join(roles, ", "))
+}
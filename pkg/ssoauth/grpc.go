@@ -0,0 +1,101 @@
+package ssoauth
+
+import (
+	"context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"strings"
+)
+
+// UnaryServerInterceptor verifies the bearer token of the authorization metadata, or the token field of the request
+// like the SSO API has it, and puts the principal into the context; invalid tokens are rejected with Unauthenticated.
+// The public methods, full names like /pkg.Service/Method, are called without token
+func UnaryServerInterceptor(v *Verifier, publicMethods ...string) grpc.UnaryServerInterceptor {
+	public := methodSet(publicMethods)
+
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if public[info.FullMethod] {
+			return handler(ctx, req)
+		}
+
+		token := bearerToken(ctx)
+		if token == "" {
+			token = tokenField(req)
+		}
+
+		p, err := v.Verify(ctx, token)
+		if err != nil {
+			return nil, status.Error(codes.Unauthenticated, err.Error())
+		}
+
+		return handler(NewContext(ctx, p), req)
+	}
+}
+
+// StreamServerInterceptor is UnaryServerInterceptor for streams, the token has to be in the authorization metadata
+func StreamServerInterceptor(v *Verifier, publicMethods ...string) grpc.StreamServerInterceptor {
+	public := methodSet(publicMethods)
+
+	return func(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if public[info.FullMethod] {
+			return handler(srv, stream)
+		}
+
+		p, err := v.Verify(stream.Context(), bearerToken(stream.Context()))
+		if err != nil {
+			return status.Error(codes.Unauthenticated, err.Error())
+		}
+
+		return handler(srv, &principalStream{ServerStream: stream, ctx: NewContext(stream.Context(), p)})
+	}
+}
+
+type principalStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *principalStream) Context() context.Context {
+	return s.ctx
+}
+
+func methodSet(methods []string) map[string]bool {
+	set := make(map[string]bool, len(methods))
+	for _, method := range methods {
+		set[method] = true
+	}
+
+	return set
+}
+
+// bearerToken returns the token of the authorization metadata, empty if there is none
+func bearerToken(ctx context.Context) string {
+	for _, value := range metadata.ValueFromIncomingContext(ctx, "authorization") {
+		if token, ok := strings.CutPrefix(value, "Bearer "); ok {
+			return token
+		}
+	}
+
+	return ""
+}
+
+// tokenField returns the string field token of the request, empty if there is none
+func tokenField(req any) string {
+	message, ok := req.(proto.Message)
+	if !ok {
+		return ""
+	}
+
+	reflectMessage := message.ProtoReflect()
+
+	field := reflectMessage.Descriptor().Fields().ByName("token")
+	if field == nil || field.Kind() != protoreflect.StringKind {
+		return ""
+	}
+
+	return reflectMessage.Get(field).String()
+}
@@ -0,0 +1,49 @@
+package ssoauth
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Middleware verifies the bearer token of the Authorization header and puts the principal into the request context,
+// requests with an invalid or without token are rejected with 401
+func Middleware(v *Verifier) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+
+			p, err := v.Verify(r.Context(), token)
+			if err != nil {
+				w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+				http.Error(w, err.Error(), http.StatusUnauthorized)
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(NewContext(r.Context(), p)))
+		})
+	}
+}
+
+// RequireRoleMiddleware rejects requests whose principal has none of the roles with 403,
+// it has to run after Middleware
+func RequireRoleMiddleware(roles ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			p, ok := FromContext(r.Context())
+			if !ok {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				http.Error(w, ErrMissingToken.Error(), http.StatusUnauthorized)
+				return
+			}
+
+			for _, role := range roles {
+				if p.HasRole(role) {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+
+			http.Error(w, "one of the roles "+strings.Join(roles, ", ")+" is required", http.StatusForbidden)
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package ssoauth
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// KeySource returns the key the tokens are signed with, jwt_secret of the SSO config
+type KeySource interface {
+	Key(ctx context.Context) ([]byte, error)
+}
+
+// SharedKey is a KeySource of a fixed key
+type SharedKey []byte
+
+func (k SharedKey) Key(context.Context) ([]byte, error) {
+	return k, nil
+}
+
+// KeyFunc loads the key, e.g. from a secret store
+type KeyFunc func(ctx context.Context) ([]byte, error)
+
+func (f KeyFunc) Key(ctx context.Context) ([]byte, error) {
+	return f(ctx)
+}
+
+// CachedKey keeps the key of the source for the ttl, so it is not loaded for every token;
+// if loading fails after the ttl the last key is used until a load succeeds
+func CachedKey(source KeySource, ttl time.Duration) KeySource {
+	return &cachedKey{source: source, ttl: ttl}
+}
+
+type cachedKey struct {
+	source KeySource
+	ttl    time.Duration
+
+	mu       sync.Mutex
+	key      []byte
+	loadedAt time.Time
+}
+
+func (c *cachedKey) Key(ctx context.Context) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.key != nil && time.Since(c.loadedAt) < c.ttl {
+		return c.key, nil
+	}
+
+	key, err := c.source.Key(ctx)
+	if err != nil {
+		if c.key != nil {
+			return c.key, nil
+		}
+		return nil, err
+	}
+
+	c.key = key
+	c.loadedAt = time.Now()
+
+	return key, nil
+}
@@ -0,0 +1,199 @@
+// Package ssoauth verifies the tokens issued by the SSO service locally, without calling the service.
+//
+// The Verifier checks the signature with the shared key, the expiry, the issuer and the audience.
+// The gRPC interceptors and the http middleware put the Principal of a valid token into the context:
+//
+//	verifier := ssoauth.NewVerifier(ssoauth.SharedKey(secret), ssoauth.WithIssuer("sso"), ssoauth.WithAudience("sso"))
+//	server := grpc.NewServer(grpc.ChainUnaryInterceptor(ssoauth.UnaryServerInterceptor(verifier)))
+//
+//	func (s *server) DeleteThing(ctx context.Context, req *pb.DeleteThingRequest) (*pb.DeleteThingResponse, error) {
+//		if err := ssoauth.RequireRole(ctx, "admin"); err != nil {
+//			return nil, err
+//		}
+//		...
+//	}
+package ssoauth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/dgrijalva/jwt-go"
+	"strconv"
+	"time"
+)
+
+var (
+	ErrMissingToken = errors.New("missing token")
+	ErrInvalidToken = errors.New("invalid token")
+)
+
+// Principal is the user of a verified token
+type Principal struct {
+	UserId uint64
+	Email  string
+	// Roles are the names of the roles the user had when the token was issued
+	Roles     []string
+	ExpiresAt time.Time
+	// Claims are all claims of the token, e.g. the claims of the custom attributes
+	Claims map[string]any
+}
+
+// HasRole reports whether the principal has the role
+func (p *Principal) HasRole(role string) bool {
+	for _, name := range p.Roles {
+		if name == role {
+			return true
+		}
+	}
+
+	return false
+}
+
+type Verifier struct {
+	keys     KeySource
+	issuer   string
+	audience string
+	leeway   time.Duration
+}
+
+// Option configures the Verifier
+type Option func(*Verifier)
+
+// WithIssuer requires the iss claim to be the issuer, jwt_issuer of the SSO config
+func WithIssuer(issuer string) Option {
+	return func(v *Verifier) {
+		v.issuer = issuer
+	}
+}
+
+// WithAudience requires the aud claim to contain the audience, jwt_audience of the SSO config
+func WithAudience(audience string) Option {
+	return func(v *Verifier) {
+		v.audience = audience
+	}
+}
+
+// WithLeeway allows the clocks of the SSO service and this service to differ by the leeway
+func WithLeeway(leeway time.Duration) Option {
+	return func(v *Verifier) {
+		v.leeway = leeway
+	}
+}
+
+// NewVerifier returns a verifier of the tokens signed with the key of the source,
+// without WithIssuer and WithAudience the iss and aud claims are not checked
+func NewVerifier(keys KeySource, opts ...Option) *Verifier {
+	v := &Verifier{keys: keys}
+	for _, opt := range opts {
+		opt(v)
+	}
+
+	return v
+}
+
+// Verify returns the principal of the token, the error wraps ErrMissingToken or ErrInvalidToken
+func (v *Verifier) Verify(ctx context.Context, token string) (*Principal, error) {
+	if token == "" {
+		return nil, ErrMissingToken
+	}
+
+	// the registered claims are checked below with the leeway
+	parser := &jwt.Parser{SkipClaimsValidation: true}
+
+	parsed, err := parser.Parse(token, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method %v", token.Header["alg"])
+		}
+		return v.keys.Key(ctx)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	claims, ok := parsed.Claims.(jwt.MapClaims)
+	if !ok || !parsed.Valid {
+		return nil, ErrInvalidToken
+	}
+
+	if err := v.checkClaims(claims); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	return principal(claims)
+}
+
+func (v *Verifier) checkClaims(claims jwt.MapClaims) error {
+	now := time.Now()
+
+	// numbers are decoded as float64
+	exp, ok := claims["exp"].(float64)
+	if !ok {
+		return errors.New("token has no exp")
+	}
+	if now.After(time.Unix(int64(exp), 0).Add(v.leeway)) {
+		return errors.New("token is expired")
+	}
+
+	if nbf, ok := claims["nbf"].(float64); ok && now.Add(v.leeway).Before(time.Unix(int64(nbf), 0)) {
+		return errors.New("token is not valid yet")
+	}
+
+	if v.issuer != "" && !claims.VerifyIssuer(v.issuer, true) {
+		return errors.New("unexpected issuer")
+	}
+
+	if v.audience != "" && !hasAudience(claims["aud"], v.audience) {
+		return errors.New("unexpected audience")
+	}
+
+	return nil
+}
+
+// hasAudience reports whether the aud claim, a string or an array of strings, contains the audience
+func hasAudience(claim any, audience string) bool {
+	switch aud := claim.(type) {
+	case string:
+		return aud == audience
+	case []any:
+		for _, item := range aud {
+			if item == audience {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func principal(claims jwt.MapClaims) (*Principal, error) {
+	p := &Principal{Claims: claims}
+
+	switch uid := claims["uid"].(type) {
+	case float64:
+		p.UserId = uint64(uid)
+	default:
+		sub, _ := claims["sub"].(string)
+		userId, err := strconv.ParseUint(sub, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: token has no user", ErrInvalidToken)
+		}
+		p.UserId = userId
+	}
+
+	p.Email, _ = claims["email"].(string)
+
+	if exp, ok := claims["exp"].(float64); ok {
+		p.ExpiresAt = time.Unix(int64(exp), 0)
+	}
+
+	if roles, ok := claims["roles"].([]any); ok {
+		for _, role := range roles {
+			if name, ok := role.(string); ok {
+				p.Roles = append(p.Roles, name)
+			}
+		}
+	}
+
+	return p, nil
+}
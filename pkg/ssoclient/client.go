@@ -0,0 +1,85 @@
+// Package ssoclient is the Go client of the SSO gRPC API.
+//
+// The Users and Roles clients are the generated stubs on a connection that applies the options:
+// every call gets the default timeout, idempotent calls and calls with WithIdempotencyKey are retried with backoff
+// and the token of the TokenSource is set on the requests.
+//
+//	client, err := ssoclient.New("sso.internal:9800", ssoclient.WithPassword(email, password))
+//	if err != nil {
+//		return err
+//	}
+//	defer client.Close()
+//
+//	ok, err := client.HasRoles(ctx, userId, "admin")
+package ssoclient
+
+import (
+	"crypto/tls"
+	"fmt"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	sso "sso_go_grpc/proto/gen"
+)
+
+type Client struct {
+	Users sso.UserApiClient
+	Roles sso.RoleApiClient
+
+	conn   *grpc.ClientConn
+	tokens TokenSource
+}
+
+// New returns a client of the SSO server at the target, e.g. "localhost:9800",
+// the connection is established on the first call
+func New(target string, opts ...Option) (*Client, error) {
+	const op = "ssoclient.New"
+
+	options := defaultOptions()
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	creds := insecure.NewCredentials()
+	if !options.insecure {
+		tlsConfig := options.tls
+		if tlsConfig == nil {
+			tlsConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+		}
+		creds = credentials.NewTLS(tlsConfig)
+	}
+
+	client := &Client{tokens: options.tokens}
+
+	// the timeout covers all attempts of a call, the token is set again on every attempt
+	dialOptions := append([]grpc.DialOption{
+		grpc.WithTransportCredentials(creds),
+		grpc.WithChainUnaryInterceptor(
+			timeoutUnary(options.timeout),
+			retryUnary(options.retry),
+			client.tokenUnary(),
+		),
+		grpc.WithChainStreamInterceptor(client.tokenStream()),
+	}, options.dialOptions...)
+
+	conn, err := grpc.Dial(target, dialOptions...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	client.conn = conn
+	client.Users = sso.NewUserApiClient(conn)
+	client.Roles = sso.NewRoleApiClient(conn)
+
+	// the password source logs in with the client itself, Login is sent without a token
+	if options.password != nil {
+		client.tokens = newLoginSource(client.Users, options.password.email, options.password.password)
+	}
+
+	return client, nil
+}
+
+// Close closes the connection, running calls are canceled
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
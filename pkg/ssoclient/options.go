@@ -0,0 +1,100 @@
+package ssoclient
+
+import (
+	"crypto/tls"
+	"google.golang.org/grpc"
+	"time"
+)
+
+// Option configures the Client
+type Option func(*options)
+
+type options struct {
+	tls         *tls.Config
+	insecure    bool
+	timeout     time.Duration
+	retry       RetryPolicy
+	tokens      TokenSource
+	password    *credentialsOption
+	dialOptions []grpc.DialOption
+}
+
+type credentialsOption struct {
+	email    string
+	password string
+}
+
+// RetryPolicy configures the retries of idempotent calls failing with Unavailable or ResourceExhausted,
+// the backoff doubles from BaseBackoff up to MaxBackoff with full jitter
+type RetryPolicy struct {
+	// MaxAttempts includes the first attempt, 1 disables the retries
+	MaxAttempts int
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+}
+
+func defaultOptions() options {
+	return options{
+		timeout: 10 * time.Second,
+		retry:   RetryPolicy{MaxAttempts: 3, BaseBackoff: 100 * time.Millisecond, MaxBackoff: 2 * time.Second},
+	}
+}
+
+// WithTLS sets the tls config of the connection, by default the server is verified with the system roots;
+// set Certificates for mTLS
+func WithTLS(config *tls.Config) Option {
+	return func(o *options) {
+		o.tls = config
+		o.insecure = false
+	}
+}
+
+// WithInsecure connects without TLS, e.g. to a local server or through a TLS terminating proxy
+func WithInsecure() Option {
+	return func(o *options) {
+		o.insecure = true
+	}
+}
+
+// WithTimeout sets the timeout of unary calls whose context has no deadline, 0 disables it; default 10s
+func WithTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.timeout = timeout
+	}
+}
+
+// WithRetry sets the retry policy of idempotent calls; default 3 attempts with a backoff from 100ms up to 2s
+func WithRetry(policy RetryPolicy) Option {
+	return func(o *options) {
+		o.retry = policy
+	}
+}
+
+// WithTokenSource sets the source of the tokens set on the requests
+func WithTokenSource(source TokenSource) Option {
+	return func(o *options) {
+		o.tokens = source
+		o.password = nil
+	}
+}
+
+// WithToken sets a fixed token on the requests
+func WithToken(token string) Option {
+	return WithTokenSource(StaticToken(token))
+}
+
+// WithPassword logs in with the email and password and logs in again before the token expires
+// or after the server rejected it
+func WithPassword(email, password string) Option {
+	return func(o *options) {
+		o.tokens = nil
+		o.password = &credentialsOption{email: email, password: password}
+	}
+}
+
+// WithDialOptions adds grpc dial options, e.g. interceptors or a custom dialer
+func WithDialOptions(dialOptions ...grpc.DialOption) Option {
+	return func(o *options) {
+		o.dialOptions = append(o.dialOptions, dialOptions...)
+	}
+}
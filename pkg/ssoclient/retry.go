@@ -0,0 +1,103 @@
+package ssoclient
+
+import (
+	"context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"math/rand"
+	"time"
+)
+
+// idempotentMethods are retried, they only read
+var idempotentMethods = map[string]bool{
+	"/api.UserApi/GetUserById":      true,
+	"/api.UserApi/GetUserByEmail":   true,
+	"/api.UserApi/ListUsers":        true,
+	"/api.UserApi/ExportUserData":   true,
+	"/api.UserApi/ListLoginHistory": true,
+	"/api.RoleApi/VerifyUserRoles":  true,
+}
+
+// timeoutUnary sets the timeout on calls whose context has no deadline
+func timeoutUnary(timeout time.Duration) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if _, ok := ctx.Deadline(); timeout <= 0 || ok {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+// idempotencyKey is the metadata key the server replays the responses of mutating calls for
+const idempotencyKey = "idempotency-key"
+
+// WithIdempotencyKey returns a context whose calls send the idempotency key, the server runs a mutating call
+// with the key once and returns its response to the retries, so the calls with a key are retried too.
+// The key, e.g. a random UUID, has to be new for every operation
+func WithIdempotencyKey(ctx context.Context, key string) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, idempotencyKey, key)
+}
+
+// retryUnary retries idempotent calls and calls with an idempotency key on Unavailable and ResourceExhausted
+// until the attempts are used up or the context is done
+func retryUnary(policy RetryPolicy) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if !idempotentMethods[method] && !hasIdempotencyKey(ctx) {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+
+		var err error
+
+		for attempt := 1; ; attempt++ {
+			err = invoker(ctx, method, req, reply, cc, opts...)
+			if !retryable(err) || attempt >= policy.MaxAttempts {
+				return err
+			}
+
+			timer := time.NewTimer(backoff(policy, attempt))
+
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return err
+			case <-timer.C:
+			}
+		}
+	}
+}
+
+func hasIdempotencyKey(ctx context.Context) bool {
+	md, _ := metadata.FromOutgoingContext(ctx)
+	return len(md.Get(idempotencyKey)) > 0
+}
+
+func retryable(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.ResourceExhausted:
+		return true
+	}
+
+	return false
+}
+
+// backoff returns a random duration up to BaseBackoff * 2^(attempt-1), at most MaxBackoff
+func backoff(policy RetryPolicy, attempt int) time.Duration {
+	limit := policy.BaseBackoff
+	for i := 1; i < attempt && limit < policy.MaxBackoff; i++ {
+		limit *= 2
+	}
+	if policy.MaxBackoff > 0 && limit > policy.MaxBackoff {
+		limit = policy.MaxBackoff
+	}
+	if limit <= 0 {
+		return 0
+	}
+
+	return time.Duration(rand.Int63n(int64(limit)))
+}
@@ -0,0 +1,62 @@
+package ssoclient
+
+import (
+	"context"
+	"fmt"
+	sso "sso_go_grpc/proto/gen"
+)
+
+// HasRoles reports whether the user has all the roles by name, true if no roles are given
+func (c *Client) HasRoles(ctx context.Context, userId uint64, roles ...string) (bool, error) {
+	const op = "ssoclient.HasRoles"
+
+	res, err := c.Users.GetUserById(ctx, &sso.GetUserByIdRequest{UserId: userId})
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	names := make(map[string]bool, len(res.GetUser().GetRoles()))
+	for _, role := range res.GetUser().GetRoles() {
+		names[role.GetName()] = true
+	}
+
+	for _, role := range roles {
+		if !names[role] {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// HasAnyRole reports whether the user has at least one of the roles by name
+func (c *Client) HasAnyRole(ctx context.Context, userId uint64, roles ...string) (bool, error) {
+	const op = "ssoclient.HasAnyRole"
+
+	res, err := c.Users.GetUserById(ctx, &sso.GetUserByIdRequest{UserId: userId})
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	for _, role := range res.GetUser().GetRoles() {
+		for _, name := range roles {
+			if role.GetName() == name {
+				return true, nil
+			}
+		}
+	}
+
+	return false, nil
+}
+
+// VerifyRoleIds reports whether the user has all the roles by id, it is checked by the server with VerifyUserRoles
+func (c *Client) VerifyRoleIds(ctx context.Context, userId uint64, roleIds ...uint64) (bool, error) {
+	const op = "ssoclient.VerifyRoleIds"
+
+	res, err := c.Roles.VerifyUserRoles(ctx, &sso.VerifyUserRolesRequest{UserId: userId, RoleIds: roleIds})
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return res.GetVerified(), nil
+}
@@ -0,0 +1,195 @@
+package ssoclient
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	sso "sso_go_grpc/proto/gen"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TokenSource returns the token set on the requests
+type TokenSource interface {
+	Token(ctx context.Context) (string, error)
+}
+
+// Invalidator is implemented by token sources that can get a new token,
+// Invalidate is called after the server rejected the token with Unauthenticated
+type Invalidator interface {
+	Invalidate(token string)
+}
+
+// StaticToken is a TokenSource of a fixed token
+type StaticToken string
+
+func (t StaticToken) Token(context.Context) (string, error) {
+	return string(t), nil
+}
+
+// publicMethods are sent without a token
+var publicMethods = map[string]bool{
+	"/api.UserApi/Register": true,
+	"/api.UserApi/Login":    true,
+}
+
+// tokenUnary sets the token on the requests with a token field that is empty and as bearer token in the metadata,
+// a call rejected with Unauthenticated is sent once more with a new token
+func (c *Client) tokenUnary() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if c.tokens == nil || publicMethods[method] {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+
+		// a token set by the caller is kept
+		field := emptyTokenField(req)
+
+		send := func() (string, error) {
+			token, err := c.tokens.Token(ctx)
+			if err != nil {
+				return "", err
+			}
+
+			if field != nil {
+				req.(proto.Message).ProtoReflect().Set(field, protoreflect.ValueOfString(token))
+			}
+
+			return token, invoker(withBearer(ctx, token), method, req, reply, cc, opts...)
+		}
+
+		token, err := send()
+
+		invalidator, ok := c.tokens.(Invalidator)
+		if ok && status.Code(err) == codes.Unauthenticated {
+			invalidator.Invalidate(token)
+			_, err = send()
+		}
+
+		return err
+	}
+}
+
+// tokenStream sets the bearer token of streams, the token field of the streamed requests is set by the caller
+func (c *Client) tokenStream() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		if c.tokens == nil {
+			return streamer(ctx, desc, cc, method, opts...)
+		}
+
+		token, err := c.tokens.Token(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		return streamer(withBearer(ctx, token), desc, cc, method, opts...)
+	}
+}
+
+// Token returns the token of the client, e.g. for the token field of streamed requests
+func (c *Client) Token(ctx context.Context) (string, error) {
+	if c.tokens == nil {
+		return "", nil
+	}
+
+	return c.tokens.Token(ctx)
+}
+
+func withBearer(ctx context.Context, token string) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+token)
+}
+
+// emptyTokenField returns the token field of the request if it is empty, nil if it is set or there is none
+func emptyTokenField(req any) protoreflect.FieldDescriptor {
+	message, ok := req.(proto.Message)
+	if !ok {
+		return nil
+	}
+
+	reflectMessage := message.ProtoReflect()
+
+	field := reflectMessage.Descriptor().Fields().ByName("token")
+	if field == nil || field.Kind() != protoreflect.StringKind || reflectMessage.Get(field).String() != "" {
+		return nil
+	}
+
+	return field
+}
+
+// refreshBefore is the time before the expiry of a token the loginSource logs in again
+const refreshBefore = time.Minute
+
+// loginSource logs in with email and password, the token is kept until shortly before it expires
+type loginSource struct {
+	users    sso.UserApiClient
+	email    string
+	password string
+
+	mu     sync.Mutex
+	token  string
+	expiry time.Time
+}
+
+func newLoginSource(users sso.UserApiClient, email, password string) *loginSource {
+	return &loginSource{users: users, email: email, password: password}
+}
+
+func (s *loginSource) Token(ctx context.Context) (string, error) {
+	const op = "ssoclient.loginSource.Token"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.token != "" && (s.expiry.IsZero() || time.Now().Add(refreshBefore).Before(s.expiry)) {
+		return s.token, nil
+	}
+
+	res, err := s.users.Login(ctx, &sso.LoginRequest{Email: s.email, Password: s.password})
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	s.token = res.GetToken()
+	s.expiry = expiry(s.token)
+
+	return s.token, nil
+}
+
+func (s *loginSource) Invalidate(token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// a token of a concurrent login is kept
+	if s.token == token {
+		s.token = ""
+	}
+}
+
+// expiry returns the exp claim of the jwt without verifying it, zero if the token has none
+func expiry(token string) time.Time {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return time.Time{}
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return time.Time{}
+	}
+
+	var claims struct {
+		Exp float64 `json:"exp"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil || claims.Exp == 0 {
+		return time.Time{}
+	}
+
+	return time.Unix(int64(claims.Exp), 0)
+}